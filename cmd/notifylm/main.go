@@ -1,24 +1,44 @@
 package main
 
 import (
+	"bufio"
+	"cmp"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // so timezone names resolve without system zoneinfo
+
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/emirlan/notifylm/internal/calendar"
 	"github.com/emirlan/notifylm/internal/classifier"
 	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/contacts"
+	"github.com/emirlan/notifylm/internal/feedback"
+	"github.com/emirlan/notifylm/internal/googleauth"
+	"github.com/emirlan/notifylm/internal/hooks"
 	"github.com/emirlan/notifylm/internal/listener"
+	"github.com/emirlan/notifylm/internal/login"
 	"github.com/emirlan/notifylm/internal/message"
 	"github.com/emirlan/notifylm/internal/notifier"
+	"github.com/emirlan/notifylm/internal/redact"
+	"github.com/emirlan/notifylm/internal/rules"
 	"github.com/emirlan/notifylm/internal/server"
 	"github.com/emirlan/notifylm/internal/store"
+	"github.com/emirlan/notifylm/internal/transcriber"
+	"github.com/emirlan/notifylm/internal/vision"
 )
 
 func main() {
@@ -26,8 +46,17 @@ func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	debug := flag.Bool("debug", false, "Enable debug logging")
 	dryRun := flag.Bool("dry-run", false, "Don't send actual notifications")
+	hashPassword := flag.Bool("hash-password", false, "Read a password from stdin, print its bcrypt hash for server.auth.password_hash and exit")
 	flag.Parse()
 
+	if *hashPassword {
+		if err := printPasswordHash(os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Setup logging
 	logLevel := slog.LevelInfo
 	if *debug {
@@ -39,9 +68,19 @@ func main() {
 
 	// Load configuration
 	cfg, err := config.Load(*configPath)
-	if err != nil {
-		slog.Warn("Failed to load config, using defaults", "error", err)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Config file not found, using defaults", "path", *configPath)
 		cfg = config.DefaultConfig()
+	} else if err != nil {
+		slog.Error("Invalid config", "path", *configPath, "error", err)
+		os.Exit(1)
+	}
+
+	// Everything that reads or shows dates (the classifier, calendar,
+	// rules and dashboard) works in the user's timezone
+	if cfg.Timezone != "" {
+		time.Local = cfg.Location()
+		slog.Info("Using timezone", "timezone", cfg.Timezone)
 	}
 
 	// Create context with cancellation
@@ -63,28 +102,119 @@ func main() {
 	// Create in-memory store for the dashboard
 	msgStore := store.NewStore(500)
 
+	// LLM spending is kept on disk so budgets survive restarts
+	usagePath := cfg.LLM.UsagePath
+	if usagePath == "" {
+		usagePath = filepath.Join(filepath.Dir(cmp.Or(cfg.Feedback.Path, "./feedback.jsonl")), "usage.json")
+	}
+	if err := msgStore.UseUsageFile(usagePath); err != nil {
+		slog.Error("Failed to load LLM usage, starting from zero", "error", err)
+	}
+
 	// Initialize listeners
 	listeners := initializeListeners(cfg)
 
 	// Register listeners in the store
 	for _, l := range listeners {
-		var src message.Source
-		switch l.Name() {
-		case "WhatsApp":
-			src = message.SourceWhatsApp
-		case "Telegram":
-			src = message.SourceTelegram
-		case "Slack":
-			src = message.SourceSlack
-		case "Gmail":
-			src = message.SourceGmail
+		msgStore.UpdateListenerStatus(l.Name(), l.Source(), l.Account(), true)
+	}
+
+	// Corrections from the dashboard become few-shot examples for the classifier
+	var fb *feedback.Store
+	if cfg.Feedback.Enabled {
+		if fb, err = feedback.NewStore(cfg.Feedback.Path); err != nil {
+			slog.Error("Failed to load classification feedback, corrections are disabled", "error", err)
+			fb = nil
+		}
+	}
+
+	// VIP, blocked and priority-overridden senders
+	var book *contacts.Book
+	if cfg.Contacts.Enabled {
+		if book, err = contacts.Load(cfg.Contacts.Path); err != nil {
+			slog.Error("Failed to load contacts", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Initialize classifier
+	msgClassifier := classifier.NewLLMClassifier(cfg.LLM)
+	if fb != nil {
+		msgClassifier.UseExamples(fb, cfg.Feedback.MaxExamples)
+	}
+	if cfg.LLM.ContextMessages >= 0 {
+		msgClassifier.UseHistory(msgStore, cfg.LLM.ContextMessages, cfg.LLM.ContextTokens)
+	}
+	var redactor *redact.Redactor
+	if cfg.Redaction.Enabled {
+		if redactor, err = redact.New(cfg.Redaction); err != nil {
+			slog.Error("Invalid redaction config", "error", err)
+			os.Exit(1)
 		}
-		msgStore.UpdateListenerStatus(l.Name(), src, true)
+		msgClassifier.UseRedaction(redactor)
 	}
 
+	// Repeated messages reuse their earlier classification
+	var cls classifier.Classifier = msgClassifier
+	var cache *classifier.Cache
+	if cfg.Cache.Enabled {
+		cache = classifier.NewCache(msgClassifier, cfg.Cache)
+		cls = cache
+	}
+
+	// Google OAuth prompts go to the terminal unless the dashboard can host them
+	var googleAuth googleauth.Authorizer = googleauth.TerminalAuthorizer{}
+
 	// Start dashboard server
 	if cfg.Server.Enabled {
-		srv := server.New(msgStore, cfg.Server.Port)
+		// Route WhatsApp QR codes and Telegram login codes to the dashboard
+		// instead of the terminal.
+		loginBroker := login.NewBroker(msgStore.SetListenerAwaitingLogin)
+		for _, l := range listeners {
+			if il, ok := l.(listener.InteractiveLogin); ok {
+				il.UseLoginBroker(loginBroker)
+			}
+		}
+
+		if (cfg.Server.Auth.Mode == "" || cfg.Server.Auth.Mode == "none") && !isLoopback(cfg.Server.BindAddress) {
+			slog.Warn("Dashboard is reachable from the network without authentication; set server.auth.mode",
+				"bind_address", cfg.Server.BindAddress)
+		}
+
+		srv, err := server.New(cfg.Server, msgStore, loginBroker)
+		if err != nil {
+			slog.Error("Failed to create dashboard server", "error", err)
+			os.Exit(1)
+		}
+
+		if fb != nil {
+			srv.UseFeedback(fb)
+		}
+		if book != nil {
+			srv.UseContacts(book)
+		}
+		if cache != nil {
+			srv.UseCache(cache)
+		}
+		srv.UseBudget(cfg.LLM.Budget)
+
+		redirectURL := cfg.Google.RedirectURL
+		if redirectURL == "" {
+			scheme := "http"
+			if cfg.Server.TLSEnabled() {
+				scheme = "https"
+			}
+			redirectURL = fmt.Sprintf("%s://localhost:%d/oauth/google/callback", scheme, serverPort(cfg.Server))
+		}
+		webAuth := googleauth.NewWebAuthorizer(loginBroker, redirectURL)
+		srv.Handle("/oauth/google/", webAuth)
+		googleAuth = webAuth
+		for _, l := range listeners {
+			if gl, ok := l.(*listener.GmailListener); ok {
+				gl.UseGoogleAuthorizer(webAuth)
+			}
+		}
+
 		if err := srv.Start(); err != nil {
 			slog.Error("Failed to start dashboard server", "error", err)
 		} else {
@@ -96,16 +226,58 @@ func main() {
 		}
 	}
 
-	// Initialize classifier
-	msgClassifier := classifier.NewLLMClassifier(cfg.LLM)
-
 	// Initialize notifier
 	var msgNotifier notifier.Notifier
+	routes := make(map[string]notifier.Notifier)
 	if *dryRun {
 		msgNotifier = notifier.NewMockNotifier()
+		for name := range cfg.Pushover.Routes {
+			routes[name] = msgNotifier
+		}
 		slog.Info("Running in dry-run mode - notifications will be logged only")
 	} else {
 		msgNotifier = notifier.NewPushoverNotifier(cfg.Pushover)
+		for name, route := range cfg.Pushover.RouteConfigs() {
+			routes[name] = notifier.NewPushoverNotifier(route)
+		}
+	}
+
+	// Fall back to keywords and rules once the LLM budget is spent, and stop
+	// paying for transcripts and image descriptions
+	var overBudget func() string
+	if cfg.LLM.Budget.Enabled() {
+		overBudget = msgClassifier.BudgetExhausted
+		msgClassifier.UseBudget(msgStore, cfg.LLM.Budget, func(reason string) {
+			alert := message.NewMessage("notifylm", "LLM budget", "The "+reason+". Messages are classified by keywords and rules until it resets.")
+			if err := msgNotifier.Notify(alert); err != nil {
+				slog.Error("Failed to send budget notification", "error", err)
+			}
+		})
+	}
+
+	// Declarative rules, reloaded when the file changes
+	var ruleEngine *rules.Engine
+	if cfg.Rules.Enabled {
+		if ruleEngine, err = rules.Load(cfg.Rules.Path); err != nil {
+			slog.Error("Failed to load rules", "error", err)
+			os.Exit(1)
+		}
+		for _, name := range ruleEngine.Routes() {
+			if _, ok := routes[name]; !ok {
+				slog.Warn("Rules refer to an unknown notifier route; add it under pushover.routes", "route", name)
+			}
+		}
+		go ruleEngine.Watch(ctx, time.Duration(cfg.Rules.ReloadSeconds)*time.Second)
+	}
+
+	// Scripted hooks, reloaded when the file changes
+	var hookEngine *hooks.Engine
+	if cfg.Hooks.Enabled {
+		if hookEngine, err = hooks.New(cfg.Hooks); err != nil {
+			slog.Error("Failed to load hooks", "error", err)
+			os.Exit(1)
+		}
+		go hookEngine.Watch(ctx, time.Duration(cfg.Hooks.ReloadSeconds)*time.Second)
 	}
 
 	// Initialize calendar event creator
@@ -114,23 +286,120 @@ func main() {
 		if *dryRun {
 			calendarCreator = calendar.NewMockCalendarCreator()
 			slog.Info("Calendar: using mock creator (dry-run mode)")
-		} else {
-			gc, err := calendar.NewGoogleCalendarCreator(ctx, cfg.Calendar)
+		} else if cfg.Calendar.Backend == "caldav" {
+			cc, err := calendar.NewCalDAVCreator(cfg.Calendar)
 			if err != nil {
-				slog.Error("Failed to initialize Google Calendar, disabling", "error", err)
+				slog.Error("Failed to initialize CalDAV calendar, disabling", "error", err)
 			} else {
-				calendarCreator = gc
+				calendarCreator = cc
+				go discoverCalDAV(ctx, "calendar", cc.Discover)
+			}
+		} else {
+			// Connecting the account may wait for the user, so don't hold up
+			// the listeners meanwhile.
+			deferred := calendar.NewDeferredCreator()
+			calendarCreator = deferred
+			go func() {
+				gc, err := calendar.NewGoogleCalendarCreator(ctx, cfg.Calendar, googleAuth)
+				if err != nil {
+					slog.Error("Failed to initialize Google Calendar, disabling", "error", err)
+					return
+				}
+				deferred.Set(gc)
 				slog.Info("Google Calendar integration enabled")
+			}()
+		}
+	}
+
+	// Initialize the task list for undated action items
+	var taskSink calendar.TaskSink
+	if cfg.Tasks.Enabled {
+		switch {
+		case *dryRun:
+			taskSink = calendar.NewMockTaskSink()
+			slog.Info("Tasks: using mock sink (dry-run mode)")
+		case cfg.Tasks.Backend == "google":
+			deferred := calendar.NewDeferredTaskSink()
+			taskSink = deferred
+			go func() {
+				gt, err := calendar.NewGoogleTasksSink(ctx, cfg.Tasks, googleAuth)
+				if err != nil {
+					slog.Error("Failed to initialize Google Tasks, disabling", "error", err)
+					return
+				}
+				deferred.Set(gt)
+				slog.Info("Google Tasks integration enabled")
+			}()
+		case cfg.Tasks.Backend == "caldav":
+			ct, err := calendar.NewCalDAVTaskSink(cfg.Tasks.CalDAV)
+			if err != nil {
+				slog.Error("Failed to initialize CalDAV tasks, disabling", "error", err)
+			} else {
+				taskSink = ct
+				go discoverCalDAV(ctx, "task list", ct.Discover)
+			}
+		default:
+			ft, err := calendar.NewFileTaskSink(cfg.Tasks)
+			if err != nil {
+				slog.Error("Failed to initialize the tasks file, disabling", "error", err)
+			} else {
+				taskSink = ft
+				slog.Info("Tasks file enabled", "path", ft.Path())
+			}
+		}
+	}
+
+	// Initialize voice message transcription
+	var voiceTranscriber transcriber.Transcriber
+	if cfg.Transcription.Enabled {
+		t, err := transcriber.New(cfg.Transcription, cfg.LLM.Prices)
+		if err != nil {
+			slog.Error("Failed to initialize transcription, disabling", "error", err)
+		} else {
+			voiceTranscriber = t
+			for _, l := range listeners {
+				if md, ok := l.(listener.MediaDownloader); ok {
+					md.DownloadMedia(message.AttachmentAudio)
+				}
 			}
+			slog.Info("Voice message transcription enabled", "provider", cfg.Transcription.Provider)
 		}
 	}
 
+	// Initialize image understanding
+	var imageDescriber vision.Describer
+	if cfg.Vision.Enabled {
+		imageDescriber = vision.NewOpenAIDescriber(cfg.Vision, cfg.LLM.Prices)
+		for _, l := range listeners {
+			if md, ok := l.(listener.MediaDownloader); ok {
+				md.DownloadMedia(message.AttachmentImage)
+			}
+		}
+		slog.Info("Image understanding enabled", "model", cfg.Vision.Model)
+	}
+
+	p := &pipeline{
+		cls:        cls,
+		notify:     msgNotifier,
+		routes:     routes,
+		cal:        calendarCreator,
+		tasks:      taskSink,
+		st:         msgStore,
+		transcribe: voiceTranscriber,
+		describe:   imageDescriber,
+		redactor:   redactor,
+		overBudget: overBudget,
+		contacts:   book,
+		rules:      ruleEngine,
+		hooks:      hookEngine,
+	}
+
 	// Start message processor
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		processMessages(ctx, messageChan, msgClassifier, msgNotifier, calendarCreator, msgStore)
+		p.run(ctx, messageChan)
 	}()
 
 	// Start all listeners concurrently
@@ -174,164 +443,93 @@ func main() {
 	slog.Info("Shutdown complete")
 }
 
-func initializeListeners(cfg *config.Config) []listener.Listener {
-	var listeners []listener.Listener
-
-	if cfg.WhatsApp.Enabled {
-		listeners = append(listeners, listener.NewWhatsAppListener(cfg.WhatsApp))
-	}
-
-	if cfg.Telegram.Enabled {
-		listeners = append(listeners, listener.NewTelegramListener(cfg.Telegram))
+// serverPort returns the dashboard port, applying the server's default.
+func serverPort(cfg config.ServerConfig) int {
+	if cfg.Port == 0 {
+		return 8080
 	}
+	return cfg.Port
+}
 
-	if cfg.Slack.Enabled {
-		listeners = append(listeners, listener.NewSlackListener(cfg.Slack))
+// isLoopback reports whether the dashboard only listens on this machine.
+// An empty bind address means the server's 127.0.0.1 default.
+func isLoopback(addr string) bool {
+	if addr == "" || addr == "localhost" {
+		return true
 	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
 
-	if cfg.Gmail.Enabled {
-		listeners = append(listeners, listener.NewGmailListener(cfg.Gmail))
+// discoverCalDAV looks up a CalDAV calendar at startup so configuration
+// mistakes show up in the log right away. Lookup is retried on first use.
+func discoverCalDAV(ctx context.Context, what string, discover func(context.Context) (string, error)) {
+	url, err := discover(ctx)
+	if err != nil {
+		slog.Warn("CalDAV "+what+" not found yet", "error", err)
+		return
 	}
-
-	return listeners
+	slog.Info("CalDAV "+what+" enabled", "url", url)
 }
 
-func processMessages(
-	ctx context.Context,
-	messages <-chan *message.Message,
-	cls classifier.Classifier,
-	notify notifier.Notifier,
-	cal calendar.EventCreator,
-	st *store.Store,
-) {
-	for {
-		select {
-		case <-ctx.Done():
-			// Drain remaining messages
-			for msg := range messages {
-				handleMessage(ctx, msg, cls, notify, cal, st)
-			}
-			return
-		case msg, ok := <-messages:
-			if !ok {
-				return
-			}
-			handleMessage(ctx, msg, cls, notify, cal, st)
-		}
+// printPasswordHash reads a password line from r and prints its bcrypt hash.
+func printPasswordHash(r io.Reader) error {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
 	}
-}
 
-func handleMessage(
-	ctx context.Context,
-	msg *message.Message,
-	cls classifier.Classifier,
-	notify notifier.Notifier,
-	cal calendar.EventCreator,
-	st *store.Store,
-) {
-	slog.Debug("Received message",
-		"source", msg.Source,
-		"sender", msg.Sender,
-		"text_length", len(msg.Text))
-
-	// Track in store
-	st.IncrementListenerMessageCount(msg.Source)
-
-	// Classify message urgency and extract action items
-	result, err := cls.ClassifyMessage(ctx, msg)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		slog.Error("Classification failed",
-			"source", msg.Source,
-			"error", err)
-		// Still record the message in the store without classification
-		st.AddProcessedMessage(store.ProcessedMessage{
-			Message:     msg,
-			ProcessedAt: time.Now(),
-		})
-		return
+		return fmt.Errorf("failed to hash password: %w", err)
 	}
+	fmt.Println(string(hash))
+	return nil
+}
 
-	var notifiedAt *time.Time
-	eventsCreated := 0
-
-	// Handle urgency notification
-	if result.IsUrgent {
-		slog.Info("Urgent message detected",
-			"source", msg.Source,
-			"sender", msg.Sender)
+func initializeListeners(cfg *config.Config) []listener.Listener {
+	var listeners []listener.Listener
 
-		if err := notify.Notify(msg); err != nil {
-			slog.Error("Failed to send urgency notification",
-				"source", msg.Source,
-				"error", err)
-		} else {
-			now := time.Now()
-			notifiedAt = &now
-			st.AddNotification(store.Notification{
-				Message: msg,
-				Reason:  "urgent",
-				SentAt:  now,
-			})
+	if cfg.WhatsApp.IsEnabled() {
+		for _, acc := range cfg.WhatsApp.AccountConfigs() {
+			if !acc.IsEnabled() {
+				continue
+			}
+			listeners = append(listeners, listener.NewWhatsAppListener(acc))
 		}
 	}
 
-	// Handle action items
-	for _, item := range result.ActionItems {
-		slog.Info("Action item detected",
-			"title", item.Title,
-			"datetime", item.DateTime.Format(time.RFC3339),
-			"source", msg.Source,
-			"sender", msg.Sender)
-
-		// Send action item notification via Pushover
-		actionMsg := &message.Message{
-			ID:        msg.ID,
-			Source:    msg.Source,
-			Sender:    msg.Sender,
-			Text:      fmt.Sprintf("Action: %s\nDue: %s\n\n%s", item.Title, item.DateTime.Format("Jan 2, 2006 3:04 PM"), item.Description),
-			Timestamp: msg.Timestamp,
-			Metadata:  msg.Metadata,
-		}
-		if err := notify.Notify(actionMsg); err != nil {
-			slog.Error("Failed to send action item notification",
-				"title", item.Title,
-				"error", err)
-		} else {
-			now := time.Now()
-			if notifiedAt == nil {
-				notifiedAt = &now
+	if cfg.Telegram.IsEnabled() {
+		for _, acc := range cfg.Telegram.AccountConfigs() {
+			if !acc.IsEnabled() {
+				continue
 			}
-			st.AddNotification(store.Notification{
-				Message: msg,
-				Reason:  "action_item",
-				SentAt:  now,
-			})
+			listeners = append(listeners, listener.NewTelegramListener(acc))
 		}
+	}
 
-		// Create calendar event
-		if cal != nil {
-			if err := cal.CreateEvent(ctx, &item, msg); err != nil {
-				slog.Error("Failed to create calendar event",
-					"title", item.Title,
-					"error", err)
-			} else {
-				eventsCreated++
+	if cfg.Slack.IsEnabled() {
+		for _, acc := range cfg.Slack.AccountConfigs() {
+			if !acc.IsEnabled() {
+				continue
 			}
+			listeners = append(listeners, listener.NewSlackListener(acc))
 		}
 	}
 
-	// Record processed message in the store
-	st.AddProcessedMessage(store.ProcessedMessage{
-		Message:        msg,
-		Classification: result,
-		NotifiedAt:     notifiedAt,
-		EventsCreated:  eventsCreated,
-		ProcessedAt:    time.Now(),
-	})
-
-	if !result.IsUrgent && len(result.ActionItems) == 0 {
-		slog.Debug("Message classified as not urgent, no action items",
-			"source", msg.Source,
-			"sender", msg.Sender)
+	if cfg.Gmail.IsEnabled() {
+		for _, acc := range cfg.Gmail.AccountConfigs() {
+			if !acc.IsEnabled() {
+				continue
+			}
+			listeners = append(listeners, listener.NewGmailListener(acc))
+		}
 	}
+
+	return listeners
 }
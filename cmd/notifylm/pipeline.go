@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/calendar"
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/contacts"
+	"github.com/emirlan/notifylm/internal/hooks"
+	"github.com/emirlan/notifylm/internal/message"
+	"github.com/emirlan/notifylm/internal/notifier"
+	"github.com/emirlan/notifylm/internal/redact"
+	"github.com/emirlan/notifylm/internal/rules"
+	"github.com/emirlan/notifylm/internal/store"
+	"github.com/emirlan/notifylm/internal/transcriber"
+	"github.com/emirlan/notifylm/internal/vision"
+)
+
+// pipeline holds everything needed to process an incoming message.
+type pipeline struct {
+	cls        classifier.Classifier
+	notify     notifier.Notifier
+	routes     map[string]notifier.Notifier // named notifiers rules can pick
+	cal        calendar.EventCreator        // nil if calendar integration is disabled
+	tasks      calendar.TaskSink            // nil if tasks are disabled
+	st         *store.Store
+	transcribe transcriber.Transcriber // nil if transcription is disabled
+	describe   vision.Describer        // nil if image understanding is disabled
+	redactor   *redact.Redactor        // masks image captions; nil if they are sent as they are
+	overBudget func() string           // why the LLM budget is spent, "" if it isn't; nil if unlimited
+	contacts   *contacts.Book          // nil if contacts are disabled
+	rules      *rules.Engine           // nil if rules are disabled
+	hooks      *hooks.Engine           // nil if hooks are disabled
+}
+
+// run processes messages until the channel is closed.
+func (p *pipeline) run(ctx context.Context, messages <-chan *message.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			// Drain remaining messages
+			for msg := range messages {
+				p.handleMessage(ctx, msg)
+			}
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			p.handleMessage(ctx, msg)
+		}
+	}
+}
+
+func (p *pipeline) handleMessage(ctx context.Context, msg *message.Message) {
+	slog.Debug("Received message",
+		"source", msg.Source,
+		"account", msg.Metadata["account"],
+		"sender", msg.Sender,
+		"text_length", len(msg.Text))
+
+	// Track in store
+	p.st.IncrementListenerMessageCount(msg.Source, msg.Metadata["account"])
+
+	var outcomes []store.Outcome
+	record := func(kind, subject, status, detail string) {
+		outcomes = append(outcomes, store.Outcome{
+			Kind: kind, Subject: subject, Status: status, Detail: detail, At: time.Now(),
+		})
+	}
+
+	// Blocked senders are dropped before spending anything on them
+	blocked := func(contact *contacts.Contact) bool {
+		if contact == nil || !contact.Blocked {
+			return false
+		}
+		slog.Debug("Message from blocked sender", "source", msg.Source, "contact", contact.Name)
+		record("contact", contact.Name, "skipped", "blocked sender, not classified")
+		p.skip(msg, nil, outcomes)
+		return true
+	}
+	if blocked(p.contacts.Match(msg)) {
+		return
+	}
+
+	// Turn media into text first, so hooks and rules see transcripts and
+	// image descriptions rather than an empty voice note
+	imageItems := p.enrich(ctx, msg)
+
+	// The on_receive hook can rewrite the message before contacts and rules
+	// read it
+	if keep, err := p.hooks.OnReceive(msg); err != nil {
+		slog.Error("Hook failed", "hook", hooks.OnReceive, "source", msg.Source, "error", err)
+		record("hook", hooks.OnReceive, "failed", err.Error())
+	} else if !keep {
+		slog.Debug("Message suppressed by hook", "source", msg.Source, "sender", msg.Sender)
+		record("hook", hooks.OnReceive, "applied", "suppressed the message")
+		p.skip(msg, nil, outcomes)
+		return
+	}
+
+	// Matched again, as the hook may have rewritten the sender
+	contact := p.contacts.Match(msg)
+	if blocked(contact) {
+		return
+	}
+	if contact != nil {
+		msg.Metadata["contact"] = contact.Name
+		msg.Metadata["contact_priority"] = contact.Label()
+	}
+
+	// Rules that don't depend on the classification run before the LLM
+	decision := p.rules.Before(msg)
+	recordRules(decision.Matches, record)
+	if decision.Drop {
+		slog.Debug("Message dropped by rule", "source", msg.Source, "sender", msg.Sender)
+		p.skip(msg, nil, outcomes)
+		return
+	}
+	if decision.Priority != "" {
+		msg.Metadata["priority"] = decision.Priority
+	}
+
+	// Classify message urgency and extract action items
+	var result *classifier.ClassificationResult
+	var err error
+	if decision.SkipLLM {
+		result = &classifier.ClassificationResult{
+			Trace: &classifier.Trace{Method: "rule", Note: "classifier skipped by a rule"},
+		}
+	} else {
+		result, err = p.cls.ClassifyMessage(ctx, msg)
+	}
+	if err != nil {
+		slog.Error("Classification failed",
+			"source", msg.Source,
+			"error", err)
+		// A VIP is still worth a notification
+		var notifiedAt *time.Time
+		if contact != nil && contact.VIP {
+			notifiedAt = p.notifyVIP(p.notifier(decision), msg, contact, record)
+		}
+		// Still record the message in the store without classification
+		p.st.AddProcessedMessage(store.ProcessedMessage{
+			Message:       msg,
+			ClassifyError: err.Error(),
+			NotifiedAt:    notifiedAt,
+			Outcomes:      outcomes,
+			ProcessedAt:   time.Now(),
+		})
+		return
+	}
+	for _, item := range imageItems {
+		if !hasActionItem(result, item) {
+			result.ActionItems = append(result.ActionItems, item)
+		}
+	}
+	applyContact(result, contact, record)
+
+	// Then the rules that look at the classification, and the overrides
+	recordRules(decision.After(msg, result), record)
+	applyRules(result, decision)
+	if len(decision.Tags) > 0 {
+		msg.Metadata["tags"] = strings.Join(decision.Tags, ", ")
+	}
+	if decision.Drop {
+		slog.Debug("Message dropped by rule", "source", msg.Source, "sender", msg.Sender)
+		p.skip(msg, result, outcomes)
+		return
+	}
+
+	// The after_classify hook has the last word on the result
+	if keep, err := p.hooks.AfterClassify(msg, result); err != nil {
+		slog.Error("Hook failed", "hook", hooks.AfterClassify, "source", msg.Source, "error", err)
+		record("hook", hooks.AfterClassify, "failed", err.Error())
+	} else if !keep {
+		slog.Debug("Notifications suppressed by hook", "source", msg.Source, "sender", msg.Sender)
+		record("hook", hooks.AfterClassify, "applied", "suppressed notifications")
+		p.skip(msg, result, outcomes)
+		return
+	}
+	notify := p.notifier(decision)
+
+	// An edit is classified again, but should only page us for what's new.
+	var previous *classifier.ClassificationResult
+	if msg.Metadata["edited"] == "true" {
+		if pm, ok := p.st.FindMessage(msg.Source, msg.ID); ok {
+			previous = pm.Classification
+		}
+	}
+
+	var notifiedAt *time.Time
+	eventsCreated := 0
+
+	// Handle urgency notification
+	if result.IsUrgent && previous != nil && previous.IsUrgent {
+		record("notification", "urgent", "skipped", "already notified before the edit")
+	} else if result.IsUrgent {
+		slog.Info("Urgent message detected",
+			"source", msg.Source,
+			"sender", msg.Sender)
+
+		if sent, err := p.deliver(notify, msg, "urgent", record); err != nil {
+			slog.Error("Failed to send urgency notification",
+				"source", msg.Source,
+				"error", err)
+			record("notification", "urgent", "failed", err.Error())
+		} else if !sent {
+			record("notification", "urgent", "skipped", "suppressed by the before_notify hook")
+		} else {
+			now := time.Now()
+			notifiedAt = &now
+			p.st.AddNotification(store.Notification{
+				Message: msg,
+				Reason:  "urgent",
+				SentAt:  now,
+			})
+			record("notification", "urgent", "sent", "")
+		}
+	}
+
+	// Handle action items
+	for _, item := range result.ActionItems {
+		if previous != nil && hasActionItem(previous, item) {
+			record("notification", item.Title, "skipped", "action item already handled before the edit")
+			continue
+		}
+
+		// Undated items are to-dos rather than appointments, so they go on
+		// the task list quietly instead of paging the user
+		if item.DateTime.IsZero() {
+			p.addTask(ctx, &item, msg, record)
+			continue
+		}
+
+		slog.Info("Action item detected",
+			"title", item.Title,
+			"datetime", item.DateTime.Format(time.RFC3339),
+			"source", msg.Source,
+			"sender", msg.Sender)
+
+		due := item.DateTime.Format("Jan 2, 2006 3:04 PM")
+		if item.AllDay {
+			due = item.DateTime.Format("Mon, Jan 2, 2006")
+		}
+
+		// Send action item notification via Pushover
+		actionMsg := &message.Message{
+			ID:        msg.ID,
+			Source:    msg.Source,
+			Sender:    msg.Sender,
+			Text:      fmt.Sprintf("Action: %s\nDue: %s\n\n%s", item.Title, due, item.Description),
+			Timestamp: msg.Timestamp,
+			Metadata:  msg.Metadata,
+		}
+		if sent, err := p.deliver(notify, actionMsg, "action_item", record); err != nil {
+			slog.Error("Failed to send action item notification",
+				"title", item.Title,
+				"error", err)
+			record("notification", item.Title, "failed", err.Error())
+		} else if !sent {
+			record("notification", item.Title, "skipped", "suppressed by the before_notify hook")
+		} else {
+			now := time.Now()
+			if notifiedAt == nil {
+				notifiedAt = &now
+			}
+			p.st.AddNotification(store.Notification{
+				Message: msg,
+				Reason:  "action_item",
+				SentAt:  now,
+			})
+			record("notification", item.Title, "sent", "")
+		}
+
+		// Create calendar event
+		if p.cal != nil {
+			if err := p.cal.CreateEvent(ctx, &item, msg); err != nil {
+				slog.Error("Failed to create calendar event",
+					"title", item.Title,
+					"error", err)
+				record("calendar", item.Title, "failed", err.Error())
+			} else {
+				eventsCreated++
+				record("calendar", item.Title, "created", "")
+			}
+		} else {
+			record("calendar", item.Title, "skipped", "calendar integration is disabled")
+		}
+	}
+
+	// Record processed message in the store
+	p.st.AddProcessedMessage(store.ProcessedMessage{
+		Message:        msg,
+		Classification: result,
+		NotifiedAt:     notifiedAt,
+		EventsCreated:  eventsCreated,
+		Outcomes:       outcomes,
+		ProcessedAt:    time.Now(),
+	})
+
+	if !result.IsUrgent && len(result.ActionItems) == 0 {
+		slog.Debug("Message classified as not urgent, no action items",
+			"source", msg.Source,
+			"sender", msg.Sender)
+	}
+}
+
+// addTask saves an undated action item as a task.
+func (p *pipeline) addTask(ctx context.Context, item *classifier.ActionItem, msg *message.Message, record func(kind, subject, status, detail string)) {
+	slog.Info("Task detected",
+		"title", item.Title,
+		"source", msg.Source,
+		"sender", msg.Sender)
+
+	if p.tasks == nil {
+		record("task", item.Title, "skipped", "tasks are disabled")
+		return
+	}
+	if err := p.tasks.AddTask(ctx, item, msg); err != nil {
+		slog.Error("Failed to save task",
+			"title", item.Title,
+			"error", err)
+		record("task", item.Title, "failed", err.Error())
+		return
+	}
+	record("task", item.Title, "created", "")
+}
+
+// applyContact overrides the classifier's urgency for VIPs, who always
+// notify, and low-priority contacts, who never do.
+func applyContact(result *classifier.ClassificationResult, contact *contacts.Contact, record func(kind, subject, status, detail string)) {
+	switch {
+	case contact == nil:
+	case contact.VIP && !result.IsUrgent:
+		result.IsUrgent = true
+		record("contact", contact.Name, "applied", "VIP sender, marked urgent")
+	case contact.Priority == contacts.PriorityLow && result.IsUrgent:
+		result.IsUrgent = false
+		record("contact", contact.Name, "applied", "low-priority sender, not urgent")
+	}
+}
+
+// notifyVIP sends the urgency notification for a VIP whose message couldn't
+// be classified, returning when it was sent.
+func (p *pipeline) notifyVIP(notify notifier.Notifier, msg *message.Message, contact *contacts.Contact, record func(kind, subject, status, detail string)) *time.Time {
+	if sent, err := p.deliver(notify, msg, "vip", record); err != nil {
+		slog.Error("Failed to send VIP notification",
+			"source", msg.Source,
+			"error", err)
+		record("notification", "vip", "failed", err.Error())
+		return nil
+	} else if !sent {
+		record("notification", "vip", "skipped", "suppressed by the before_notify hook")
+		return nil
+	}
+	now := time.Now()
+	p.st.AddNotification(store.Notification{
+		Message: msg,
+		Reason:  "vip",
+		SentAt:  now,
+	})
+	record("notification", "vip", "sent", contact.Name+" is a VIP")
+	return &now
+}
+
+// deliver lets the before_notify hook rewrite or suppress a notification,
+// then sends it. The hook works on a copy so the stored message is
+// unchanged. It reports false if the hook suppressed the notification.
+func (p *pipeline) deliver(notify notifier.Notifier, msg *message.Message, reason string, record func(kind, subject, status, detail string)) (bool, error) {
+	if p.hooks.Defined(hooks.BeforeNotify) {
+		out := *msg
+		out.Metadata = maps.Clone(msg.Metadata)
+		send, err := p.hooks.BeforeNotify(&out, reason)
+		switch {
+		case err != nil:
+			// Fail open: a broken hook shouldn't cost a notification
+			slog.Error("Hook failed", "hook", hooks.BeforeNotify, "source", msg.Source, "error", err)
+			record("hook", hooks.BeforeNotify, "failed", err.Error())
+		case !send:
+			return false, nil
+		default:
+			msg = &out
+		}
+	}
+	return true, notify.Notify(msg)
+}
+
+// recordRules lists the rules that matched among the message's outcomes.
+func recordRules(matches []rules.Match, record func(kind, subject, status, detail string)) {
+	for _, m := range matches {
+		record("rule", m.Rule, "applied", m.Action.String())
+	}
+}
+
+// applyRules forces the urgency the rules ask for. A low priority means not
+// urgent unless a rule says otherwise.
+func applyRules(result *classifier.ClassificationResult, d *rules.Decision) {
+	switch {
+	case d.Urgent != nil:
+		result.IsUrgent = *d.Urgent
+	case d.Priority == rules.PriorityLow:
+		result.IsUrgent = false
+	}
+}
+
+// notifier returns the route the rules picked, or the default notifier.
+func (p *pipeline) notifier(d *rules.Decision) notifier.Notifier {
+	if d.Notify == "" {
+		return p.notify
+	}
+	if n, ok := p.routes[d.Notify]; ok {
+		return n
+	}
+	slog.Warn("Unknown notifier route, using the default", "route", d.Notify)
+	return p.notify
+}
+
+// skip records a message that won't be classified further or notified about,
+// releasing its attachments.
+func (p *pipeline) skip(msg *message.Message, result *classifier.ClassificationResult, outcomes []store.Outcome) {
+	for i := range msg.Attachments {
+		msg.Attachments[i].Data = nil
+	}
+	p.st.AddProcessedMessage(store.ProcessedMessage{
+		Message:        msg,
+		Classification: result,
+		Skipped:        true,
+		Outcomes:       outcomes,
+		ProcessedAt:    time.Now(),
+	})
+}
+
+// hasActionItem reports whether result already contains an action item with
+// the same title and time.
+func hasActionItem(result *classifier.ClassificationResult, item classifier.ActionItem) bool {
+	for _, existing := range result.ActionItems {
+		if existing.Title == item.Title && existing.DateTime.Equal(item.DateTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// enrich converts downloaded attachments into text: voice notes are
+// transcribed and images described into the message text. Events read from
+// images, dated or not, are returned so they can join the classifier's
+// action items. Attachment data is released afterwards so the store doesn't
+// retain media.
+func (p *pipeline) enrich(ctx context.Context, msg *message.Message) []classifier.ActionItem {
+	caption := truncateRunes(msg.Text, 500)
+
+	var items []classifier.ActionItem
+	for i := range msg.Attachments {
+		a := &msg.Attachments[i]
+		switch {
+		case a.Kind == message.AttachmentAudio && p.transcribe != nil:
+			p.transcribeAudio(ctx, msg, a)
+		case a.Kind == message.AttachmentImage && p.describe != nil:
+			items = append(items, p.describeImage(ctx, msg, a, caption)...)
+		}
+		a.Data = nil
+	}
+
+	if msg.Text == "" {
+		msg.Text = fmt.Sprintf("[%s message]", msg.Metadata["media_type"])
+	}
+	return items
+}
+
+func (p *pipeline) transcribeAudio(ctx context.Context, msg *message.Message, a *message.Attachment) {
+	// Local models cost nothing, so they keep going once the budget is spent
+	if _, local := p.transcribe.(*transcriber.WhisperCppTranscriber); !local && p.budgetSpent(msg, "transcription") {
+		return
+	}
+	start := time.Now()
+	result, err := p.transcribe.Transcribe(ctx, a.Data, a.FileName)
+	if err != nil {
+		slog.Error("Failed to transcribe audio",
+			"source", msg.Source,
+			"sender", msg.Sender,
+			"error", err)
+		return
+	}
+	p.st.AddUsage(msg.Source, time.Now(), result.Usage)
+	transcript := result.Text
+	if transcript == "" {
+		return
+	}
+
+	slog.Debug("Audio transcribed",
+		"source", msg.Source,
+		"duration", time.Since(start),
+		"transcript_length", len(transcript))
+
+	if msg.Text == "" {
+		msg.Text = transcript
+	} else {
+		msg.Text += "\n\n[Voice transcript] " + transcript
+	}
+	msg.Metadata["transcribed"] = "true"
+}
+
+func (p *pipeline) describeImage(ctx context.Context, msg *message.Message, a *message.Attachment, caption string) []classifier.ActionItem {
+	if p.budgetSpent(msg, "image description") {
+		return nil
+	}
+	// The caption leaves the machine like a classification prompt, so it is
+	// masked the same way
+	red := p.redactor.Session()
+	red.Sender(msg.Sender)
+	start := time.Now()
+	desc, err := p.describe.Describe(ctx, a.Data, a.MimeType, red.Redact(caption))
+	if err != nil {
+		slog.Error("Failed to describe image",
+			"source", msg.Source,
+			"sender", msg.Sender,
+			"error", err)
+		return nil
+	}
+	p.st.AddUsage(msg.Source, time.Now(), desc.Usage)
+	desc.Text = red.Restore(desc.Text)
+	for i := range desc.ActionItems {
+		item := &desc.ActionItems[i]
+		item.Title = red.Restore(item.Title)
+		item.Description = red.Restore(item.Description)
+	}
+	if desc.Text == "" {
+		return desc.ActionItems
+	}
+
+	slog.Debug("Image described",
+		"source", msg.Source,
+		"duration", time.Since(start),
+		"events", len(desc.ActionItems))
+
+	label := "[Image]"
+	if a.FileName != "" {
+		label = fmt.Sprintf("[Image: %s]", a.FileName)
+	}
+	if msg.Text == "" {
+		msg.Text = label + " " + desc.Text
+	} else {
+		msg.Text += "\n\n" + label + " " + desc.Text
+	}
+	msg.Metadata["image_described"] = "true"
+	return desc.ActionItems
+}
+
+// budgetSpent reports whether the LLM budget rules out a paid call for what.
+func (p *pipeline) budgetSpent(msg *message.Message, what string) bool {
+	if p.overBudget == nil {
+		return false
+	}
+	reason := p.overBudget()
+	if reason == "" {
+		return false
+	}
+	slog.Debug("LLM budget exhausted, skipping media", "media", what, "source", msg.Source, "reason", reason)
+	return true
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,464 @@
+package calendar
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// caldavClient talks WebDAV and CalDAV (RFC 4791) to a calendar server.
+type caldavClient struct {
+	http     *http.Client
+	origin   *url.URL // the configured server, the only one credentials go to
+	username string
+	password string
+}
+
+func newCalDAVClient(cfg config.CalDAVConfig) *caldavClient {
+	origin, _ := url.Parse(cfg.URL) // nil if invalid, so nothing gets the password
+	return &caldavClient{
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			// Redirects are followed by hand, since net/http turns a
+			// redirected PROPFIND into a GET
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		origin:   origin,
+		username: cfg.Username,
+		password: cfg.Password,
+	}
+}
+
+// trusted reports whether u is on the configured server, so it may be sent
+// the password.
+func (c *caldavClient) trusted(u *url.URL) bool {
+	return c.origin != nil && u.Scheme == c.origin.Scheme && strings.EqualFold(u.Host, c.origin.Host)
+}
+
+// do sends a request, following redirects with the same method and body.
+// Credentials are only sent to the configured server, and redirects from
+// https to http are refused. The returned URL is where the response came
+// from.
+func (c *caldavClient) do(ctx context.Context, method, target string, header http.Header, body []byte) (*http.Response, *url.URL, error) {
+	for range 5 {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if c.username != "" && c.trusted(req.URL) {
+			req.SetBasicAuth(c.username, c.password)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			loc, err := resp.Location()
+			resp.Body.Close()
+			if err != nil {
+				return nil, nil, fmt.Errorf("bad redirect from %s: %w", target, err)
+			}
+			if req.URL.Scheme == "https" && loc.Scheme != "https" {
+				return nil, nil, fmt.Errorf("refusing redirect from %s to insecure %s", target, loc.Redacted())
+			}
+			target = loc.String()
+			continue
+		case http.StatusUnauthorized:
+			resp.Body.Close()
+			return nil, nil, errors.New("authentication failed, check the username and password")
+		}
+		return resp, req.URL, nil
+	}
+	return nil, nil, fmt.Errorf("too many redirects from %s", target)
+}
+
+// put creates a calendar object named after uid in the collection.
+func (c *caldavClient) put(ctx context.Context, collection, uid string, ics []byte) (string, error) {
+	href := strings.TrimSuffix(collection, "/") + "/" + strings.TrimSuffix(uid, "@notifylm") + ".ics"
+	header := http.Header{
+		"Content-Type":  {"text/calendar; charset=utf-8"},
+		"If-None-Match": {"*"}, // never overwrite
+	}
+	resp, _, err := c.do(ctx, http.MethodPut, href, header, ics)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", statusError(resp)
+	}
+	return href, nil
+}
+
+// davResource is one entry of a PROPFIND response, with the properties the
+// server found.
+type davResource struct {
+	URL          string
+	Calendar     bool // resourcetype includes calendar
+	DisplayName  string
+	Components   []string // supported component types; empty means any
+	Principal    string   // current-user-principal
+	CalendarHome string   // calendar-home-set
+}
+
+// usable reports whether discovery can go on from r.
+func (r davResource) usable() bool {
+	return r.Calendar || r.Principal != "" || r.CalendarHome != ""
+}
+
+// supports reports whether the calendar accepts component, e.g. "VEVENT".
+func (r davResource) supports(component string) bool {
+	return len(r.Components) == 0 || slices.Contains(r.Components, component)
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:resourcetype/>
+    <d:displayname/>
+    <d:current-user-principal/>
+    <c:calendar-home-set/>
+    <c:supported-calendar-component-set/>
+  </d:prop>
+</d:propfind>`
+
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+				} `xml:"DAV: resourcetype"`
+				DisplayName          string   `xml:"DAV: displayname"`
+				CurrentUserPrincipal *davHref `xml:"DAV: current-user-principal"`
+				CalendarHomeSet      *davHref `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+				Components           struct {
+					Comps []struct {
+						Name string `xml:"name,attr"`
+					} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+				} `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+// propfind lists target (depth 0) or its children too (depth 1).
+func (c *caldavClient) propfind(ctx context.Context, target string, depth int) ([]davResource, error) {
+	header := http.Header{
+		"Content-Type": {"application/xml; charset=utf-8"},
+		"Depth":        {fmt.Sprint(depth)},
+	}
+	resp, base, err := c.do(ctx, "PROPFIND", target, header, []byte(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError(resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to parse PROPFIND response: %w", err)
+	}
+
+	resolve := func(href string) string {
+		u, err := base.Parse(href)
+		if err != nil {
+			return href
+		}
+		return u.String()
+	}
+	var resources []davResource
+	for _, r := range ms.Responses {
+		res := davResource{URL: resolve(r.Href)}
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			p := ps.Prop
+			res.Calendar = res.Calendar || p.ResourceType.Calendar != nil
+			if p.DisplayName != "" {
+				res.DisplayName = p.DisplayName
+			}
+			if p.CurrentUserPrincipal != nil && p.CurrentUserPrincipal.Href != "" {
+				res.Principal = resolve(p.CurrentUserPrincipal.Href)
+			}
+			if p.CalendarHomeSet != nil && p.CalendarHomeSet.Href != "" {
+				res.CalendarHome = resolve(p.CalendarHomeSet.Href)
+			}
+			for _, comp := range p.Components.Comps {
+				res.Components = append(res.Components, strings.ToUpper(comp.Name))
+			}
+		}
+		resources = append(resources, res)
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("empty PROPFIND response from %s", target)
+	}
+	return resources, nil
+}
+
+// findCalendar returns the URL of the calendar collection for component,
+// starting from cfg.URL: the calendar itself, or a server whose calendars
+// are found through the current user's principal and calendar home.
+func (c *caldavClient) findCalendar(ctx context.Context, cfg config.CalDAVConfig, component string) (string, error) {
+	start, err := c.propfind(ctx, cfg.URL, 0)
+	if err != nil || !start[0].usable() {
+		// Given just the server, try the well-known address, which
+		// redirects to the CalDAV root on Fastmail, Nextcloud and others
+		if u, perr := url.Parse(cfg.URL); perr == nil && (u.Path == "" || u.Path == "/") {
+			u.Path = "/.well-known/caldav"
+			if wk, wkErr := c.propfind(ctx, u.String(), 0); wkErr == nil {
+				start, err = wk, nil
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	self := start[0]
+	if self.Calendar {
+		return self.URL, nil
+	}
+
+	home := self.CalendarHome
+	if home == "" {
+		if self.Principal == "" {
+			return "", fmt.Errorf("%s is not a calendar and doesn't name the user's principal", cfg.URL)
+		}
+		principal, err := c.propfind(ctx, self.Principal, 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to read principal: %w", err)
+		}
+		if home = principal[0].CalendarHome; home == "" {
+			return "", fmt.Errorf("no calendar home for principal %s", self.Principal)
+		}
+	}
+
+	children, err := c.propfind(ctx, home, 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to list calendars: %w", err)
+	}
+	var names []string
+	for _, r := range children {
+		if !r.Calendar || !r.supports(component) {
+			continue
+		}
+		segment := path.Base(strings.TrimSuffix(r.URL, "/"))
+		if cfg.Calendar == "" || strings.EqualFold(r.DisplayName, cfg.Calendar) || segment == cfg.Calendar {
+			return r.URL, nil
+		}
+		names = append(names, cmp.Or(r.DisplayName, segment))
+	}
+	if cfg.Calendar != "" {
+		return "", fmt.Errorf("no calendar named %q for %s (found: %s)", cfg.Calendar, component, strings.Join(names, ", "))
+	}
+	return "", fmt.Errorf("no calendar accepting %s in %s", component, home)
+}
+
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if msg := strings.TrimSpace(string(body)); msg != "" && !strings.HasPrefix(msg, "<") {
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return errors.New(resp.Status)
+}
+
+// caldavCalendar is a calendar collection, found on first use and kept
+// until an error suggests it moved.
+type caldavCalendar struct {
+	client    *caldavClient
+	cfg       config.CalDAVConfig
+	component string // "VEVENT" or "VTODO"
+
+	mu  sync.Mutex
+	url string
+}
+
+func newCalDAVCalendar(cfg config.CalDAVConfig, component string) (*caldavCalendar, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid CalDAV URL %q", cfg.URL)
+	}
+	return &caldavCalendar{client: newCalDAVClient(cfg), cfg: cfg, component: component}, nil
+}
+
+// discover returns the calendar's URL, finding it if needed.
+func (c *caldavCalendar) discover(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.url != "" {
+		return c.url, nil
+	}
+	u, err := c.client.findCalendar(ctx, c.cfg, c.component)
+	if err != nil {
+		return "", fmt.Errorf("failed to find CalDAV calendar: %w", err)
+	}
+	c.url = u
+	return u, nil
+}
+
+// put stores a calendar object, discovering the calendar again if the
+// server no longer has it.
+func (c *caldavCalendar) put(ctx context.Context, uid string, ics []byte) (string, error) {
+	collection, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	href, err := c.client.put(ctx, collection, uid, ics)
+	if err != nil {
+		c.mu.Lock()
+		c.url = ""
+		c.mu.Unlock()
+	}
+	return href, err
+}
+
+// CalDAVCreator creates events as VEVENTs on a CalDAV server such as
+// Nextcloud, Fastmail, iCloud or Radicale.
+type CalDAVCreator struct {
+	calendar           *caldavCalendar
+	defaultDurationMin int
+}
+
+// NewCalDAVCreator creates a CalDAV event creator. The calendar is looked up
+// on first use; call Discover to check the configuration sooner.
+func NewCalDAVCreator(cfg config.CalendarConfig) (*CalDAVCreator, error) {
+	cal, err := newCalDAVCalendar(cfg.CalDAV, "VEVENT")
+	if err != nil {
+		return nil, err
+	}
+
+	defaultDuration := cfg.DefaultDurationMinutes
+	if defaultDuration <= 0 {
+		defaultDuration = 30
+	}
+
+	return &CalDAVCreator{calendar: cal, defaultDurationMin: defaultDuration}, nil
+}
+
+// Discover finds the calendar events go to and returns its URL.
+func (c *CalDAVCreator) Discover(ctx context.Context) (string, error) {
+	return c.calendar.discover(ctx)
+}
+
+func (c *CalDAVCreator) CreateEvent(ctx context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	duration := item.DurationMinutes
+	if duration <= 0 {
+		duration = c.defaultDurationMin
+	}
+	uid := newUID()
+	now := time.Now()
+
+	var w icalWriter
+	w.raw("BEGIN", "VCALENDAR")
+	w.raw("VERSION", "2.0")
+	w.raw("PRODID", "-//notifylm//EN")
+	w.raw("BEGIN", "VEVENT")
+	w.prop("UID", uid)
+	w.raw("DTSTAMP", icalUTC(now))
+	if item.AllDay {
+		// The end date is exclusive
+		w.raw("DTSTART;VALUE=DATE", item.DateTime.Format("20060102"))
+		w.raw("DTEND;VALUE=DATE", item.DateTime.AddDate(0, 0, 1).Format("20060102"))
+	} else {
+		w.raw("DTSTART", icalUTC(item.DateTime))
+		w.raw("DTEND", icalUTC(item.DateTime.Add(time.Duration(duration)*time.Minute)))
+	}
+	w.prop("SUMMARY", item.Title)
+	w.prop("DESCRIPTION", fmt.Sprintf("Source: %s\nFrom: %s\n\n%s", msg.Source, msg.Sender, item.Description))
+	w.raw("END", "VEVENT")
+	w.raw("END", "VCALENDAR")
+
+	href, err := c.calendar.put(ctx, uid, w.bytes())
+	if err != nil {
+		return fmt.Errorf("failed to create CalDAV event: %w", err)
+	}
+
+	slog.Info("CalDAV event created",
+		"title", item.Title,
+		"start", item.DateTime.Format(time.RFC3339),
+		"all_day", item.AllDay,
+		"href", href)
+
+	return nil
+}
+
+// CalDAVTaskSink saves tasks as VTODOs in a CalDAV task list, e.g. on
+// Nextcloud, Fastmail or Radicale.
+type CalDAVTaskSink struct {
+	calendar *caldavCalendar
+}
+
+// NewCalDAVTaskSink creates a CalDAV task sink. The task list is looked up
+// on first use; call Discover to check the configuration sooner.
+func NewCalDAVTaskSink(cfg config.CalDAVConfig) (*CalDAVTaskSink, error) {
+	cal, err := newCalDAVCalendar(cfg, "VTODO")
+	if err != nil {
+		return nil, err
+	}
+	return &CalDAVTaskSink{calendar: cal}, nil
+}
+
+// Discover finds the task list tasks go to and returns its URL.
+func (c *CalDAVTaskSink) Discover(ctx context.Context) (string, error) {
+	return c.calendar.discover(ctx)
+}
+
+func (c *CalDAVTaskSink) AddTask(ctx context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	uid := newUID()
+	now := time.Now()
+
+	var w icalWriter
+	w.raw("BEGIN", "VCALENDAR")
+	w.raw("VERSION", "2.0")
+	w.raw("PRODID", "-//notifylm//EN")
+	w.raw("BEGIN", "VTODO")
+	w.prop("UID", uid)
+	w.raw("DTSTAMP", icalUTC(now))
+	w.raw("CREATED", icalUTC(now))
+	w.prop("SUMMARY", item.Title)
+	w.prop("DESCRIPTION", fmt.Sprintf("Source: %s\nFrom: %s\n\n%s", msg.Source, msg.Sender, item.Description))
+	w.raw("STATUS", "NEEDS-ACTION")
+	w.raw("END", "VTODO")
+	w.raw("END", "VCALENDAR")
+
+	href, err := c.calendar.put(ctx, uid, w.bytes())
+	if err != nil {
+		return fmt.Errorf("failed to create CalDAV task: %w", err)
+	}
+
+	slog.Info("CalDAV task created", "title", item.Title, "href", href)
+	return nil
+}
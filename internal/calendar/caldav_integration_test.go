@@ -0,0 +1,168 @@
+//go:build integration
+
+package calendar
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// setupCalDAV creates a fresh calendar on the server at CALDAV_TEST_URL,
+// e.g. a local Radicale started with "make radicale", and returns a config
+// that finds it by discovery from the server root.
+func setupCalDAV(t *testing.T) (cfg config.CalDAVConfig, collection string) {
+	t.Helper()
+	base := os.Getenv("CALDAV_TEST_URL")
+	if base == "" {
+		t.Skip("CALDAV_TEST_URL not set, skipping integration test")
+	}
+	cfg = config.CalDAVConfig{
+		URL:      base,
+		Username: cmp.Or(os.Getenv("CALDAV_TEST_USER"), "notifylm"),
+		Password: cmp.Or(os.Getenv("CALDAV_TEST_PASSWORD"), "notifylm"),
+		Calendar: fmt.Sprintf("notifylm-test-%d", time.Now().UnixNano()),
+	}
+	ctx := context.Background()
+	client := newCalDAVClient(cfg)
+
+	// Radicale creates the user's home on first access
+	if _, err := client.propfind(ctx, base, 0); err != nil {
+		t.Fatalf("server not reachable: %v", err)
+	}
+	collection = strings.TrimSuffix(base, "/") + "/" + cfg.Username + "/" + cfg.Calendar + "/"
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:mkcalendar xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:set><d:prop>
+    <d:displayname>` + cfg.Calendar + `</d:displayname>
+    <c:supported-calendar-component-set><c:comp name="VEVENT"/><c:comp name="VTODO"/></c:supported-calendar-component-set>
+  </d:prop></d:set>
+</c:mkcalendar>`
+	resp, _, err := client.do(ctx, "MKCALENDAR", collection, http.Header{"Content-Type": {"application/xml"}}, []byte(body))
+	if err != nil {
+		t.Fatalf("MKCALENDAR failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MKCALENDAR: %s", resp.Status)
+	}
+	t.Cleanup(func() {
+		if resp, _, err := client.do(ctx, http.MethodDelete, collection, nil, nil); err == nil {
+			resp.Body.Close()
+		}
+	})
+	return cfg, collection
+}
+
+// objects returns the calendar objects stored in collection.
+func objects(t *testing.T, cfg config.CalDAVConfig, collection string) []string {
+	t.Helper()
+	ctx := context.Background()
+	client := newCalDAVClient(cfg)
+	resources, err := client.propfind(ctx, collection, 1)
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", collection, err)
+	}
+	var result []string
+	for _, r := range resources {
+		if !strings.HasSuffix(r.URL, ".ics") {
+			continue
+		}
+		resp, _, err := client.do(ctx, http.MethodGet, r.URL, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", r.URL, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		result = append(result, string(body))
+	}
+	return result
+}
+
+func testMessage() *message.Message {
+	return &message.Message{
+		ID:        "integration-test",
+		Source:    message.SourceSlack,
+		Sender:    "test-user",
+		Text:      "Team meeting tomorrow at 2pm, and please review the contract",
+		Timestamp: time.Now(),
+	}
+}
+
+func TestIntegration_CalDAVDiscovery(t *testing.T) {
+	cfg, collection := setupCalDAV(t)
+	creator, err := NewCalDAVCreator(config.CalendarConfig{CalDAV: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := creator.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	if strings.TrimSuffix(found, "/") != strings.TrimSuffix(collection, "/") {
+		t.Errorf("discovered %s, want %s", found, collection)
+	}
+}
+
+func TestIntegration_CalDAVEvents(t *testing.T) {
+	cfg, collection := setupCalDAV(t)
+	creator, err := NewCalDAVCreator(config.CalendarConfig{CalDAV: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	timed := &classifier.ActionItem{Title: "Team meeting", Description: "Weekly sync; bring notes, please", DateTime: start, DurationMinutes: 60}
+	if err := creator.CreateEvent(ctx, timed, testMessage()); err != nil {
+		t.Fatalf("failed to create timed event: %v", err)
+	}
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	allDay := &classifier.ActionItem{Title: "Rent due", DateTime: day, AllDay: true}
+	if err := creator.CreateEvent(ctx, allDay, testMessage()); err != nil {
+		t.Fatalf("failed to create all-day event: %v", err)
+	}
+
+	stored := objects(t, cfg, collection)
+	if len(stored) != 2 {
+		t.Fatalf("expected 2 events, found %d", len(stored))
+	}
+	all := strings.Join(stored, "\n")
+	for _, want := range []string{"SUMMARY:Team meeting", "DTSTART:" + icalUTC(start), "SUMMARY:Rent due", "DTSTART;VALUE=DATE:" + day.Format("20060102")} {
+		if !strings.Contains(all, want) {
+			t.Errorf("stored events lack %q:\n%s", want, all)
+		}
+	}
+}
+
+func TestIntegration_CalDAVTasks(t *testing.T) {
+	cfg, collection := setupCalDAV(t)
+	sink, err := NewCalDAVTaskSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := &classifier.ActionItem{Title: "Review the contract", Description: "Before the board meeting"}
+	if err := sink.AddTask(context.Background(), item, testMessage()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+
+	stored := objects(t, cfg, collection)
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 task, found %d", len(stored))
+	}
+	if !strings.Contains(stored[0], "BEGIN:VTODO") || !strings.Contains(stored[0], "SUMMARY:Review the contract") {
+		t.Errorf("unexpected task:\n%s", stored[0])
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"google.golang.org/api/calendar/v3"
@@ -27,9 +28,10 @@ type GoogleCalendarCreator struct {
 	defaultDurationMin int
 }
 
-// NewGoogleCalendarCreator initializes a Google Calendar event creator.
-func NewGoogleCalendarCreator(ctx context.Context, cfg config.CalendarConfig) (*GoogleCalendarCreator, error) {
-	client, err := googleauth.GetOAuth2Client(ctx, cfg.CredentialsPath, cfg.TokenPath, calendar.CalendarEventsScope)
+// NewGoogleCalendarCreator initializes a Google Calendar event creator. If no
+// token with calendar access is stored yet, it blocks until authz obtains one.
+func NewGoogleCalendarCreator(ctx context.Context, cfg config.CalendarConfig, authz googleauth.Authorizer) (*GoogleCalendarCreator, error) {
+	client, err := googleauth.GetOAuth2Client(ctx, "calendar", cfg.CredentialsPath, cfg.TokenPath, authz, calendar.CalendarEventsScope)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get calendar OAuth2 client: %w", err)
 	}
@@ -78,6 +80,11 @@ func (g *GoogleCalendarCreator) CreateEvent(ctx context.Context, item *classifie
 			DateTime: end.Format(time.RFC3339),
 		},
 	}
+	if item.AllDay {
+		// All-day events end on the following day, exclusive
+		event.Start = &calendar.EventDateTime{Date: start.Format(time.DateOnly)}
+		event.End = &calendar.EventDateTime{Date: start.AddDate(0, 0, 1).Format(time.DateOnly)}
+	}
 
 	created, err := g.service.Events.Insert(g.calendarID, event).Context(ctx).Do()
 	if err != nil {
@@ -87,11 +94,42 @@ func (g *GoogleCalendarCreator) CreateEvent(ctx context.Context, item *classifie
 	slog.Info("Calendar event created",
 		"title", item.Title,
 		"start", start.Format(time.RFC3339),
+		"all_day", item.AllDay,
 		"event_link", created.HtmlLink)
 
 	return nil
 }
 
+// DeferredCreator forwards to an EventCreator that becomes available later,
+// such as a Google Calendar creator waiting for the user to connect their
+// account on the dashboard. Until then CreateEvent fails.
+type DeferredCreator struct {
+	mu      sync.RWMutex
+	creator EventCreator
+}
+
+func NewDeferredCreator() *DeferredCreator {
+	return &DeferredCreator{}
+}
+
+// Set makes creator available.
+func (d *DeferredCreator) Set(creator EventCreator) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.creator = creator
+}
+
+func (d *DeferredCreator) CreateEvent(ctx context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	d.mu.RLock()
+	creator := d.creator
+	d.mu.RUnlock()
+
+	if creator == nil {
+		return fmt.Errorf("calendar is not connected yet")
+	}
+	return creator.CreateEvent(ctx, item, msg)
+}
+
 // MockCalendarCreator logs events instead of creating them.
 type MockCalendarCreator struct{}
 
@@ -105,6 +143,7 @@ func (m *MockCalendarCreator) CreateEvent(_ context.Context, item *classifier.Ac
 		"description", item.Description,
 		"datetime", item.DateTime.Format(time.RFC3339),
 		"duration_minutes", item.DurationMinutes,
+		"all_day", item.AllDay,
 		"source", msg.Source,
 		"sender", msg.Sender)
 	return nil
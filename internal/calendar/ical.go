@@ -0,0 +1,60 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// icalWriter builds an iCalendar (RFC 5545) document.
+type icalWriter struct {
+	b strings.Builder
+}
+
+// prop writes a property, escaping text values and folding long lines.
+func (w *icalWriter) prop(name, value string) {
+	w.line(name + ":" + icalEscape(value))
+}
+
+// raw writes a property whose value needs no escaping, such as a date.
+func (w *icalWriter) raw(name, value string) {
+	w.line(name + ":" + value)
+}
+
+// line folds content lines at 75 octets without splitting a UTF-8 sequence.
+func (w *icalWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	w.b.WriteString(s + "\r\n")
+}
+
+func (w *icalWriter) bytes() []byte {
+	return []byte(w.b.String())
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icalEscape(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// icalUTC formats t as a UTC date-time, e.g. 20250315T140000Z.
+func icalUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// newUID returns a unique identifier for a calendar object.
+func newUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b) + "@notifylm"
+}
@@ -0,0 +1,210 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/tasks/v1"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/googleauth"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// TaskSink saves action items that have no date, which can't go on a
+// calendar, as tasks.
+type TaskSink interface {
+	AddTask(ctx context.Context, item *classifier.ActionItem, msg *message.Message) error
+}
+
+// GoogleTasksSink adds tasks to a Google Tasks list.
+type GoogleTasksSink struct {
+	service *tasks.Service
+	listID  string
+}
+
+// NewGoogleTasksSink initializes a Google Tasks sink. If no token with tasks
+// access is stored yet, it blocks until authz obtains one.
+func NewGoogleTasksSink(ctx context.Context, cfg config.TasksConfig, authz googleauth.Authorizer) (*GoogleTasksSink, error) {
+	client, err := googleauth.GetOAuth2Client(ctx, "tasks", cfg.CredentialsPath, cfg.TokenPath, authz, tasks.TasksScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks OAuth2 client: %w", err)
+	}
+
+	svc, err := tasks.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tasks service: %w", err)
+	}
+
+	listID := cfg.TaskListID
+	if listID == "" {
+		listID = "@default"
+	}
+
+	return &GoogleTasksSink{service: svc, listID: listID}, nil
+}
+
+func (g *GoogleTasksSink) AddTask(ctx context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	task := &tasks.Task{
+		Title: item.Title,
+		Notes: fmt.Sprintf("Source: %s\nFrom: %s\n\n%s", msg.Source, msg.Sender, item.Description),
+	}
+
+	created, err := g.service.Tasks.Insert(g.listID, task).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	slog.Info("Google task created",
+		"title", item.Title,
+		"task_link", created.WebViewLink)
+
+	return nil
+}
+
+// FileTaskSink appends tasks to a local Markdown checklist or todo.txt file.
+type FileTaskSink struct {
+	path     string
+	markdown bool
+
+	mu sync.Mutex
+}
+
+// NewFileTaskSink creates a file sink. The format defaults to Markdown for
+// .md files and todo.txt otherwise.
+func NewFileTaskSink(cfg config.TasksConfig) (*FileTaskSink, error) {
+	path := cfg.Path
+	if path == "" {
+		path = "./data/tasks.md"
+	}
+	markdown := cfg.Format == "markdown"
+	if cfg.Format == "" {
+		ext := strings.ToLower(filepath.Ext(path))
+		markdown = ext == ".md" || ext == ".markdown"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tasks directory: %w", err)
+	}
+	return &FileTaskSink{path: path, markdown: markdown}, nil
+}
+
+// Path returns the file tasks are written to.
+func (f *FileTaskSink) Path() string {
+	return f.path
+}
+
+func (f *FileTaskSink) AddTask(_ context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	var entry string
+	if f.markdown {
+		entry = markdownTask(item, msg, time.Now())
+	} else {
+		entry = todoTxtTask(item, msg, time.Now())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open tasks file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(entry); err != nil {
+		return fmt.Errorf("failed to write task: %w", err)
+	}
+
+	slog.Info("Task saved", "title", item.Title, "path", f.path)
+	return nil
+}
+
+// markdownTask renders an unchecked checklist item such as "- [ ] Review the
+// contract (from Alice via gmail, 2025-03-15)", with the description
+// indented on the line beneath it.
+func markdownTask(item *classifier.ActionItem, msg *message.Message, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "- [ ] %s (from %s via %s, %s)\n",
+		oneLine(item.Title), oneLine(msg.Sender), msg.Source, now.Format(time.DateOnly))
+	if desc := oneLine(item.Description); desc != "" {
+		fmt.Fprintf(&b, "  %s\n", desc)
+	}
+	return b.String()
+}
+
+// todoTxtTask renders a todo.txt line with the creation date, the title and
+// description, the source as a project and the sender as a key:value tag,
+// e.g.
+//
+//	2025-03-15 Review the contract - before the board meeting +gmail from:Alice_Smith
+func todoTxtTask(item *classifier.ActionItem, msg *message.Message, now time.Time) string {
+	sender := strings.Join(strings.Fields(msg.Sender), "_")
+	text := oneLine(item.Title)
+	if desc := oneLine(item.Description); desc != "" {
+		text += " - " + desc
+	}
+	line := fmt.Sprintf("%s %s +%s", now.Format(time.DateOnly), text, msg.Source)
+	if sender != "" {
+		line += " from:" + sender
+	}
+	return line + "\n"
+}
+
+// oneLine collapses whitespace, including newlines, to single spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// DeferredTaskSink forwards to a TaskSink that becomes available later, such
+// as a Google Tasks sink waiting for the user to connect their account on
+// the dashboard. Until then AddTask fails.
+type DeferredTaskSink struct {
+	mu   sync.RWMutex
+	sink TaskSink
+}
+
+func NewDeferredTaskSink() *DeferredTaskSink {
+	return &DeferredTaskSink{}
+}
+
+// Set makes sink available.
+func (d *DeferredTaskSink) Set(sink TaskSink) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sink = sink
+}
+
+func (d *DeferredTaskSink) AddTask(ctx context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	d.mu.RLock()
+	sink := d.sink
+	d.mu.RUnlock()
+
+	if sink == nil {
+		return fmt.Errorf("tasks are not connected yet")
+	}
+	return sink.AddTask(ctx, item, msg)
+}
+
+// MockTaskSink logs tasks instead of saving them.
+type MockTaskSink struct{}
+
+func NewMockTaskSink() *MockTaskSink {
+	return &MockTaskSink{}
+}
+
+func (m *MockTaskSink) AddTask(_ context.Context, item *classifier.ActionItem, msg *message.Message) error {
+	slog.Info("MOCK TASK",
+		"title", item.Title,
+		"description", item.Description,
+		"source", msg.Source,
+		"sender", msg.Sender)
+	return nil
+}
@@ -0,0 +1,266 @@
+package classifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// Trace methods for results reused from the cache.
+const (
+	MethodCache   = "cache"   // identical text
+	MethodSimilar = "similar" // near-duplicate text
+)
+
+// Cache reuses LLM classifications for messages that repeat one seen
+// recently from the same sender in the same channel or chat, such as a
+// recurring alert or a reminder sent again. The channel is part of the
+// match because the prompt includes it, so the same text cross-posted
+// elsewhere is classified again. Text is compared after normalizing case and
+// whitespace, and near-duplicates are found by SimHash distance.
+//
+// Only results without action items are kept, since those depend on when the
+// message was sent, and messages whose numbers differ never match, so a new
+// time or amount is always classified.
+type Cache struct {
+	inner Classifier
+
+	ttl         time.Duration
+	minLength   int
+	maxEntries  int
+	maxDistance int // -1 if only identical text matches
+
+	mu      sync.Mutex
+	entries map[[32]byte][]*cacheEntry // by scope, see scopeKey
+	count   int
+}
+
+type cacheEntry struct {
+	text    [32]byte // hash of the normalized text
+	simhash uint64
+	numbers string
+	result  ClassificationResult // without a trace
+	model   string
+	at      time.Time // message time, for the trace note
+	expires time.Time
+}
+
+// NewCache wraps inner so repeated messages reuse its earlier results.
+func NewCache(inner Classifier, cfg config.CacheConfig) *Cache {
+	c := &Cache{
+		inner:       inner,
+		ttl:         time.Duration(cfg.TTLMinutes) * time.Minute,
+		minLength:   cfg.MinLength,
+		maxEntries:  cfg.MaxEntries,
+		maxDistance: cfg.SimilarityBits,
+		entries:     make(map[[32]byte][]*cacheEntry),
+	}
+	if c.ttl <= 0 {
+		c.ttl = 6 * time.Hour
+	}
+	if c.minLength <= 0 {
+		c.minLength = 40
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = 1000
+	}
+	if c.maxDistance == 0 {
+		c.maxDistance = 3
+	} else if c.maxDistance < 0 {
+		c.maxDistance = -1
+	}
+	return c
+}
+
+// ClassifyMessage returns a cached result for a repeated message, or asks the
+// wrapped classifier and remembers its answer.
+func (c *Cache) ClassifyMessage(ctx context.Context, msg *message.Message) (*ClassificationResult, error) {
+	text := normalizeText(msg.Text)
+	if len([]rune(text)) < c.minLength {
+		// Short replies mean little without their conversation
+		return c.inner.ClassifyMessage(ctx, msg)
+	}
+
+	scope := scopeKey(msg)
+	textHash := sha256.Sum256([]byte(text))
+	simhash := simHash(text)
+	numbers := numbersIn(text)
+
+	if result := c.lookup(scope, textHash, simhash, numbers); result != nil {
+		slog.Debug("Reusing cached classification",
+			"source", msg.Source,
+			"sender", msg.Sender,
+			"method", result.Trace.Method)
+		return result, nil
+	}
+
+	result, err := c.inner.ClassifyMessage(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if result.Trace != nil && result.Trace.Method == "llm" && len(result.ActionItems) == 0 {
+		c.store(scope, &cacheEntry{
+			text:    textHash,
+			simhash: simhash,
+			numbers: numbers,
+			result:  ClassificationResult{IsUrgent: result.IsUrgent},
+			model:   result.Trace.Model,
+			at:      msg.Timestamp,
+			expires: time.Now().Add(c.ttl),
+		})
+	}
+	return result, nil
+}
+
+// Forget drops the cached results for messages like msg, so a corrected
+// classification isn't repeated.
+func (c *Cache) Forget(msg *message.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scope := scopeKey(msg)
+	c.count -= len(c.entries[scope])
+	delete(c.entries, scope)
+}
+
+func (c *Cache) lookup(scope, textHash [32]byte, simhash uint64, numbers string) *ClassificationResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var best *cacheEntry
+	identical := false
+	bestDistance := c.maxDistance + 1
+	for _, e := range c.entries[scope] {
+		if now.After(e.expires) || e.numbers != numbers {
+			continue
+		}
+		if e.text == textHash {
+			best, identical = e, true
+			break
+		}
+		if d := bits.OnesCount64(e.simhash ^ simhash); d < bestDistance {
+			best, bestDistance = e, d
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	result := best.result
+	result.Trace = &Trace{Method: MethodCache, Model: best.model}
+	what := "an identical"
+	if !identical {
+		result.Trace.Method = MethodSimilar
+		what = "a similar"
+	}
+	result.Trace.Note = fmt.Sprintf("reused the result for %s message from %s", what, best.at.Format("Jan 2 15:04"))
+	return &result
+}
+
+func (c *Cache) store(scope [32]byte, e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[scope] = append(c.entries[scope], e)
+	c.count++
+}
+
+// evict drops expired entries, and the oldest one if the cache is still
+// full. Entries are appended in time order, so each scope's first entry is
+// its oldest. Must be called with c.mu held.
+func (c *Cache) evict() {
+	now := time.Now()
+	var oldest [32]byte
+	var oldestExpires time.Time
+	for scope, list := range c.entries {
+		i := 0
+		for i < len(list) && now.After(list[i].expires) {
+			i++
+		}
+		c.count -= i
+		if list = list[i:]; len(list) == 0 {
+			delete(c.entries, scope)
+			continue
+		}
+		c.entries[scope] = list
+		if oldestExpires.IsZero() || list[0].expires.Before(oldestExpires) {
+			oldest, oldestExpires = scope, list[0].expires
+		}
+	}
+	if c.count >= c.maxEntries && !oldestExpires.IsZero() {
+		if list := c.entries[oldest][1:]; len(list) > 0 {
+			c.entries[oldest] = list
+		} else {
+			delete(c.entries, oldest)
+		}
+		c.count--
+	}
+}
+
+// scopeKey identifies what besides the text the classifier sees: the
+// source, the sender and the metadata included in the prompt.
+func scopeKey(msg *message.Message) [32]byte {
+	return sha256.Sum256([]byte(string(msg.Source) + "\x00" + msg.Sender + "\x00" + messageContext(msg)))
+}
+
+// normalizeText lowercases text and collapses runs of whitespace.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// simHash fingerprints text from its words and word pairs, so texts that
+// differ in a word or two are a few bits apart.
+func simHash(text string) uint64 {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	for i, w := range words {
+		add(w)
+		if i > 0 {
+			add(words[i-1] + " " + w)
+		}
+	}
+
+	var fp uint64
+	for i, w := range weights {
+		if w > 0 {
+			fp |= 1 << i
+		}
+	}
+	return fp
+}
+
+// numbersIn returns the digit runs in text, which must agree for two
+// messages to be treated as the same.
+func numbersIn(text string) string {
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,170 @@
+package classifier
+
+import (
+	"context"
+	"math/bits"
+	"testing"
+
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// countingClassifier answers like the LLM and counts the calls it gets.
+type countingClassifier struct {
+	calls  int
+	result ClassificationResult
+}
+
+func (c *countingClassifier) ClassifyMessage(_ context.Context, _ *message.Message) (*ClassificationResult, error) {
+	c.calls++
+	result := c.result
+	result.Trace = &Trace{Method: "llm", Model: "test-model"}
+	return &result, nil
+}
+
+func cacheMessage(text string) *message.Message {
+	msg := message.NewMessage(message.SourceSlack, "monitor", text)
+	msg.Metadata["channel_name"] = "alerts"
+	return msg
+}
+
+const alert = "Disk usage on the database server is above the warning level, please take a look"
+
+func TestCacheLookup(t *testing.T) {
+	similar := "Disk usage on the database server is above the warning level, please take a look soon"
+	distance := bits.OnesCount64(simHash(normalizeText(alert)) ^ simHash(normalizeText(similar)))
+	if distance == 0 {
+		t.Fatal("the near-duplicate has the same SimHash; pick texts that differ")
+	}
+
+	tests := []struct {
+		name       string
+		bits       int
+		second     *message.Message
+		wantMethod string // "" if the second message goes to the LLM
+	}{
+		{"identical", 3, cacheMessage(alert), MethodCache},
+		{"case and spacing", 3, cacheMessage("DISK usage on the database   server is above the warning level, please take a look"), MethodCache},
+		{"near-duplicate within the threshold", distance, cacheMessage(similar), MethodSimilar},
+		{"near-duplicate beyond the threshold", distance - 1, cacheMessage(similar), ""},
+		{"only identical text", -1, cacheMessage(similar), ""},
+		{"different text", 3, cacheMessage("The quarterly planning meeting moved to the large room on the second floor"), ""},
+		{"different numbers", 64, cacheMessage("Disk usage on the database server is above the warning level 2, please take a look"), ""},
+		{"other channel", 3, func() *message.Message {
+			msg := cacheMessage(alert)
+			msg.Metadata["channel_name"] = "general"
+			return msg
+		}(), ""},
+		{"other sender", 3, message.NewMessage(message.SourceSlack, "someone", alert), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingClassifier{result: ClassificationResult{IsUrgent: true}}
+			c := NewCache(inner, config.CacheConfig{SimilarityBits: tt.bits})
+			if _, err := c.ClassifyMessage(context.Background(), cacheMessage(alert)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.ClassifyMessage(context.Background(), tt.second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMethod == "" {
+				if inner.calls != 2 {
+					t.Errorf("the second message was answered from the cache (%s)", got.Trace.Method)
+				}
+				return
+			}
+			if inner.calls != 1 {
+				t.Fatalf("the second message went to the LLM, want a %q hit", tt.wantMethod)
+			}
+			if got.Trace.Method != tt.wantMethod || got.Trace.Model != "test-model" || !got.IsUrgent {
+				t.Errorf("cached result %+v with trace %+v", got, got.Trace)
+			}
+		})
+	}
+}
+
+func TestCacheSkips(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ClassificationResult
+		text   string
+	}{
+		{"action items", ClassificationResult{ActionItems: []ActionItem{{Title: "Check the disk"}}}, alert},
+		{"short text", ClassificationResult{IsUrgent: true}, "Disk full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingClassifier{result: tt.result}
+			c := NewCache(inner, config.CacheConfig{})
+			for range 2 {
+				if _, err := c.ClassifyMessage(context.Background(), cacheMessage(tt.text)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if inner.calls != 2 {
+				t.Errorf("the LLM was asked %d times, want 2", inner.calls)
+			}
+		})
+	}
+}
+
+func TestCacheForget(t *testing.T) {
+	inner := &countingClassifier{result: ClassificationResult{IsUrgent: true}}
+	c := NewCache(inner, config.CacheConfig{})
+	ctx := context.Background()
+
+	other := message.NewMessage(message.SourceSlack, "someone", alert)
+	for _, msg := range []*message.Message{cacheMessage(alert), other} {
+		if _, err := c.ClassifyMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Feedback on one message forgets its scope only
+	c.Forget(cacheMessage(alert))
+	if _, err := c.ClassifyMessage(ctx, cacheMessage(alert)); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("after Forget the LLM was asked %d times, want 3", inner.calls)
+	}
+	if _, err := c.ClassifyMessage(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 3 {
+		t.Error("Forget dropped another sender's result")
+	}
+	if c.count != 2 {
+		t.Errorf("cache counts %d entries, want 2", c.count)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	inner := &countingClassifier{}
+	c := NewCache(inner, config.CacheConfig{MaxEntries: 2, SimilarityBits: -1})
+	ctx := context.Background()
+	texts := []string{
+		alert,
+		"The quarterly planning meeting moved to the large room on the second floor",
+		"Reminder that the office is closed on Friday for the building maintenance work",
+	}
+	for _, text := range texts {
+		if _, err := c.ClassifyMessage(ctx, cacheMessage(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.count != 2 {
+		t.Errorf("cache holds %d entries, want 2", c.count)
+	}
+	// The oldest entry made room for the newest
+	if _, err := c.ClassifyMessage(ctx, cacheMessage(texts[2])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ClassifyMessage(ctx, cacheMessage(texts[0])); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 4 {
+		t.Errorf("the LLM was asked %d times, want 4", inner.calls)
+	}
+}
@@ -3,31 +3,61 @@ package classifier
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
-
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
-	"github.com/openai/openai-go/shared"
+	"unicode/utf8"
 
 	"github.com/emirlan/notifylm/internal/config"
 	"github.com/emirlan/notifylm/internal/message"
+	"github.com/emirlan/notifylm/internal/redact"
 )
 
 // ActionItem represents a detected action item with an optional date/time.
+// Items without one are tasks rather than calendar events.
 type ActionItem struct {
 	Title           string
 	Description     string
 	DateTime        time.Time
 	DurationMinutes int
+	AllDay          bool // DateTime is midnight of a day with no set time
 }
 
 // ClassificationResult holds the outcome of classifying a message.
 type ClassificationResult struct {
 	IsUrgent    bool
 	ActionItems []ActionItem
+	Trace       *Trace // how the result was reached; nil if not recorded
+}
+
+// Trace records the exchange behind a classification so it can be inspected
+// on the dashboard.
+type Trace struct {
+	Method       string // "llm", "keyword", "rule", "cache", "similar", or "fallback" when the LLM reply wasn't valid JSON
+	Model        string
+	SystemPrompt string
+	UserPrompt   string
+	RawResponse  string
+	FinishReason string
+	Latency      time.Duration
+	Note         string   // e.g. the keyword that matched or why parsing failed
+	Fallbacks    []string // models tried first that failed, and why
+	Redacted     int      // personal data values masked in the prompts
+
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // estimated, in US dollars
+}
+
+// Usage returns the LLM usage the trace records, if any.
+func (t *Trace) Usage() Usage {
+	if t == nil || t.PromptTokens+t.CompletionTokens == 0 {
+		return Usage{}
+	}
+	return Usage{Calls: 1, PromptTokens: t.PromptTokens, CompletionTokens: t.CompletionTokens, Cost: t.Cost}
 }
 
 // Classifier determines if messages are urgent/important and extracts action items.
@@ -35,23 +65,114 @@ type Classifier interface {
 	ClassifyMessage(ctx context.Context, msg *message.Message) (*ClassificationResult, error)
 }
 
+// Example is a past classification the user corrected, shown to the LLM so
+// it handles similar messages better.
+type Example struct {
+	Source     message.Source
+	Sender     string
+	Text       string
+	Correction string // e.g. "should have been urgent"
+}
+
+// ExampleSource supplies corrected examples relevant to a message.
+type ExampleSource interface {
+	Examples(msg *message.Message, limit int) []Example
+}
+
+// HistorySource supplies the messages that preceded one in its conversation.
+type HistorySource interface {
+	Conversation(msg *message.Message, limit int) []*message.Message
+}
+
 // LLMClassifier uses an LLM to classify message urgency and extract action items.
 type LLMClassifier struct {
-	cfg    config.LLMConfig
-	client openai.Client
-	hasLLM bool
+	cfg        config.LLMConfig
+	backends   []*backend // the fallback chain, primary model first
+	timeout    time.Duration
+	maxRetries int
+
+	examples    ExampleSource // nil if feedback is disabled
+	maxExamples int
+
+	history       HistorySource // nil if conversation context is disabled
+	historyLimit  int
+	historyTokens int
+
+	budget *budget // nil if spending is unlimited
+
+	redactor *redact.Redactor // nil if prompts are sent as they are
 }
 
-// NewLLMClassifier creates a new LLM-based classifier.
+// NewLLMClassifier creates a new LLM-based classifier. Models are tried in
+// the order configured, and keywords are used when none is available.
 func NewLLMClassifier(cfg config.LLMConfig) *LLMClassifier {
-	c := &LLMClassifier{cfg: cfg}
-	if cfg.Provider == "openai" && cfg.APIKey != "" {
-		c.client = openai.NewClient(option.WithAPIKey(cfg.APIKey))
-		c.hasLLM = true
+	c := &LLMClassifier{
+		cfg:        cfg,
+		timeout:    time.Duration(cfg.TimeoutSeconds) * time.Second,
+		maxRetries: cfg.MaxRetries,
+	}
+	if c.timeout <= 0 {
+		c.timeout = 30 * time.Second
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = 2
+	} else if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	failures := cfg.BreakerFailures
+	if failures <= 0 {
+		failures = 5
+	}
+	cooldown := time.Duration(cfg.BreakerCooldownSeconds) * time.Second
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+
+	models := append([]config.LLMModelConfig{{
+		Provider: cfg.Provider,
+		APIKey:   cfg.APIKey,
+		Model:    cfg.Model,
+		BaseURL:  cfg.BaseURL,
+	}}, cfg.Fallbacks...)
+	for _, m := range models {
+		if b := newBackend(m, failures, cooldown); b != nil {
+			c.backends = append(c.backends, b)
+		}
 	}
 	return c
 }
 
+// UseExamples adds up to max of the user's corrections to the system prompt
+// as few-shot examples. max defaults to 5.
+func (c *LLMClassifier) UseExamples(src ExampleSource, max int) {
+	if max <= 0 {
+		max = 5
+	}
+	c.examples = src
+	c.maxExamples = max
+}
+
+// UseHistory adds up to limit earlier messages from the same conversation to
+// the prompt, newest first until about maxTokens are used. limit defaults to
+// 10 and maxTokens to 1000.
+func (c *LLMClassifier) UseHistory(src HistorySource, limit, maxTokens int) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if maxTokens <= 0 {
+		maxTokens = 1000
+	}
+	c.history = src
+	c.historyLimit = limit
+	c.historyTokens = maxTokens
+}
+
+// UseRedaction masks personal data in prompts with r, restoring the values in
+// the action items that come back.
+func (c *LLMClassifier) UseRedaction(r *redact.Redactor) {
+	c.redactor = r
+}
+
 // ClassifyMessage sends the message to an LLM for classification.
 func (c *LLMClassifier) ClassifyMessage(ctx context.Context, msg *message.Message) (*ClassificationResult, error) {
 	slog.Debug("Classifying message",
@@ -59,8 +180,22 @@ func (c *LLMClassifier) ClassifyMessage(ctx context.Context, msg *message.Messag
 		"sender", msg.Sender,
 		"text_preview", truncate(msg.Text, 50))
 
-	if c.hasLLM {
-		return c.callOpenAI(ctx, msg)
+	if len(c.backends) > 0 {
+		reason := c.budget.check(time.Now())
+		if reason != "" {
+			result := c.keywordClassify(msg)
+			result.Trace.Note = "LLM budget exhausted: " + reason + "; " + result.Trace.Note
+			return result, nil
+		}
+
+		result, err := c.callOpenAI(ctx, msg)
+		if err == nil || ctx.Err() != nil {
+			return result, err
+		}
+		slog.Warn("No LLM could classify the message, falling back to keywords", "error", err)
+		result = c.keywordClassify(msg)
+		result.Trace.Note = "no LLM available (" + err.Error() + "); " + result.Trace.Note
+		return result, nil
 	}
 
 	return c.keywordClassify(msg), nil
@@ -79,49 +214,83 @@ type llmActionItem struct {
 	DurationMinutes int    `json:"duration_minutes"`
 }
 
+// callOpenAI asks each model in the chain in turn until one answers.
 func (c *LLMClassifier) callOpenAI(ctx context.Context, msg *message.Message) (*ClassificationResult, error) {
-	model := c.cfg.Model
-	if model == "" {
-		model = "gpt-5-nano"
-	}
-
 	systemPrompt := `You are a message analysis assistant. Analyze the message and return a JSON object with two fields:
 
 1. "urgent" (boolean): true if the message requires immediate attention.
    Urgent criteria: emergencies, safety concerns, immediate deadlines, financial/security alerts, health concerns, explicit urgency (ASAP, urgent, critical).
    Not urgent: general conversation, marketing, newsletters, routine updates.
+   A "Contact priority: high" or "Priority: high" line means the user considers this message's sender or chat important; lean towards urgent.
 
-2. "action_items" (array): extract any action items that have a specific date or deadline. Each item has:
+2. "action_items" (array): extract things the message asks the user to do or attend, such as meetings, deadlines, bills or requests like "please review the contract". Each item has:
    - "title": short summary of the action
    - "description": fuller context
-   - "datetime": ISO 8601 / RFC 3339 datetime string (e.g. "2025-03-15T14:00:00Z"). Only include if a specific date/time is mentioned or can be inferred.
+   - "datetime": when it happens or is due, in the user's timezone without an offset: "2025-03-15T14:00" for a set time, or just the date "2025-03-15" for a whole day or a deadline without a time. Resolve relative dates ("tomorrow", "next Friday") from the message time. Leave it out if no date is mentioned or can be inferred; the item is then kept as a task.
    - "duration_minutes": estimated duration in minutes (default 30 if unclear)
 
-   If there are no action items with dates, return an empty array.
+   If there are no action items, return an empty array.
+
+Earlier messages from the same conversation may be included for context. Use them to understand the new message (e.g. what "yes, tomorrow at 5" agrees to), but classify only the new message and don't extract action items that were already settled earlier.
 
 Respond with ONLY valid JSON, no markdown fences or extra text. Example:
-{"urgent": false, "action_items": [{"title": "Team meeting", "description": "Weekly sync with engineering", "datetime": "2025-03-15T14:00:00Z", "duration_minutes": 60}]}`
+{"urgent": false, "action_items": [{"title": "Team meeting", "description": "Weekly sync with engineering", "datetime": "2025-03-15T14:00", "duration_minutes": 60}]}`
+
+	// Senders are collected first so every mention of them is masked
+	red := c.redactor.Session()
+	red.Sender(msg.Sender)
+	examples := c.fewShot(msg, red)
+	history := c.conversation(msg, red)
+
+	systemPrompt += red.Redact(examples)
 
-	userPrompt := fmt.Sprintf("Source: %s\nFrom: %s\nTime: %s\n\nMessage:\n%s",
+	sent := messageTime(msg)
+	userPrompt := red.Redact(fmt.Sprintf("Source: %s\nFrom: %s\n%s%s\n%sMessage:\n%s",
 		msg.Source,
 		msg.Sender,
-		msg.Timestamp.Format(time.RFC3339),
+		timeContext(sent, time.Now()),
+		messageContext(msg),
+		history,
 		msg.Text,
-	)
-
-	resp, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model: model,
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(systemPrompt),
-			openai.UserMessage(userPrompt),
-		},
-		MaxCompletionTokens: openai.Int(4096),
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONObject: &shared.ResponseFormatJSONObjectParam{
-				Type: "json_object",
-			},
-		},
-	})
+	))
+	if red.Count() > 0 {
+		systemPrompt += "\n\nPersonal data has been replaced by placeholders such as [EMAIL_1] or [PHONE_2]. Copy them unchanged into action items where the value matters."
+	}
+
+	var failed []string
+	for _, b := range c.backends {
+		if !b.breaker.allow(time.Now()) {
+			failed = append(failed, b.name+": skipped while failing")
+			continue
+		}
+		result, err := c.classifyWith(ctx, b, systemPrompt, userPrompt, sent)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if b.breaker.record(err, time.Now()) {
+			slog.Warn("LLM keeps failing, skipping it for a while", "model", b.name, "cooldown", b.breaker.cooldown)
+		}
+		if err != nil {
+			slog.Warn("LLM call failed", "model", b.name, "error", err)
+			failed = append(failed, b.name+": "+err.Error())
+			continue
+		}
+		result.Trace.Fallbacks = failed
+		result.Trace.Redacted = red.Count()
+		for i := range result.ActionItems {
+			item := &result.ActionItems[i]
+			item.Title = red.Restore(item.Title)
+			item.Description = red.Restore(item.Description)
+		}
+		return result, nil
+	}
+	return nil, errors.New(strings.Join(failed, "; "))
+}
+
+// classifyWith sends the prompts to one model and parses its reply,
+// resolving relative dates from sent.
+func (c *LLMClassifier) classifyWith(ctx context.Context, b *backend, systemPrompt, userPrompt string, sent time.Time) (*ClassificationResult, error) {
+	resp, latency, err := c.complete(ctx, b, systemPrompt, userPrompt)
 	if err != nil {
 		return nil, fmt.Errorf("OpenAI API error: %w", err)
 	}
@@ -141,24 +310,160 @@ Respond with ONLY valid JSON, no markdown fences or extra text. Example:
 		"content", content,
 		"refusal", resp.Choices[0].Message.Refusal)
 
+	trace := &Trace{
+		Method:       "llm",
+		Model:        b.name,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		RawResponse:  content,
+		FinishReason: string(resp.Choices[0].FinishReason),
+		Latency:      latency,
+
+		PromptTokens:     int(resp.Usage.PromptTokens),
+		CompletionTokens: int(resp.Usage.CompletionTokens),
+	}
+	trace.Cost = c.cost(b.model, trace.PromptTokens, trace.CompletionTokens)
+
 	// Try to parse as JSON
-	result, err := parseJSONResponse(content)
+	result, err := parseJSONResponse(content, sent)
 	if err != nil {
 		slog.Warn("Failed to parse LLM JSON response, falling back to string matching",
 			"error", err,
 			"content", content)
-		return fallbackStringMatch(content), nil
+		result = fallbackStringMatch(content)
+		trace.Method = "fallback"
+		trace.Note = err.Error()
+		result.Trace = trace
+		return result, nil
 	}
+	result.Trace = trace
 
 	slog.Info("OpenAI classification result",
 		"is_urgent", result.IsUrgent,
 		"action_items", len(result.ActionItems),
-		"model", model)
+		"model", b.name,
+		"tokens", trace.PromptTokens+trace.CompletionTokens)
 
 	return result, nil
 }
 
-func parseJSONResponse(content string) (*ClassificationResult, error) {
+// historyLineRunes bounds each earlier message quoted for context.
+const historyLineRunes = 500
+
+// conversation renders the messages before msg in its conversation, oldest
+// first. The newest are kept when the token budget runs out.
+func (c *LLMClassifier) conversation(msg *message.Message, red *redact.Session) string {
+	if c.history == nil {
+		return ""
+	}
+	earlier := c.history.Conversation(msg, c.historyLimit)
+	if len(earlier) == 0 {
+		return ""
+	}
+
+	var lines []string
+	budget := c.historyTokens
+	for i := len(earlier) - 1; i >= 0; i-- {
+		m := earlier[i]
+		text := strings.Join(strings.Fields(m.Text), " ")
+		if r := []rune(text); len(r) > historyLineRunes {
+			text = string(r[:historyLineRunes]) + "..."
+		}
+		red.Sender(m.Sender)
+		line := fmt.Sprintf("[%s] %s: %s", m.Timestamp.Format("Jan 2 15:04"), m.Sender, text)
+		if budget -= estimateTokens(line); budget < 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	slices.Reverse(lines)
+	return "Earlier in this conversation (oldest first):\n" + strings.Join(lines, "\n") + "\n\n"
+}
+
+// estimateTokens approximates the token count of s at four characters per
+// token, which is close enough for budgeting.
+func estimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// fewShot renders the user's relevant corrections for the system prompt.
+func (c *LLMClassifier) fewShot(msg *message.Message, red *redact.Session) string {
+	if c.examples == nil {
+		return ""
+	}
+	examples := c.examples.Examples(msg, c.maxExamples)
+	if len(examples) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\nThe user corrected these earlier classifications. Apply the same judgement to similar messages:\n")
+	for _, ex := range examples {
+		red.Sender(ex.Sender)
+		fmt.Fprintf(&b, "- From %s via %s: %q -> %s\n", ex.Sender, ex.Source, truncate(ex.Text, 200), ex.Correction)
+	}
+	return b.String()
+}
+
+// contextFields lists the message metadata passed to the LLM, with the label
+// used in the prompt.
+var contextFields = []struct{ key, label string }{
+	{"account", "Account"},
+	{"contact", "Known contact"},
+	{"contact_priority", "Contact priority"},
+	{"priority", "Priority"},
+	{"channel_name", "Channel"},
+	{"chat_title", "Chat"},
+	{"is_dm", "Direct message"},
+	{"mentioned", "Mentions me"},
+	{"edited", "Edited"},
+	{"files", "Files"},
+	{"quoted_text", "In reply to"},
+	{"transcribed", "Transcribed voice note"},
+	{"image_described", "Image described"},
+}
+
+// messageTime returns when msg was sent in the user's timezone, or now if
+// the source didn't say.
+func messageTime(msg *message.Message) time.Time {
+	if msg.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return msg.Timestamp.Local()
+}
+
+// timeContext tells the LLM when the message was sent, today's date and the
+// user's timezone, so relative dates resolve to the right day.
+func timeContext(sent, now time.Time) string {
+	now = now.Local()
+	zone := time.Local.String()
+	if zone == "Local" {
+		zone, _ = now.Zone()
+	}
+	return fmt.Sprintf("Time: %s\nToday: %s\nTimezone: %s (UTC%s)\n",
+		sent.Format("Monday, 2006-01-02 15:04"),
+		now.Format("Monday, 2006-01-02"),
+		zone, now.Format("-07:00"))
+}
+
+// messageContext renders the metadata that helps the LLM judge a message, one
+// "Label: value" line per field that is set.
+func messageContext(msg *message.Message) string {
+	var b strings.Builder
+	for _, f := range contextFields {
+		if v := msg.Metadata[f.key]; v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", f.label, v)
+		}
+	}
+	return b.String()
+}
+
+// parseJSONResponse reads the LLM's reply. Dates without an offset are in
+// ref's location, and relative ones count from ref.
+func parseJSONResponse(content string, ref time.Time) (*ClassificationResult, error) {
 	// Strip markdown code fences if present
 	content = strings.TrimPrefix(content, "```json")
 	content = strings.TrimPrefix(content, "```")
@@ -175,6 +480,9 @@ func parseJSONResponse(content string) (*ClassificationResult, error) {
 	}
 
 	for _, item := range resp.ActionItems {
+		if strings.TrimSpace(item.Title) == "" {
+			continue
+		}
 		ai := ActionItem{
 			Title:           item.Title,
 			Description:     item.Description,
@@ -183,18 +491,17 @@ func parseJSONResponse(content string) (*ClassificationResult, error) {
 		if ai.DurationMinutes <= 0 {
 			ai.DurationMinutes = 30
 		}
+		// Items without a date are kept as tasks
 		if item.Datetime != "" {
-			t, err := time.Parse(time.RFC3339, item.Datetime)
+			t, allDay, err := ParseDateTime(item.Datetime, ref)
 			if err != nil {
-				slog.Warn("Failed to parse action item datetime",
+				slog.Warn("Failed to parse action item datetime, keeping it as a task",
 					"datetime", item.Datetime,
 					"error", err)
-				continue
+			} else {
+				ai.DateTime = t
+				ai.AllDay = allDay
 			}
-			ai.DateTime = t
-		} else {
-			// Skip action items without a datetime
-			continue
 		}
 		result.ActionItems = append(result.ActionItems, ai)
 	}
@@ -229,11 +536,17 @@ func (c *LLMClassifier) keywordClassify(msg *message.Message) *ClassificationRes
 		if strings.Contains(text, keyword) {
 			slog.Info("Message classified as URGENT (keyword)",
 				"keyword_matched", keyword)
-			return &ClassificationResult{IsUrgent: true}
+			return &ClassificationResult{
+				IsUrgent: true,
+				Trace:    &Trace{Method: "keyword", Note: fmt.Sprintf("matched keyword %q", keyword)},
+			}
 		}
 	}
 
-	return &ClassificationResult{IsUrgent: false}
+	return &ClassificationResult{
+		IsUrgent: false,
+		Trace:    &Trace{Method: "keyword", Note: "no urgent keyword matched"},
+	}
 }
 
 func truncate(s string, maxLen int) string {
@@ -0,0 +1,181 @@
+package classifier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localLayouts are datetimes without an offset, read in the user's timezone.
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ParseDateTime reads a datetime as LLMs tend to write it: RFC 3339, a local
+// date and time, a date alone, or a relative expression such as "tomorrow
+// at 3pm", "next friday" or "in 2 hours". Local and relative values are
+// resolved against ref and its location. allDay is true when no time of day
+// was given, in which case t is midnight at the start of that day.
+func ParseDateTime(s string, ref time.Time) (t time.Time, allDay bool, err error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	loc := ref.Location()
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, true, nil
+	}
+	if t, allDay, ok := parseRelative(strings.ToLower(s), ref); ok {
+		return t, allDay, nil
+	}
+	return time.Time{}, false, fmt.Errorf("unrecognized datetime %q", s)
+}
+
+var (
+	inRe    = regexp.MustCompile(`^in (\d+|an?|one) (minute|hour|day|week)s?$`)
+	clockRe = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+// parseRelative handles "<day> [at <time>]" and "in <n> <unit>".
+func parseRelative(s string, ref time.Time) (time.Time, bool, bool) {
+	if m := inRe.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			n = 1 // "a", "an" or "one"
+		}
+		switch m[2] {
+		case "minute":
+			return ref.Add(time.Duration(n) * time.Minute), false, true
+		case "hour":
+			return ref.Add(time.Duration(n) * time.Hour), false, true
+		case "day":
+			return midnight(ref).AddDate(0, 0, n), true, true
+		case "week":
+			return midnight(ref).AddDate(0, 0, 7*n), true, true
+		}
+	}
+
+	dayPart, clockPart, hasClock := strings.Cut(s, " at ")
+	if !hasClock {
+		// "tomorrow 15:00" or "monday 8:15 am"
+		i := strings.LastIndex(s, " ")
+		if suffix := s[i+1:]; i > 0 && (suffix == "am" || suffix == "pm") {
+			i = strings.LastIndex(s[:i], " ")
+		}
+		if i > 0 && clockRe.MatchString(s[i+1:]) {
+			dayPart, clockPart, hasClock = s[:i], s[i+1:], true
+		}
+	}
+
+	day, ok := parseDay(strings.TrimSpace(dayPart), ref)
+	if !ok {
+		return time.Time{}, false, false
+	}
+	if dayPart == "tonight" && !hasClock {
+		return atClock(day, 20, 0), false, true
+	}
+	if !hasClock {
+		return day, true, true
+	}
+	h, m, ok := parseClock(strings.TrimSpace(clockPart))
+	if !ok {
+		return time.Time{}, false, false
+	}
+	return atClock(day, h, m), false, true
+}
+
+// atClock returns the given time of day on day's date. Adding hours to
+// midnight would be off by one on days the clocks change.
+func atClock(day time.Time, hour, minute int) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hour, minute, 0, 0, day.Location())
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// parseDay resolves a day name relative to ref, returning its midnight.
+func parseDay(s string, ref time.Time) (time.Time, bool) {
+	today := midnight(ref)
+	switch s {
+	case "today", "tonight", "this evening", "this afternoon", "this morning":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	case "day after tomorrow", "the day after tomorrow":
+		return today.AddDate(0, 0, 2), true
+	case "next week":
+		return today.AddDate(0, 0, 7), true
+	}
+
+	s = strings.TrimPrefix(s, "on ")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "next "), "this ")
+	wd, ok := weekdays[s]
+	if !ok {
+		return time.Time{}, false
+	}
+	// Weekdays mean the coming one, never today: "friday" said on a Friday
+	// is a week away. "next friday" is read the same way, as most people
+	// mean it.
+	days := (int(wd) - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return today.AddDate(0, 0, days), true
+}
+
+// parseClock reads "15:00", "3pm", "3:30 pm", "noon" or "midnight".
+func parseClock(s string) (hour, minute int, ok bool) {
+	switch s {
+	case "noon", "midday":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+	m := clockRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" && (hour < 1 || hour > 12) {
+		return 0, 0, false
+	}
+	switch m[3] {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if m[2] == "" && m[3] == "" {
+		return 0, 0, false // a bare number isn't a time
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// midnight returns the start of t's day in its location.
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,141 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // so the tests don't depend on system zoneinfo
+)
+
+func TestParseDateTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday, 2025-03-12 10:30 in Berlin
+	ref := time.Date(2025, 3, 12, 10, 30, 0, 0, berlin)
+	at := func(y int, m time.Month, d, hour, minute int) time.Time {
+		return time.Date(y, m, d, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		in     string
+		want   time.Time
+		allDay bool
+	}{
+		// Absolute forms
+		{"2025-03-15T14:00:00Z", time.Date(2025, 3, 15, 14, 0, 0, 0, time.UTC), false},
+		{"2025-03-15T14:00:00+05:00", time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC), false},
+		{"2025-03-15T14:00", at(2025, 3, 15, 14, 0), false},
+		{"2025-03-15 14:00:30", time.Date(2025, 3, 15, 14, 0, 30, 0, berlin), false},
+		{"  2025-03-15 14:00  ", at(2025, 3, 15, 14, 0), false},
+		{"2025-03-15", at(2025, 3, 15, 0, 0), true},
+
+		// Relative days
+		{"today", at(2025, 3, 12, 0, 0), true},
+		{"Tomorrow", at(2025, 3, 13, 0, 0), true},
+		{"tomorrow at 3pm", at(2025, 3, 13, 15, 0), false},
+		{"tomorrow 15:30", at(2025, 3, 13, 15, 30), false},
+		{"tomorrow at noon", at(2025, 3, 13, 12, 0), false},
+		{"tomorrow at midnight", at(2025, 3, 13, 0, 0), false},
+		{"the day after tomorrow", at(2025, 3, 14, 0, 0), true},
+		{"tonight", at(2025, 3, 12, 20, 0), false},
+		{"tonight at 9pm", at(2025, 3, 12, 21, 0), false},
+		{"next week", at(2025, 3, 19, 0, 0), true},
+		{"in 2 hours", ref.Add(2 * time.Hour), false},
+		{"in an hour", ref.Add(time.Hour), false},
+		{"in 45 minutes", ref.Add(45 * time.Minute), false},
+		{"in 3 days", at(2025, 3, 15, 0, 0), true},
+		{"in a week", at(2025, 3, 19, 0, 0), true},
+
+		// Weekdays always mean the coming one, never today
+		{"friday", at(2025, 3, 14, 0, 0), true},
+		{"on friday at 9am", at(2025, 3, 14, 9, 0), false},
+		{"next friday", at(2025, 3, 14, 0, 0), true},
+		{"this friday", at(2025, 3, 14, 0, 0), true},
+		{"wednesday", at(2025, 3, 19, 0, 0), true},
+		{"monday 8:15 am", at(2025, 3, 17, 8, 15), false},
+		{"tuesday at 12am", at(2025, 3, 18, 0, 0), false},
+		{"tuesday at 12pm", at(2025, 3, 18, 12, 0), false},
+
+		// Clocks change on 2025-03-30 in Berlin; noon stays noon
+		{"sunday at noon", at(2025, 3, 16, 12, 0), false},
+		{"in 18 days", at(2025, 3, 30, 0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, allDay, err := ParseDateTime(tt.in, ref)
+			if err != nil {
+				t.Fatalf("ParseDateTime(%q) failed: %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) || allDay != tt.allDay {
+				t.Errorf("ParseDateTime(%q) = %v, allDay %v; want %v, allDay %v", tt.in, got, allDay, tt.want, tt.allDay)
+			}
+		})
+	}
+}
+
+func TestParseDateTimeAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		ref  time.Time
+		in   string
+		want time.Time
+	}{
+		{
+			name: "spring forward",
+			ref:  time.Date(2025, 3, 29, 18, 0, 0, 0, berlin), // Saturday, CET
+			in:   "tomorrow at 3pm",
+			want: time.Date(2025, 3, 30, 15, 0, 0, 0, berlin), // CEST
+		},
+		{
+			name: "fall back",
+			ref:  time.Date(2025, 10, 25, 18, 0, 0, 0, berlin), // Saturday, CEST
+			in:   "sunday at noon",
+			want: time.Date(2025, 10, 26, 12, 0, 0, 0, berlin), // CET
+		},
+		{
+			name: "weekday a week later",
+			ref:  time.Date(2025, 3, 28, 9, 0, 0, 0, berlin), // Friday, CET
+			in:   "friday at 9am",
+			want: time.Date(2025, 4, 4, 9, 0, 0, 0, berlin), // CEST
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := ParseDateTime(tt.in, tt.ref)
+			if err != nil {
+				t.Fatalf("ParseDateTime(%q) failed: %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDateTime(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			if h, m, _ := got.Clock(); h != tt.want.Hour() || m != tt.want.Minute() {
+				t.Errorf("ParseDateTime(%q) is at %02d:%02d local, want %02d:%02d", tt.in, h, m, tt.want.Hour(), tt.want.Minute())
+			}
+		})
+	}
+}
+
+func TestParseDateTimeInvalid(t *testing.T) {
+	ref := time.Date(2025, 3, 12, 10, 30, 0, 0, time.UTC)
+	for _, in := range []string{
+		"",
+		"soon",
+		"someday at 3pm",
+		"tomorrow at 3",    // a bare number isn't a time
+		"tomorrow at 13pm", // am/pm hours run 1-12
+		"tomorrow at 0am",
+		"tomorrow at 25:00", // out of range
+		"tomorrow at 9:60",
+		"2025-02-30",
+		"in two hours",
+	} {
+		if got, _, err := ParseDateTime(in, ref); err == nil {
+			t.Errorf("ParseDateTime(%q) = %v, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+// backend is one model in the fallback chain.
+type backend struct {
+	name    string // shown in traces, e.g. "gpt-5-nano" or "llama3.2 at localhost:11434"
+	model   string
+	client  openai.Client
+	breaker *breaker
+}
+
+// newBackend returns nil if the model isn't usable.
+func newBackend(m config.LLMModelConfig, failures int, cooldown time.Duration) *backend {
+	if m.Provider != "openai" || (m.APIKey == "" && m.BaseURL == "") {
+		return nil
+	}
+	if m.Model == "" {
+		m.Model = "gpt-5-nano"
+	}
+	// Retries are ours, so they can feed the circuit breaker
+	opts := []option.RequestOption{option.WithAPIKey(m.APIKey), option.WithMaxRetries(0)}
+	name := m.Model
+	if m.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(m.BaseURL))
+		if u, err := url.Parse(m.BaseURL); err == nil && u.Host != "" {
+			name += " at " + u.Host
+		}
+	}
+	return &backend{
+		name:    name,
+		model:   m.Model,
+		client:  openai.NewClient(opts...),
+		breaker: &breaker{threshold: failures, cooldown: cooldown},
+	}
+}
+
+// complete sends the prompts to b, retrying rate limits, server errors and
+// timeouts with jittered exponential backoff.
+func (c *LLMClassifier) complete(ctx context.Context, b *backend, systemPrompt, userPrompt string) (*openai.ChatCompletion, time.Duration, error) {
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		start := time.Now()
+		resp, err := b.client.Chat.Completions.New(attemptCtx, openai.ChatCompletionNewParams{
+			Model: b.model,
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage(systemPrompt),
+				openai.UserMessage(userPrompt),
+			},
+			MaxCompletionTokens: openai.Int(4096),
+			ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+				OfJSONObject: &shared.ResponseFormatJSONObjectParam{
+					Type: "json_object",
+				},
+			},
+		})
+		latency := time.Since(start)
+		cancel()
+		if err == nil {
+			return resp, latency, nil
+		}
+		if ctx.Err() != nil {
+			return nil, latency, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", c.timeout)
+		}
+
+		retryable, wait := retryAfter(err, attempt)
+		if !retryable || attempt >= c.maxRetries {
+			return nil, latency, err
+		}
+		slog.Warn("LLM call failed, retrying",
+			"model", b.name,
+			"attempt", attempt+1,
+			"wait", wait.Round(time.Millisecond),
+			"error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, latency, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryAfter reports whether err is worth retrying and how long to wait
+// first: the server's Retry-After if given, otherwise exponential backoff
+// from half a second with full jitter, capped at 8 seconds.
+func retryAfter(err error, attempt int) (bool, time.Duration) {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusTooManyRequests, code == http.StatusRequestTimeout, code >= 500:
+		default:
+			return false, 0
+		}
+		if apiErr.Response != nil {
+			if secs, err := strconv.Atoi(apiErr.Response.Header.Get("Retry-After")); err == nil && secs > 0 {
+				return true, min(time.Duration(secs)*time.Second, time.Minute)
+			}
+		}
+	}
+	// Anything else is a network error or timeout
+	backoff := min(500*time.Millisecond<<attempt, 8*time.Second)
+	return true, rand.N(backoff) + 1
+}
+
+// breaker stops calls to a model after repeated failures, letting one
+// through again after the cooldown to see whether it has recovered.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// allow reports whether a call may be made.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) {
+		return false
+	}
+	// Half-open: let this call probe, and block others until it reports
+	b.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// record reports the outcome of an allowed call.
+func (b *breaker) record(err error, now time.Time) (opened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures == b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package classifier
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+// Usage is the tokens used and estimated cost of LLM calls.
+type Usage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"` // US dollars; calls to models with unknown prices count as free
+}
+
+// Tokens returns the prompt and completion tokens combined.
+func (u Usage) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Add returns the sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Calls:            u.Calls + other.Calls,
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		Cost:             u.Cost + other.Cost,
+	}
+}
+
+// UsageSource reports LLM usage so far in the day and month containing at.
+type UsageSource interface {
+	UsageTotals(at time.Time) (day, month Usage)
+}
+
+// defaultPrices are list prices in US dollars per million tokens. Models are
+// matched by the longest prefix, so dated snapshots share their base price.
+var defaultPrices = map[string]config.ModelPrice{
+	"gpt-5":        {Input: 1.25, Output: 10},
+	"gpt-5-mini":   {Input: 0.25, Output: 2},
+	"gpt-5-nano":   {Input: 0.05, Output: 0.40},
+	"gpt-4.1":      {Input: 2, Output: 8},
+	"gpt-4.1-mini": {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano": {Input: 0.10, Output: 0.40},
+	"gpt-4o":       {Input: 2.50, Output: 10},
+	"gpt-4o-mini":  {Input: 0.15, Output: 0.60},
+
+	// Transcription models bill audio input by the token
+	"gpt-4o-transcribe":      {Input: 6, Output: 10},
+	"gpt-4o-mini-transcribe": {Input: 3, Output: 5},
+}
+
+// price looks up a model's price, preferring those in prices. It reports
+// false for unknown models.
+func price(prices map[string]config.ModelPrice, model string) (config.ModelPrice, bool) {
+	if p, ok := prices[model]; ok {
+		return p, true
+	}
+	best := ""
+	for name := range defaultPrices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return defaultPrices[best], true
+}
+
+// cost estimates the price of a call in US dollars.
+func (c *LLMClassifier) cost(model string, promptTokens, completionTokens int) float64 {
+	return EstimateCost(c.cfg.Prices, model, promptTokens, completionTokens)
+}
+
+// EstimateCost estimates the price of a call in US dollars from the built-in
+// prices, overridden by prices. Unknown models are free.
+func EstimateCost(prices map[string]config.ModelPrice, model string, promptTokens, completionTokens int) float64 {
+	p, ok := price(prices, model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// budget enforces config.BudgetConfig against the usage recorded so far.
+type budget struct {
+	cfg      config.BudgetConfig
+	usage    UsageSource
+	exceeded func(reason string) // called once per exhausted period
+
+	mu       sync.Mutex
+	notified string // period of the last exceeded call
+}
+
+// UseBudget stops calling the LLM once a limit in cfg is reached, according
+// to the usage reported by src. exceeded is called once each time a day's or
+// month's budget runs out.
+func (c *LLMClassifier) UseBudget(src UsageSource, cfg config.BudgetConfig, exceeded func(reason string)) {
+	c.budget = &budget{cfg: cfg, usage: src, exceeded: exceeded}
+}
+
+// BudgetExhausted returns why the LLM budget is spent, or "" if there is
+// none or it isn't. Other paid calls, such as describing images, check it
+// too.
+func (c *LLMClassifier) BudgetExhausted() string {
+	return c.budget.check(time.Now())
+}
+
+// check returns why the budget is exhausted, or "" if calls may go ahead.
+func (b *budget) check(now time.Time) string {
+	if b == nil {
+		return ""
+	}
+	day, month := b.usage.UsageTotals(now)
+
+	var reason, period string
+	switch {
+	case b.cfg.DailyUSD > 0 && day.Cost >= b.cfg.DailyUSD:
+		reason = fmt.Sprintf("daily budget of $%.2f reached ($%.2f spent)", b.cfg.DailyUSD, day.Cost)
+		period = now.Format("2006-01-02")
+	case b.cfg.DailyTokens > 0 && day.Tokens() >= b.cfg.DailyTokens:
+		reason = fmt.Sprintf("daily budget of %d tokens reached (%d used)", b.cfg.DailyTokens, day.Tokens())
+		period = now.Format("2006-01-02")
+	case b.cfg.MonthlyUSD > 0 && month.Cost >= b.cfg.MonthlyUSD:
+		reason = fmt.Sprintf("monthly budget of $%.2f reached ($%.2f spent)", b.cfg.MonthlyUSD, month.Cost)
+		period = now.Format("2006-01")
+	case b.cfg.MonthlyTokens > 0 && month.Tokens() >= b.cfg.MonthlyTokens:
+		reason = fmt.Sprintf("monthly budget of %d tokens reached (%d used)", b.cfg.MonthlyTokens, month.Tokens())
+		period = now.Format("2006-01")
+	default:
+		return ""
+	}
+
+	b.mu.Lock()
+	first := b.notified != period
+	b.notified = period
+	b.mu.Unlock()
+	if first {
+		slog.Warn("LLM budget exhausted, classifying by keywords until it resets", "reason", reason)
+		if b.exceeded != nil {
+			b.exceeded(reason)
+		}
+	}
+	return reason
+}
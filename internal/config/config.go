@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,57 +19,531 @@ type Config struct {
 	Pushover PushoverConfig `yaml:"pushover"`
 	LLM      LLMConfig      `yaml:"llm"`
 	Calendar CalendarConfig `yaml:"calendar"`
+	Tasks    TasksConfig    `yaml:"tasks"`
 	Server   ServerConfig   `yaml:"server"`
+	Google   GoogleConfig   `yaml:"google"`
+
+	Transcription TranscriptionConfig `yaml:"transcription"`
+	Vision        VisionConfig        `yaml:"vision"`
+	Feedback      FeedbackConfig      `yaml:"feedback"`
+	Contacts      ContactsConfig      `yaml:"contacts"`
+	Rules         RulesConfig         `yaml:"rules"`
+	Hooks         HooksConfig         `yaml:"hooks"`
+	Cache         CacheConfig         `yaml:"cache"`
+	Redaction     RedactionConfig     `yaml:"redaction"`
+
+	// Timezone is the user's IANA timezone, e.g. "Europe/Berlin". Dates in
+	// messages are read and shown in it. Defaults to the system's.
+	Timezone string `yaml:"timezone"`
 }
 
+// Location returns the configured timezone, or local time if unset.
+func (c *Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local // checked by Load
+	}
+	return loc
+}
+
+// Each platform section either describes a single account directly or lists
+// several named accounts under "accounts". Accounts inherit unset fields from
+// the enclosing section, and per-account paths default to the section's path
+// suffixed with the account name. An account with "enabled: false" stays off
+// while the others run.
+
 type WhatsAppConfig struct {
-	Enabled     bool   `yaml:"enabled"`
-	StoragePath string `yaml:"storage_path"`
+	Enabled     *bool            `yaml:"enabled"`
+	Name        string           `yaml:"name"`
+	StoragePath string           `yaml:"storage_path"`
+	Accounts    []WhatsAppConfig `yaml:"accounts"`
 }
 
 type TelegramConfig struct {
-	Enabled  bool   `yaml:"enabled"`
+	Enabled  *bool  `yaml:"enabled"`
+	Name     string `yaml:"name"`
 	AppID    int    `yaml:"app_id"`
 	AppHash  string `yaml:"app_hash"`
 	Phone    string `yaml:"phone"`
 	DataPath string `yaml:"data_path"`
+
+	// Chat filtering. Entries are chat IDs or @usernames. When AllowChats is
+	// set, only those chats are forwarded; DenyChats always wins.
+	AllowChats []string `yaml:"allow_chats"`
+	DenyChats  []string `yaml:"deny_chats"`
+
+	// Unset in an account, these inherit the platform-wide setting; set to
+	// false, they turn it off for that account.
+	IncludeChannels *bool `yaml:"include_channels"` // forward broadcast channel posts
+	RespectMute     *bool `yaml:"respect_mute"`     // skip chats muted in Telegram
+
+	Accounts []TelegramConfig `yaml:"accounts"`
 }
 
 type SlackConfig struct {
-	Enabled  bool   `yaml:"enabled"`
-	AppToken string `yaml:"app_token"`
-	BotToken string `yaml:"bot_token"`
+	Enabled  *bool         `yaml:"enabled"`
+	Name     string        `yaml:"name"`
+	AppToken string        `yaml:"app_token"`
+	BotToken string        `yaml:"bot_token"`
+	Accounts []SlackConfig `yaml:"accounts"`
 }
 
 type GmailConfig struct {
-	Enabled         bool   `yaml:"enabled"`
-	CredentialsPath string `yaml:"credentials_path"`
-	TokenPath       string `yaml:"token_path"`
-	PollInterval    int    `yaml:"poll_interval_seconds"`
+	Enabled         *bool         `yaml:"enabled"`
+	Name            string        `yaml:"name"`
+	CredentialsPath string        `yaml:"credentials_path"`
+	TokenPath       string        `yaml:"token_path"`
+	PollInterval    int           `yaml:"poll_interval_seconds"`
+	Accounts        []GmailConfig `yaml:"accounts"`
+}
+
+// IsEnabled reports whether the section, or the account, is turned on.
+func (c WhatsAppConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// AccountConfigs returns the effective config of every WhatsApp account.
+func (c WhatsAppConfig) AccountConfigs() []WhatsAppConfig {
+	if len(c.Accounts) == 0 {
+		return []WhatsAppConfig{c}
+	}
+	accounts := make([]WhatsAppConfig, 0, len(c.Accounts))
+	for _, a := range c.Accounts {
+		if a.Enabled == nil {
+			a.Enabled = c.Enabled
+		}
+		if a.StoragePath == "" {
+			a.StoragePath = filepath.Join(c.StoragePath, a.Name)
+		}
+		a.Accounts = nil
+		accounts = append(accounts, a)
+	}
+	return accounts
+}
+
+// IsEnabled reports whether the section, or the account, is turned on.
+func (c TelegramConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// AccountConfigs returns the effective config of every Telegram account.
+func (c TelegramConfig) AccountConfigs() []TelegramConfig {
+	if len(c.Accounts) == 0 {
+		return []TelegramConfig{c}
+	}
+	accounts := make([]TelegramConfig, 0, len(c.Accounts))
+	for _, a := range c.Accounts {
+		if a.Enabled == nil {
+			a.Enabled = c.Enabled
+		}
+		if a.AppID == 0 {
+			a.AppID = c.AppID
+		}
+		if a.AppHash == "" {
+			a.AppHash = c.AppHash
+		}
+		if a.DataPath == "" {
+			a.DataPath = filepath.Join(c.DataPath, a.Name)
+		}
+		if a.AllowChats == nil {
+			a.AllowChats = c.AllowChats
+		}
+		if a.DenyChats == nil {
+			a.DenyChats = c.DenyChats
+		}
+		if a.IncludeChannels == nil {
+			a.IncludeChannels = c.IncludeChannels
+		}
+		if a.RespectMute == nil {
+			a.RespectMute = c.RespectMute
+		}
+		a.Accounts = nil
+		accounts = append(accounts, a)
+	}
+	return accounts
+}
+
+// ForwardChannels reports whether broadcast channel posts are forwarded.
+func (c TelegramConfig) ForwardChannels() bool {
+	return c.IncludeChannels != nil && *c.IncludeChannels
+}
+
+// SkipMuted reports whether chats muted in Telegram are skipped.
+func (c TelegramConfig) SkipMuted() bool {
+	return c.RespectMute != nil && *c.RespectMute
+}
+
+// IsEnabled reports whether the section, or the account, is turned on.
+func (c SlackConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// AccountConfigs returns the effective config of every Slack workspace.
+func (c SlackConfig) AccountConfigs() []SlackConfig {
+	if len(c.Accounts) == 0 {
+		return []SlackConfig{c}
+	}
+	accounts := make([]SlackConfig, 0, len(c.Accounts))
+	for _, a := range c.Accounts {
+		if a.Enabled == nil {
+			a.Enabled = c.Enabled
+		}
+		a.Accounts = nil
+		accounts = append(accounts, a)
+	}
+	return accounts
+}
+
+// IsEnabled reports whether the section, or the account, is turned on.
+func (c GmailConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// AccountConfigs returns the effective config of every Gmail account.
+func (c GmailConfig) AccountConfigs() []GmailConfig {
+	if len(c.Accounts) == 0 {
+		return []GmailConfig{c}
+	}
+	accounts := make([]GmailConfig, 0, len(c.Accounts))
+	for _, a := range c.Accounts {
+		if a.Enabled == nil {
+			a.Enabled = c.Enabled
+		}
+		if a.CredentialsPath == "" {
+			a.CredentialsPath = c.CredentialsPath
+		}
+		if a.TokenPath == "" {
+			a.TokenPath = accountFilePath(c.TokenPath, a.Name)
+		}
+		if a.PollInterval == 0 {
+			a.PollInterval = c.PollInterval
+		}
+		a.Accounts = nil
+		accounts = append(accounts, a)
+	}
+	return accounts
+}
+
+// accountFilePath derives a per-account file path by inserting the account
+// name before the extension, e.g. "./token.json" becomes "./token-work.json".
+func accountFilePath(path, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
 }
 
 type PushoverConfig struct {
 	AppToken  string `yaml:"app_token"`
 	UserToken string `yaml:"user_token"`
+	Device    string `yaml:"device"`   // every device when empty
+	Priority  string `yaml:"priority"` // "lowest", "low", "normal" or "high" (default)
+	Sound     string `yaml:"sound"`    // defaults to "persistent"
+
+	// Routes are named variants, such as another user or a quieter priority,
+	// that rules can send notifications to. They inherit unset fields.
+	Routes map[string]PushoverConfig `yaml:"routes"`
+}
+
+// RouteConfigs returns the effective config of every named route.
+func (c PushoverConfig) RouteConfigs() map[string]PushoverConfig {
+	routes := make(map[string]PushoverConfig, len(c.Routes))
+	for name, r := range c.Routes {
+		if r.AppToken == "" {
+			r.AppToken = c.AppToken
+		}
+		if r.UserToken == "" {
+			r.UserToken = c.UserToken
+		}
+		if r.Device == "" {
+			r.Device = c.Device
+		}
+		if r.Priority == "" {
+			r.Priority = c.Priority
+		}
+		if r.Sound == "" {
+			r.Sound = c.Sound
+		}
+		r.Routes = nil
+		routes[name] = r
+	}
+	return routes
+}
+
+func (c PushoverConfig) validate() error {
+	for name, r := range c.RouteConfigs() {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("routes.%s: %w", name, err)
+		}
+	}
+	switch c.Priority {
+	case "", "lowest", "low", "normal", "high":
+		return nil
+	}
+	return fmt.Errorf("unknown priority %q", c.Priority)
 }
 
 type LLMConfig struct {
 	Provider string `yaml:"provider"` // "openai" or "gemini"
 	APIKey   string `yaml:"api_key"`
 	Model    string `yaml:"model"`
+	BaseURL  string `yaml:"base_url"` // OpenAI-compatible endpoint override
+
+	// Models tried in order when the one before fails, before falling back
+	// to keyword classification.
+	Fallbacks []LLMModelConfig `yaml:"fallbacks"`
+
+	// Each call is retried on rate limits, server errors and timeouts. After
+	// BreakerFailures consecutive failed calls a model is skipped for
+	// BreakerCooldownSeconds.
+	TimeoutSeconds         int `yaml:"timeout_seconds"`          // per attempt, defaults to 30
+	MaxRetries             int `yaml:"max_retries"`              // defaults to 2, negative disables
+	BreakerFailures        int `yaml:"breaker_failures"`         // defaults to 5
+	BreakerCooldownSeconds int `yaml:"breaker_cooldown_seconds"` // defaults to 60
+
+	// Earlier messages from the same conversation included in the prompt.
+	ContextMessages int `yaml:"context_messages"` // defaults to 10, negative disables
+	ContextTokens   int `yaml:"context_tokens"`   // estimated budget, defaults to 1000
+
+	// Prices override or extend the built-in per-model prices used to
+	// estimate cost, keyed by model name.
+	Prices map[string]ModelPrice `yaml:"prices"`
+	Budget BudgetConfig          `yaml:"budget"`
+
+	// UsagePath is the JSON file daily usage is kept in, so spending and
+	// budgets survive restarts. Defaults to usage.json next to feedback.path.
+	UsagePath string `yaml:"usage_path"`
+}
+
+// LLMModelConfig is a fallback model, such as a cheaper one or one served
+// locally by Ollama or llama.cpp.
+type LLMModelConfig struct {
+	Provider string `yaml:"provider"` // "openai", the default, for any OpenAI-compatible API
+	APIKey   string `yaml:"api_key"`  // defaults to llm.api_key unless base_url is set
+	Model    string `yaml:"model"`
+	BaseURL  string `yaml:"base_url"`
+}
+
+// ModelPrice is what a model costs in US dollars per million tokens.
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// BudgetConfig caps LLM spending. Once a limit is reached, messages are
+// classified by keywords and rules until the day or month is over. Zero
+// means no limit.
+type BudgetConfig struct {
+	DailyUSD      float64 `yaml:"daily_usd"`
+	MonthlyUSD    float64 `yaml:"monthly_usd"`
+	DailyTokens   int     `yaml:"daily_tokens"`
+	MonthlyTokens int     `yaml:"monthly_tokens"`
+}
+
+// Enabled reports whether any limit is set.
+func (b BudgetConfig) Enabled() bool {
+	return b.DailyUSD > 0 || b.MonthlyUSD > 0 || b.DailyTokens > 0 || b.MonthlyTokens > 0
+}
+
+// TranscriptionConfig configures speech-to-text for voice messages.
+type TranscriptionConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Provider string `yaml:"provider"` // "openai" or "whispercpp"
+	APIKey   string `yaml:"api_key"`  // defaults to llm.api_key
+	BaseURL  string `yaml:"base_url"` // OpenAI-compatible endpoint override
+	Model    string `yaml:"model"`    // OpenAI model name, or whisper.cpp model file
+	Language string `yaml:"language"` // ISO-639-1 code; auto-detected if empty
+
+	// whisper.cpp settings. If ServerURL is set the server is used,
+	// otherwise BinaryPath is invoked directly.
+	ServerURL  string `yaml:"server_url"`
+	BinaryPath string `yaml:"binary_path"`
+	FFmpegPath string `yaml:"ffmpeg_path"`
+}
+
+// VisionConfig configures image understanding for photo messages. Any
+// OpenAI-compatible multimodal endpoint works, including local ones.
+type VisionConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	APIKey  string `yaml:"api_key"`  // defaults to llm.api_key
+	BaseURL string `yaml:"base_url"` // e.g. "http://localhost:11434/v1" for Ollama
+	Model   string `yaml:"model"`
+}
+
+// FeedbackConfig controls the corrections made from the dashboard, which are
+// shown to the classifier as few-shot examples.
+type FeedbackConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Path        string `yaml:"path"`         // JSON Lines file, defaults to "./feedback.jsonl"
+	MaxExamples int    `yaml:"max_examples"` // per classification, defaults to 5
+}
+
+// ContactsConfig points at the address book of VIP, blocked and
+// priority-overridden senders, which is also editable from the dashboard.
+type ContactsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"` // YAML file, defaults to "./contacts.yaml"
+}
+
+// RulesConfig points at the declarative rules evaluated around the
+// classifier. The file is reloaded when it changes.
+type RulesConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Path          string `yaml:"path"`           // YAML file, defaults to "./rules.yaml"
+	ReloadSeconds int    `yaml:"reload_seconds"` // how often to check for changes, defaults to 5
+}
+
+// HooksConfig points at the Starlark script run at fixed points of message
+// handling. The file is reloaded when it changes.
+type HooksConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Path          string `yaml:"path"`           // defaults to "./hooks.star"
+	TimeoutMillis int    `yaml:"timeout_ms"`     // per call, defaults to 200
+	MaxSteps      uint64 `yaml:"max_steps"`      // per call, defaults to 1000000
+	ReloadSeconds int    `yaml:"reload_seconds"` // how often to check for changes, defaults to 5
+}
+
+// CacheConfig controls reuse of classifications for repeated messages, such
+// as a recurring alert.
+type CacheConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	TTLMinutes     int  `yaml:"ttl_minutes"`     // how long a result is reused, defaults to 360
+	MinLength      int  `yaml:"min_length"`      // shorter messages are always classified, defaults to 40 characters
+	MaxEntries     int  `yaml:"max_entries"`     // defaults to 1000
+	SimilarityBits int  `yaml:"similarity_bits"` // SimHash bits near-duplicates may differ by, defaults to 3; negative allows only identical text
+}
+
+// RedactionConfig masks personal data in prompts before they are sent to the
+// LLM. Masked values are restored in the action items that come back.
+type RedactionConfig struct {
+	Enabled  bool               `yaml:"enabled"`
+	Types    []string           `yaml:"types"`   // "email", "phone", "card", "iban", "otp"; defaults to all
+	Senders  bool               `yaml:"senders"` // also mask sender names
+	Patterns []RedactionPattern `yaml:"patterns"`
+}
+
+// RedactionPattern masks whatever a regular expression matches, with a
+// placeholder named after it.
+type RedactionPattern struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// GoogleConfig holds OAuth settings shared by Gmail, Calendar and Tasks.
+// Their credentials_path and token_path default to these, so one token grants
+// all of them.
+type GoogleConfig struct {
+	CredentialsPath string `yaml:"credentials_path"`
+	TokenPath       string `yaml:"token_path"`
+	RedirectURL     string `yaml:"redirect_url"` // defaults to the dashboard's /oauth/google/callback on localhost
 }
 
 type CalendarConfig struct {
 	Enabled                bool   `yaml:"enabled"`
+	Backend                string `yaml:"backend"` // "google" (default) or "caldav"
 	CredentialsPath        string `yaml:"credentials_path"`
 	TokenPath              string `yaml:"token_path"`
 	DefaultDurationMinutes int    `yaml:"default_duration_minutes"`
 	CalendarID             string `yaml:"calendar_id"`
+
+	CalDAV CalDAVConfig `yaml:"caldav"`
+}
+
+func (c CalendarConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Backend {
+	case "", "google":
+	case "caldav":
+		if c.CalDAV.URL == "" {
+			return fmt.Errorf("calendar: the caldav backend needs caldav.url")
+		}
+	default:
+		return fmt.Errorf("calendar: unknown backend %q (want google or caldav)", c.Backend)
+	}
+	return nil
+}
+
+// TasksConfig saves action items without a date as tasks.
+type TasksConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Backend string `yaml:"backend"` // "file" (default), "google" or "caldav"
+
+	// Google Tasks; credentials_path and token_path default to google's
+	CredentialsPath string `yaml:"credentials_path"`
+	TokenPath       string `yaml:"token_path"`
+	TaskListID      string `yaml:"task_list_id"` // defaults to "@default"
+
+	// Local file
+	Path   string `yaml:"path"`   // defaults to "./data/tasks.md"
+	Format string `yaml:"format"` // "markdown" or "todo.txt"; defaults by the file extension
+
+	CalDAV CalDAVConfig `yaml:"caldav"`
+}
+
+// CalDAVConfig locates a calendar on a CalDAV server. URL is either the
+// calendar itself or the server, e.g. https://cloud.example.com/remote.php/dav
+// or https://caldav.fastmail.com, in which case the calendar is discovered.
+type CalDAVConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"` // an app password on Nextcloud, Fastmail and iCloud
+	Calendar string `yaml:"calendar"` // display name or path segment; defaults to the first suitable calendar
+}
+
+func (c TasksConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Backend {
+	case "", "file":
+		switch c.Format {
+		case "", "markdown", "todo.txt":
+		default:
+			return fmt.Errorf("tasks: unknown format %q (want markdown or todo.txt)", c.Format)
+		}
+	case "google":
+	case "caldav":
+		if c.CalDAV.URL == "" {
+			return fmt.Errorf("tasks: the caldav backend needs caldav.url")
+		}
+	default:
+		return fmt.Errorf("tasks: unknown backend %q (want file, google or caldav)", c.Backend)
+	}
+	return nil
 }
 
 type ServerConfig struct {
-	Enabled bool `yaml:"enabled"`
-	Port    int  `yaml:"port"`
+	Enabled     bool       `yaml:"enabled"`
+	Port        int        `yaml:"port"`
+	BindAddress string     `yaml:"bind_address"` // defaults to "127.0.0.1"; "0.0.0.0" for all interfaces
+	TLSCertPath string     `yaml:"tls_cert_path"`
+	TLSKeyPath  string     `yaml:"tls_key_path"`
+	Auth        AuthConfig `yaml:"auth"`
+}
+
+// AuthConfig controls access to the dashboard and API.
+type AuthConfig struct {
+	// Mode is "none" (default), "password" for a local user, or "proxy" to
+	// trust a username header set by a reverse proxy.
+	Mode         string `yaml:"mode"`
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password_hash"` // bcrypt, see "notifylm -hash-password"
+
+	// APITokens are accepted as "Authorization: Bearer <token>" in any mode.
+	APITokens []string `yaml:"api_tokens"`
+
+	ProxyHeader    string   `yaml:"proxy_header"`    // defaults to "X-Forwarded-User"
+	TrustedProxies []string `yaml:"trusted_proxies"` // IPs or CIDRs allowed to set the header
+
+	SessionHours int `yaml:"session_hours"` // defaults to 168 (one week)
+}
+
+// TLSEnabled reports whether the dashboard is served over HTTPS.
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertPath != "" && c.TLSKeyPath != ""
 }
 
 // Load reads configuration from a YAML file.
@@ -83,25 +561,138 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.Transcription.APIKey == "" && cfg.Transcription.Provider != "whispercpp" {
+		cfg.Transcription.APIKey = cfg.LLM.APIKey
+	}
+
+	if cfg.Vision.APIKey == "" {
+		cfg.Vision.APIKey = cfg.LLM.APIKey
+	}
+
+	for i := range cfg.LLM.Fallbacks {
+		fb := &cfg.LLM.Fallbacks[i]
+		if fb.Provider == "" {
+			fb.Provider = "openai"
+		}
+		if fb.APIKey == "" && fb.BaseURL == "" {
+			fb.APIKey = cfg.LLM.APIKey
+		}
+	}
+
+	if cfg.Gmail.CredentialsPath == "" {
+		cfg.Gmail.CredentialsPath = cfg.Google.CredentialsPath
+	}
+	if cfg.Gmail.TokenPath == "" {
+		cfg.Gmail.TokenPath = cfg.Google.TokenPath
+	}
+	if cfg.Calendar.CredentialsPath == "" {
+		cfg.Calendar.CredentialsPath = cfg.Google.CredentialsPath
+	}
+	if cfg.Calendar.TokenPath == "" {
+		cfg.Calendar.TokenPath = cfg.Google.TokenPath
+	}
+	if cfg.Tasks.CredentialsPath == "" {
+		cfg.Tasks.CredentialsPath = cfg.Google.CredentialsPath
+	}
+	if cfg.Tasks.TokenPath == "" {
+		cfg.Tasks.TokenPath = cfg.Google.TokenPath
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// validate checks the config for mistakes that would otherwise only surface
+// later or be silently ignored: account lists without unique, non-empty
+// names, an unknown timezone, and incomplete pushover, calendar, tasks or
+// server settings.
+func (c *Config) validate() error {
+	sections := map[string][]string{}
+	for _, a := range c.WhatsApp.Accounts {
+		sections["whatsapp"] = append(sections["whatsapp"], a.Name)
+	}
+	for _, a := range c.Telegram.Accounts {
+		sections["telegram"] = append(sections["telegram"], a.Name)
+	}
+	for _, a := range c.Slack.Accounts {
+		sections["slack"] = append(sections["slack"], a.Name)
+	}
+	for _, a := range c.Gmail.Accounts {
+		sections["gmail"] = append(sections["gmail"], a.Name)
+	}
+
+	for section, names := range sections {
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			if name == "" {
+				return fmt.Errorf("%s: every account needs a name", section)
+			}
+			if seen[name] {
+				return fmt.Errorf("%s: duplicate account name %q", section, name)
+			}
+			seen[name] = true
+		}
+	}
+
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+		}
+	}
+
+	if err := c.Pushover.validate(); err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	if err := c.Calendar.validate(); err != nil {
+		return err
+	}
+	if err := c.Tasks.validate(); err != nil {
+		return err
+	}
+	return c.Server.validate()
+}
+
+func (c ServerConfig) validate() error {
+	if (c.TLSCertPath == "") != (c.TLSKeyPath == "") {
+		return fmt.Errorf("server: tls_cert_path and tls_key_path must be set together")
+	}
+
+	switch c.Auth.Mode {
+	case "", "none":
+	case "password":
+		if c.Auth.Username == "" || c.Auth.PasswordHash == "" {
+			return fmt.Errorf("server.auth: password mode needs username and password_hash")
+		}
+	case "proxy":
+		if len(c.Auth.TrustedProxies) == 0 {
+			return fmt.Errorf("server.auth: proxy mode needs trusted_proxies")
+		}
+	default:
+		return fmt.Errorf("server.auth: unknown mode %q", c.Auth.Mode)
+	}
+	return nil
+}
+
 // DefaultConfig returns a config with sensible defaults.
 func DefaultConfig() *Config {
+	enabled := true
 	return &Config{
 		WhatsApp: WhatsAppConfig{
-			Enabled:     true,
+			Enabled:     &enabled,
 			StoragePath: "./data/whatsapp",
 		},
 		Telegram: TelegramConfig{
-			Enabled:  true,
+			Enabled:  &enabled,
 			DataPath: "./data/telegram",
 		},
 		Slack: SlackConfig{
-			Enabled: true,
+			Enabled: &enabled,
 		},
 		Gmail: GmailConfig{
-			Enabled:         true,
+			Enabled:         &enabled,
 			CredentialsPath: "./credentials.json",
 			TokenPath:       "./token.json",
 			PollInterval:    60,
@@ -113,9 +704,25 @@ func DefaultConfig() *Config {
 			DefaultDurationMinutes: 30,
 			CalendarID:             "primary",
 		},
-		Server: ServerConfig{
+		Feedback: FeedbackConfig{
+			Enabled: true,
+			Path:    "./feedback.jsonl",
+		},
+		Contacts: ContactsConfig{
+			Enabled: true,
+			Path:    "./contacts.yaml",
+		},
+		Rules: RulesConfig{
 			Enabled: true,
-			Port:    8080,
+			Path:    "./rules.yaml",
+		},
+		Cache: CacheConfig{
+			Enabled: true,
+		},
+		Server: ServerConfig{
+			Enabled:     true,
+			Port:        8080,
+			BindAddress: "127.0.0.1",
 		},
 	}
 }
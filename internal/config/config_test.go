@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAccountsEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want map[string]bool
+	}{
+		{
+			name: "accounts inherit the section",
+			yaml: "enabled: true\naccounts: [{name: a}, {name: b}]",
+			want: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name: "an account turned off",
+			yaml: "enabled: true\naccounts: [{name: a}, {name: b, enabled: false}]",
+			want: map[string]bool{"a": true, "b": false},
+		},
+		{
+			name: "section off by default",
+			yaml: "accounts: [{name: a}]",
+			want: map[string]bool{"a": false},
+		},
+		{
+			name: "single account",
+			yaml: "enabled: true\nname: solo",
+			want: map[string]bool{"solo": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg GmailConfig
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			accounts := cfg.AccountConfigs()
+			if len(accounts) != len(tt.want) {
+				t.Fatalf("got %d accounts, want %d", len(accounts), len(tt.want))
+			}
+			for _, a := range accounts {
+				if a.IsEnabled() != tt.want[a.Name] {
+					t.Errorf("account %q enabled = %v, want %v", a.Name, a.IsEnabled(), tt.want[a.Name])
+				}
+			}
+		})
+	}
+
+	// Every platform keeps the account's own setting
+	var cfg Config
+	data := `
+whatsapp: {enabled: true, accounts: [{name: a, enabled: false}]}
+telegram: {enabled: true, accounts: [{name: a, enabled: false}]}
+slack: {enabled: true, accounts: [{name: a, enabled: false}]}
+`
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.WhatsApp.AccountConfigs()[0].IsEnabled() || cfg.Telegram.AccountConfigs()[0].IsEnabled() || cfg.Slack.AccountConfigs()[0].IsEnabled() {
+		t.Error("an account with enabled: false was turned on by its section")
+	}
+}
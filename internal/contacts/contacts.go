@@ -0,0 +1,295 @@
+// Package contacts matches message senders against a YAML address book of
+// VIPs, blocked senders and per-sender priority overrides. A contact is
+// recognized on any platform by phone number, email address, Slack user ID
+// or Telegram username.
+package contacts
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// Priorities a contact can carry.
+const (
+	PriorityHigh = "high" // hinted to the classifier as important
+	PriorityLow  = "low"  // never urgent
+)
+
+// Contact is one entry in the address book.
+type Contact struct {
+	Name              string   `yaml:"name" json:"name"`
+	VIP               bool     `yaml:"vip,omitempty" json:"vip"`         // always notify
+	Blocked           bool     `yaml:"blocked,omitempty" json:"blocked"` // never classified
+	Priority          string   `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Phones            []string `yaml:"phones,omitempty" json:"phones,omitempty"`
+	Emails            []string `yaml:"emails,omitempty" json:"emails,omitempty"` // "@example.com" matches the domain
+	SlackIDs          []string `yaml:"slack_ids,omitempty" json:"slack_ids,omitempty"`
+	TelegramUsernames []string `yaml:"telegram_usernames,omitempty" json:"telegram_usernames,omitempty"`
+}
+
+// Label describes how the contact is treated, as shown to the classifier.
+func (c *Contact) Label() string {
+	switch {
+	case c.Blocked:
+		return "blocked"
+	case c.VIP:
+		return "vip"
+	default:
+		return c.Priority
+	}
+}
+
+type bookFile struct {
+	Contacts []Contact `yaml:"contacts"`
+}
+
+// Book is the address book, loaded from and saved to a YAML file.
+type Book struct {
+	path string
+
+	mu       sync.RWMutex
+	contacts []Contact
+	index    map[string]int // identity key -> index into contacts
+}
+
+// Load reads the address book at path. A missing file gives an empty book
+// that is created on the first save.
+func Load(path string) (*Book, error) {
+	if path == "" {
+		path = "./contacts.yaml"
+	}
+	b := &Book{path: path, index: map[string]int{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contacts file: %w", err)
+	}
+	contacts, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contacts file %s: %w", path, err)
+	}
+	index, err := buildIndex(contacts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contacts file %s: %w", path, err)
+	}
+	b.contacts, b.index = contacts, index
+
+	slog.Info("Loaded contacts", "path", path, "contacts", len(contacts))
+	return b, nil
+}
+
+// Parse decodes and validates an address book in YAML.
+func Parse(data []byte) ([]Contact, error) {
+	var f bookFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse contacts: %w", err)
+	}
+	for i := range f.Contacts {
+		if err := f.Contacts[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return f.Contacts, nil
+}
+
+func (c *Contact) validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("every contact needs a name")
+	}
+	if c.VIP && c.Blocked {
+		return fmt.Errorf("contact %q can't be both vip and blocked", c.Name)
+	}
+	switch c.Priority {
+	case "", PriorityHigh, PriorityLow:
+	default:
+		return fmt.Errorf("contact %q has unknown priority %q (want %q or %q)", c.Name, c.Priority, PriorityHigh, PriorityLow)
+	}
+	if len(c.keys()) == 0 {
+		return fmt.Errorf("contact %q has no phones, emails, slack_ids or telegram_usernames", c.Name)
+	}
+	return nil
+}
+
+// keys returns the normalized identity keys the contact matches.
+func (c *Contact) keys() []string {
+	var keys []string
+	for _, p := range c.Phones {
+		if n := normalizePhone(p); n != "" {
+			keys = append(keys, "phone:"+n)
+		}
+	}
+	for _, e := range c.Emails {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			keys = append(keys, "email:"+e)
+		}
+	}
+	for _, id := range c.SlackIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			keys = append(keys, "slack:"+strings.ToUpper(id))
+		}
+	}
+	for _, u := range c.TelegramUsernames {
+		if u = normalizeUsername(u); u != "" {
+			keys = append(keys, "telegram:"+u)
+		}
+	}
+	return keys
+}
+
+func buildIndex(contacts []Contact) (map[string]int, error) {
+	index := make(map[string]int)
+	for i := range contacts {
+		for _, k := range contacts[i].keys() {
+			if j, ok := index[k]; ok && j != i {
+				return nil, fmt.Errorf("%s is listed for both %q and %q", k, contacts[j].Name, contacts[i].Name)
+			}
+			index[k] = i
+		}
+	}
+	return index, nil
+}
+
+// senderKeys returns the identity keys for the sender of msg, most specific
+// first.
+func senderKeys(msg *message.Message) []string {
+	var keys []string
+	if p := normalizePhone(msg.Metadata["sender_phone"]); p != "" {
+		keys = append(keys, "phone:"+p)
+	}
+	if e := strings.ToLower(msg.Metadata["sender_email"]); e != "" {
+		keys = append(keys, "email:"+e)
+		if at := strings.LastIndex(e, "@"); at >= 0 {
+			keys = append(keys, "email:"+e[at:])
+		}
+	}
+	if msg.Source == message.SourceSlack {
+		if id := msg.Metadata["user_id"]; id != "" {
+			keys = append(keys, "slack:"+strings.ToUpper(id))
+		}
+	}
+	if u := normalizeUsername(msg.Metadata["sender_username"]); u != "" {
+		keys = append(keys, "telegram:"+u)
+	}
+	return keys
+}
+
+// Match returns the contact the message's sender belongs to, or nil. It is
+// safe to call on a nil Book.
+func (b *Book) Match(msg *message.Message) *Contact {
+	if b == nil {
+		return nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, k := range senderKeys(msg) {
+		if i, ok := b.index[k]; ok {
+			c := b.contacts[i]
+			return &c
+		}
+	}
+	return nil
+}
+
+// All returns a copy of the contacts.
+func (b *Book) All() []Contact {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]Contact(nil), b.contacts...)
+}
+
+// YAML returns the address book as it would be saved.
+func (b *Book) YAML() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.contacts) == 0 {
+		return ""
+	}
+	data, _ := yaml.Marshal(bookFile{Contacts: b.contacts})
+	return string(data)
+}
+
+// Path returns the file the address book is saved to.
+func (b *Book) Path() string {
+	return b.path
+}
+
+// Replace validates contacts, saves them and makes them the address book.
+func (b *Book) Replace(contacts []Contact) error {
+	for i := range contacts {
+		if err := contacts[i].validate(); err != nil {
+			return err
+		}
+	}
+	index, err := buildIndex(contacts)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(bookFile{Contacts: contacts})
+	if err != nil {
+		return fmt.Errorf("failed to encode contacts: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := writeFile(b.path, data); err != nil {
+		return err
+	}
+	b.contacts, b.index = contacts, index
+	slog.Info("Contacts updated", "path", b.path, "contacts", len(contacts))
+	return nil
+}
+
+// writeFile replaces path atomically so a crash can't leave half a file.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create contacts directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".contacts-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write contacts: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write contacts: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write contacts: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save contacts: %w", err)
+	}
+	return nil
+}
+
+// normalizePhone keeps only the digits, so "+1 (555) 010-9999" matches the
+// "15550109999" WhatsApp and Telegram report.
+func normalizePhone(p string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, p)
+}
+
+func normalizeUsername(u string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(u), "@"))
+}
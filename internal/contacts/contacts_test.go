@@ -0,0 +1,152 @@
+package contacts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+const book = `
+contacts:
+  - name: Ann Lee
+    vip: true
+    phones: ["+1 (555) 010-9999"]
+    emails: [ann@example.com, ann.lee@home.example]
+    slack_ids: [u012abc]
+    telegram_usernames: ["@AnnLee"]
+  - name: Marketing
+    blocked: true
+    emails: ["@promo.example"]
+  - name: Landlord
+    priority: low
+    phones: ["+44 20 7946 0958"]
+`
+
+func loadBook(t *testing.T, data string) (*Book, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "contacts.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestMatch(t *testing.T) {
+	b, err := loadBook(t, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		source    message.Source
+		sender    string
+		metadata  map[string]string
+		want      string // contact name, "" for none
+		wantLabel string
+	}{
+		{"phone in another format", message.SourceWhatsApp, "Ann", map[string]string{"sender_phone": "15550109999"}, "Ann Lee", "vip"},
+		{"email", message.SourceGmail, "Ann", map[string]string{"sender_email": "ANN@example.com"}, "Ann Lee", "vip"},
+		{"second email", message.SourceGmail, "A. Lee", map[string]string{"sender_email": "ann.lee@home.example"}, "Ann Lee", "vip"},
+		{"slack user id", message.SourceSlack, "ann", map[string]string{"user_id": "U012ABC"}, "Ann Lee", "vip"},
+		{"slack id on another platform", message.SourceTelegram, "ann", map[string]string{"user_id": "U012ABC"}, "", ""},
+		{"telegram username", message.SourceTelegram, "Ann", map[string]string{"sender_username": "annlee"}, "Ann Lee", "vip"},
+		{"blocked domain", message.SourceGmail, "Deals", map[string]string{"sender_email": "news@promo.example"}, "Marketing", "blocked"},
+		{"subdomain isn't the domain", message.SourceGmail, "Deals", map[string]string{"sender_email": "news@mail.promo.example"}, "", ""},
+		{"priority override", message.SourceWhatsApp, "Landlord", map[string]string{"sender_phone": "442079460958"}, "Landlord", "low"},
+		{"display name alone", message.SourceWhatsApp, "Ann Lee", nil, "", ""},
+		{"unknown sender", message.SourceGmail, "Bob", map[string]string{"sender_email": "bob@example.com"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := message.NewMessage(tt.source, tt.sender, "hi")
+			for k, v := range tt.metadata {
+				msg.Metadata[k] = v
+			}
+			c := b.Match(msg)
+			switch {
+			case tt.want == "" && c != nil:
+				t.Errorf("Match = %q, want no contact", c.Name)
+			case tt.want != "" && c == nil:
+				t.Errorf("Match = nil, want %q", tt.want)
+			case c != nil && (c.Name != tt.want || c.Label() != tt.wantLabel):
+				t.Errorf("Match = %q (%s), want %q (%s)", c.Name, c.Label(), tt.want, tt.wantLabel)
+			}
+		})
+	}
+
+	var nilBook *Book
+	if c := nilBook.Match(message.NewMessage(message.SourceGmail, "Ann", "hi")); c != nil {
+		t.Errorf("nil Book matched %q", c.Name)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"not yaml", "contacts: [", "failed to parse contacts"},
+		{"no name", "contacts: [{emails: [a@b.io]}]", "needs a name"},
+		{"vip and blocked", "contacts: [{name: A, vip: true, blocked: true, emails: [a@b.io]}]", "both vip and blocked"},
+		{"unknown priority", "contacts: [{name: A, priority: urgent, emails: [a@b.io]}]", "unknown priority"},
+		{"no identities", "contacts: [{name: A, phones: [\"n/a\"]}]", "has no phones"},
+		{"listed twice", "contacts: [{name: A, emails: [a@b.io]}, {name: B, emails: [A@B.IO]}]", "listed for both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadBook(t, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	b, err := Load(filepath.Join(t.TempDir(), "contacts.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.All()) != 0 || b.YAML() != "" {
+		t.Errorf("missing file gave %d contacts", len(b.All()))
+	}
+}
+
+func TestReplace(t *testing.T) {
+	b, err := loadBook(t, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := message.NewMessage(message.SourceTelegram, "Bob", "hi")
+	msg.Metadata["sender_username"] = "bob"
+
+	// An invalid book is rejected and the old one kept
+	bad := []Contact{{Name: "A", Emails: []string{"a@b.io"}}, {Name: "B", Emails: []string{"a@b.io"}}}
+	if err := b.Replace(bad); err == nil {
+		t.Fatal("Replace accepted an address listed twice")
+	}
+	if len(b.All()) != 3 {
+		t.Errorf("failed Replace left %d contacts, want 3", len(b.All()))
+	}
+
+	if err := b.Replace([]Contact{{Name: " Bob ", Blocked: true, TelegramUsernames: []string{"@Bob"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if c := b.Match(msg); c == nil || c.Name != "Bob" || !c.Blocked {
+		t.Errorf("Match after Replace = %+v, want Bob, blocked", c)
+	}
+
+	// The saved file loads back the same
+	reloaded, err := Load(b.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := reloaded.Match(msg); c == nil || c.Name != "Bob" {
+		t.Errorf("Match after reloading = %+v, want Bob", c)
+	}
+}
@@ -0,0 +1,232 @@
+// Package feedback records the user's corrections to classifications and
+// serves them back to the classifier as few-shot examples.
+package feedback
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// Labels a correction can carry.
+const (
+	LabelUrgent          = "urgent"            // should have been urgent
+	LabelNotUrgent       = "not_urgent"        // should not have been urgent
+	LabelWrongActionItem = "wrong_action_item" // an extracted action item was wrong
+)
+
+// maxTextRunes bounds how much of a message is kept with its correction.
+const maxTextRunes = 500
+
+// maxLoaded bounds how many corrections are kept in memory.
+const maxLoaded = 1000
+
+// Correction is a labeled example persisted as one JSON line.
+type Correction struct {
+	Source     message.Source `json:"source"`
+	Account    string         `json:"account,omitempty"`
+	Sender     string         `json:"sender"`
+	MessageID  string         `json:"message_id,omitempty"`
+	Text       string         `json:"text"`
+	Label      string         `json:"label"`
+	ActionItem string         `json:"action_item,omitempty"` // title, for wrong_action_item
+	Note       string         `json:"note,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// Store appends corrections to a JSON Lines file and keeps the most recent
+// ones in memory.
+type Store struct {
+	path string
+
+	mu          sync.RWMutex
+	corrections []Correction // oldest first
+}
+
+// NewStore opens the corrections file at path, loading what it already holds.
+// A missing file is created on the first correction.
+func NewStore(path string) (*Store, error) {
+	if path == "" {
+		path = "./feedback.jsonl"
+	}
+	s := &Store{path: path}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feedback file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var c Correction
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			slog.Warn("Skipping malformed feedback line", "path", path, "line", line, "error", err)
+			continue
+		}
+		s.corrections = append(s.corrections, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feedback file: %w", err)
+	}
+	if len(s.corrections) > maxLoaded {
+		s.corrections = s.corrections[len(s.corrections)-maxLoaded:]
+	}
+
+	slog.Info("Loaded classification feedback", "path", path, "corrections", len(s.corrections))
+	return s, nil
+}
+
+// ValidLabel reports whether label is one of the known labels.
+func ValidLabel(label string) bool {
+	switch label {
+	case LabelUrgent, LabelNotUrgent, LabelWrongActionItem:
+		return true
+	}
+	return false
+}
+
+// NewCorrection builds a correction for msg.
+func NewCorrection(msg *message.Message, label, actionItem, note string) Correction {
+	text := msg.Text
+	if r := []rune(text); len(r) > maxTextRunes {
+		text = string(r[:maxTextRunes])
+	}
+	return Correction{
+		Source:     msg.Source,
+		Account:    msg.Metadata["account"],
+		Sender:     msg.Sender,
+		MessageID:  msg.ID,
+		Text:       text,
+		Label:      label,
+		ActionItem: actionItem,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// Add persists a correction. Repeating a correction for the same message is
+// a no-op.
+func (s *Store) Add(c Correction) error {
+	if !ValidLabel(c.Label) {
+		return fmt.Errorf("unknown label %q", c.Label)
+	}
+	if c.Label == LabelWrongActionItem && c.ActionItem == "" {
+		return fmt.Errorf("%s needs the action item's title", LabelWrongActionItem)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.corrections {
+		if c.MessageID != "" && existing.MessageID == c.MessageID && existing.Source == c.Source &&
+			existing.Label == c.Label && existing.ActionItem == c.ActionItem {
+			return nil
+		}
+	}
+
+	line, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode feedback: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create feedback directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open feedback file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write feedback: %w", err)
+	}
+
+	s.corrections = append(s.corrections, c)
+	if len(s.corrections) > maxLoaded {
+		s.corrections = s.corrections[1:]
+	}
+	return nil
+}
+
+// Recent returns up to limit corrections, newest first.
+func (s *Store) Recent(limit int) []Correction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit <= 0 || limit > len(s.corrections) {
+		limit = len(s.corrections)
+	}
+	result := make([]Correction, 0, limit)
+	for i := len(s.corrections) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, s.corrections[i])
+	}
+	return result
+}
+
+// Examples implements classifier.ExampleSource: the most recent corrections
+// from the same sender and source come first, then others from the source.
+func (s *Store) Examples(msg *message.Message, limit int) []classifier.Example {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sameSender, sameSource []Correction
+	for i := len(s.corrections) - 1; i >= 0; i-- {
+		c := s.corrections[i]
+		if c.Source != msg.Source {
+			continue
+		}
+		if strings.EqualFold(c.Sender, msg.Sender) {
+			sameSender = append(sameSender, c)
+		} else {
+			sameSource = append(sameSource, c)
+		}
+	}
+
+	var examples []classifier.Example
+	for _, c := range append(sameSender, sameSource...) {
+		if len(examples) >= limit {
+			break
+		}
+		examples = append(examples, classifier.Example{
+			Source:     c.Source,
+			Sender:     c.Sender,
+			Text:       c.Text,
+			Correction: c.describe(),
+		})
+	}
+	return examples
+}
+
+// describe phrases the correction for the classifier's prompt.
+func (c Correction) describe() string {
+	var d string
+	switch c.Label {
+	case LabelUrgent:
+		d = "should have been urgent"
+	case LabelNotUrgent:
+		d = "should NOT have been urgent"
+	case LabelWrongActionItem:
+		d = fmt.Sprintf("%q was not a real action item", c.ActionItem)
+	}
+	if c.Note != "" {
+		d += " (" + c.Note + ")"
+	}
+	return d
+}
@@ -0,0 +1,203 @@
+package googleauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"github.com/emirlan/notifylm/internal/login"
+)
+
+// TerminalAuthorizer prints the consent URL and reads the authorization code
+// from stdin.
+type TerminalAuthorizer struct{}
+
+// terminalMu keeps concurrent authorizations from interleaving their prompts.
+var terminalMu sync.Mutex
+
+func (TerminalAuthorizer) Authorize(ctx context.Context, name string, cfg *oauth2.Config) (*oauth2.Token, error) {
+	terminalMu.Lock()
+	defer terminalMu.Unlock()
+
+	verifier := oauth2.GenerateVerifier()
+	authURL := cfg.AuthCodeURL(randomState(), oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(verifier))
+	fmt.Printf("Open this URL in your browser to authorize %s:\n%s\n\n", name, authURL)
+	fmt.Print("Enter authorization code: ")
+
+	var code string
+	if _, err := fmt.Scan(&code); err != nil {
+		return nil, fmt.Errorf("failed to read auth code: %w", err)
+	}
+
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	return token, nil
+}
+
+// WebAuthorizer runs the consent flow from the dashboard: the user follows a
+// "Connect Google account" link, Google redirects back to a loopback URL
+// served by the dashboard, and the code is exchanged using PKCE. Mount it on
+// the dashboard under /oauth/google/.
+type WebAuthorizer struct {
+	broker      *login.Broker
+	redirectURL string
+
+	mu      sync.Mutex
+	pending map[string]*webFlow // keyed by OAuth state
+}
+
+type webFlow struct {
+	name     string
+	cfg      oauth2.Config
+	verifier string
+	result   chan *oauth2.Token
+}
+
+// NewWebAuthorizer creates a web authorizer. redirectURL must point at the
+// dashboard's /oauth/google/callback, e.g. "http://localhost:8080/oauth/google/callback";
+// Google accepts any loopback port for desktop app credentials.
+func NewWebAuthorizer(broker *login.Broker, redirectURL string) *WebAuthorizer {
+	return &WebAuthorizer{
+		broker:      broker,
+		redirectURL: redirectURL,
+		pending:     make(map[string]*webFlow),
+	}
+}
+
+// Authorize shows a connect link on the dashboard and blocks until the user
+// completes the consent screen or ctx is cancelled.
+func (w *WebAuthorizer) Authorize(ctx context.Context, name string, cfg *oauth2.Config) (*oauth2.Token, error) {
+	state := randomState()
+	flow := &webFlow{
+		name:     name,
+		cfg:      *cfg,
+		verifier: oauth2.GenerateVerifier(),
+		result:   make(chan *oauth2.Token, 1),
+	}
+	flow.cfg.RedirectURL = w.redirectURL
+
+	w.mu.Lock()
+	w.pending[state] = flow
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.pending, state)
+		w.mu.Unlock()
+		w.broker.Done(name)
+	}()
+
+	w.broker.ShowLink(name, "/oauth/google/start?state="+url.QueryEscape(state),
+		"Connect Google account",
+		fmt.Sprintf("notifylm needs access to your Google account for %s (%s).", name, scopeNames(cfg.Scopes)))
+
+	select {
+	case token := <-flow.result:
+		return token, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (w *WebAuthorizer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/oauth/google/") {
+	case "start":
+		w.handleStart(rw, r)
+	case "callback":
+		w.handleCallback(rw, r)
+	default:
+		http.NotFound(rw, r)
+	}
+}
+
+func (w *WebAuthorizer) flow(state string) (*webFlow, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f, ok := w.pending[state]
+	return f, ok
+}
+
+func (w *WebAuthorizer) handleStart(rw http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	f, ok := w.flow(state)
+	if !ok {
+		http.Error(rw, "This authorization request has expired. Reload the dashboard and try again.", http.StatusNotFound)
+		return
+	}
+
+	authURL := f.cfg.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.ApprovalForce, // make sure Google returns a refresh token
+		oauth2.SetAuthURLParam("include_granted_scopes", "true"),
+		oauth2.S256ChallengeOption(f.verifier))
+	http.Redirect(rw, r, authURL, http.StatusFound)
+}
+
+func (w *WebAuthorizer) handleCallback(rw http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, ok := w.flow(q.Get("state"))
+	if !ok {
+		http.Error(rw, "Unknown or expired authorization request.", http.StatusBadRequest)
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		slog.Warn("Google authorization denied", "name", f.name, "error", e)
+		writeResult(rw, "Authorization was not granted: "+e)
+		return
+	}
+
+	token, err := f.cfg.Exchange(r.Context(), q.Get("code"), oauth2.VerifierOption(f.verifier))
+	if err != nil {
+		slog.Error("Failed to exchange Google authorization code", "name", f.name, "error", err)
+		writeResult(rw, "Failed to complete authorization. Check the logs and try again.")
+		return
+	}
+
+	select {
+	case f.result <- token:
+	default:
+	}
+	slog.Info("Google account connected", "name", f.name)
+	writeResult(rw, fmt.Sprintf("Google account connected for %s.", f.name))
+}
+
+var resultTmpl = template.Must(template.New("result").Parse(`<!DOCTYPE html>
+<html lang="en"><head><meta charset="UTF-8"><title>notifylm</title></head>
+<body style="font-family: 'Helvetica Neue', Helvetica, Arial, sans-serif; max-width: 440px; margin: 64px auto; padding: 0 24px;">
+<p>{{.}}</p>
+<p><a href="/">Back to dashboard</a></p>
+</body></html>`))
+
+func writeResult(rw http.ResponseWriter, msg string) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := resultTmpl.Execute(rw, msg); err != nil {
+		slog.Error("Failed to render authorization result", "error", err)
+	}
+}
+
+// scopeNames shortens scope URLs for display, e.g. "gmail.readonly".
+func scopeNames(scopes []string) string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = strings.TrimPrefix(s, "https://www.googleapis.com/auth/")
+	}
+	return strings.Join(names, ", ")
+}
+
+func randomState() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -4,78 +4,213 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+// Authorizer obtains a new token from the user. name identifies what is
+// asking (e.g. "gmail:work" or "calendar") in prompts.
+type Authorizer interface {
+	Authorize(ctx context.Context, name string, cfg *oauth2.Config) (*oauth2.Token, error)
+}
+
+// storedToken is the on-disk token format. Scopes records what the token was
+// granted so a token shared by Gmail and Calendar can be extended
+// incrementally; it is empty for tokens written by older versions.
+type storedToken struct {
+	oauth2.Token
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// tokenLocks serializes reads and writes per token file, so services sharing
+// a token don't clobber each other's writes. They are only held briefly.
+var tokenLocks sync.Map // token path -> *sync.Mutex
+
+// authLocks serializes consent flows per token file, so services sharing a
+// token don't run two at once. They are held while waiting for the user,
+// which may take forever, so token refreshes must never wait on them.
+var authLocks sync.Map // token path -> *sync.Mutex
+
+func lockToken(path string) func() {
+	return lockPath(&tokenLocks, path)
+}
+
+func lockAuth(path string) func() {
+	return lockPath(&authLocks, path)
+}
+
+func lockPath(locks *sync.Map, path string) func() {
+	mu, _ := locks.LoadOrStore(path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
 // GetOAuth2Client returns an authenticated HTTP client using stored or new OAuth2 credentials.
 // It loads credentials from credentialsPath, attempts to load a cached token from tokenPath,
-// and falls back to an interactive OAuth flow if no valid token is found.
-func GetOAuth2Client(ctx context.Context, credentialsPath, tokenPath string, scopes ...string) (*http.Client, error) {
+// and falls back to authz if no token is found or the stored one lacks some of the
+// requested scopes. Refreshed tokens are written back to tokenPath.
+func GetOAuth2Client(ctx context.Context, name, credentialsPath, tokenPath string, authz Authorizer, scopes ...string) (*http.Client, error) {
 	creds, err := os.ReadFile(credentialsPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read credentials: %w", err)
 	}
 
-	cfg, err := google.ConfigFromJSON(creds, scopes...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	stored, err := loadTokenLocked(tokenPath)
+	if err != nil || !hasScopes(stored.Scopes, scopes) {
+		unlockAuth := lockAuth(tokenPath)
+		defer unlockAuth()
+		// Another service may have been granted what we need meanwhile
+		stored, err = loadTokenLocked(tokenPath)
 	}
+	if err != nil || !hasScopes(stored.Scopes, scopes) {
+		// Ask for everything the token already covers plus what's new, so the
+		// token keeps serving the other services that share it.
+		want := scopes
+		if stored != nil {
+			want = unionScopes(stored.Scopes, scopes)
+		}
+		cfg, err := google.ConfigFromJSON(creds, want...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse credentials: %w", err)
+		}
 
-	token, err := loadToken(tokenPath)
-	if err != nil {
-		token, err = authorize(ctx, cfg, tokenPath)
+		slog.Info("Google authorization required", "name", name, "scopes", strings.Join(want, " "))
+		token, err := authz.Authorize(ctx, name, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to authorize: %w", err)
 		}
+
+		stored = &storedToken{Token: *token, Scopes: grantedScopes(token, want)}
+		unlock := lockToken(tokenPath)
+		err = saveToken(tokenPath, stored)
+		unlock()
+		if err != nil {
+			slog.Warn("Failed to save Google token", "path", tokenPath, "error", err)
+		}
 	}
 
-	return cfg.Client(ctx, token), nil
+	cfg, err := google.ConfigFromJSON(creds, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+
+	src := &persistingTokenSource{
+		base:   cfg.TokenSource(ctx, &stored.Token),
+		path:   tokenPath,
+		scopes: stored.Scopes,
+		last:   stored.AccessToken,
+	}
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(&stored.Token, src)), nil
 }
 
-func loadToken(path string) (*oauth2.Token, error) {
-	data, err := os.ReadFile(path)
+// persistingTokenSource writes refreshed tokens back to disk, so a restart
+// doesn't start from an expired access token.
+type persistingTokenSource struct {
+	base   oauth2.TokenSource
+	path   string
+	scopes []string
+
+	mu   sync.Mutex
+	last string // access token last written
+}
+
+func (p *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := p.base.Token()
 	if err != nil {
 		return nil, err
 	}
 
-	var token oauth2.Token
-	if err := json.Unmarshal(data, &token); err != nil {
-		return nil, err
-	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	return &token, nil
+	if token.AccessToken != p.last {
+		if err := p.save(token); err != nil {
+			slog.Warn("Failed to save refreshed Google token", "path", p.path, "error", err)
+		} else {
+			p.last = token.AccessToken
+		}
+	}
+	return token, nil
 }
 
-func authorize(ctx context.Context, cfg *oauth2.Config, tokenPath string) (*oauth2.Token, error) {
-	authURL := cfg.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Open this URL in your browser:\n%s\n\n", authURL)
-	fmt.Print("Enter authorization code: ")
+func (p *persistingTokenSource) save(token *oauth2.Token) error {
+	unlock := lockToken(p.path)
+	defer unlock()
 
-	var code string
-	if _, err := fmt.Scan(&code); err != nil {
-		return nil, fmt.Errorf("failed to read auth code: %w", err)
+	// Another service sharing the file may have re-authorized with more
+	// scopes since we loaded it; its grant must not be overwritten by ours.
+	if current, err := loadToken(p.path); err == nil &&
+		current.RefreshToken != "" && current.RefreshToken != token.RefreshToken {
+		return nil
 	}
+	return saveToken(p.path, &storedToken{Token: *token, Scopes: p.scopes})
+}
+
+// loadTokenLocked reads the token file, waiting for writes in progress.
+func loadTokenLocked(path string) (*storedToken, error) {
+	unlock := lockToken(path)
+	defer unlock()
+	return loadToken(path)
+}
 
-	token, err := cfg.Exchange(ctx, code)
+func loadToken(path string) (*storedToken, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
+		return nil, err
 	}
 
-	if err := saveToken(tokenPath, token); err != nil {
-		fmt.Printf("Warning: failed to save token: %v\n", err)
+	var token storedToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
 	}
 
-	return token, nil
+	return &token, nil
 }
 
-func saveToken(path string, token *oauth2.Token) error {
+func saveToken(path string, token *storedToken) error {
 	data, err := json.MarshalIndent(token, "", "  ")
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(path, data, 0600)
 }
+
+// hasScopes reports whether granted covers want. Tokens from older versions
+// don't record their scopes and are assumed to cover what they're used for.
+func hasScopes(granted, want []string) bool {
+	if len(granted) == 0 {
+		return true
+	}
+	for _, s := range want {
+		if !slices.Contains(granted, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func unionScopes(a, b []string) []string {
+	result := slices.Clone(a)
+	for _, s := range b {
+		if !slices.Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// grantedScopes returns the scopes Google reports in the token response,
+// falling back to the requested ones.
+func grantedScopes(token *oauth2.Token, requested []string) []string {
+	if s, ok := token.Extra("scope").(string); ok && s != "" {
+		return strings.Fields(s)
+	}
+	return requested
+}
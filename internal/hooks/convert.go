@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"fmt"
+	"time"
+
+	"go.starlark.net/starlark"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// messageDict exposes msg to a script. Only text, sender and metadata are
+// read back.
+func messageDict(msg *message.Message) *starlark.Dict {
+	meta := starlark.NewDict(len(msg.Metadata))
+	for k, v := range msg.Metadata {
+		_ = meta.SetKey(starlark.String(k), starlark.String(v))
+	}
+
+	d := starlark.NewDict(6)
+	_ = d.SetKey(starlark.String("id"), starlark.String(msg.ID))
+	_ = d.SetKey(starlark.String("source"), starlark.String(msg.Source))
+	_ = d.SetKey(starlark.String("sender"), starlark.String(msg.Sender))
+	_ = d.SetKey(starlark.String("text"), starlark.String(msg.Text))
+	_ = d.SetKey(starlark.String("timestamp"), starlark.String(msg.Timestamp.Format(time.RFC3339)))
+	_ = d.SetKey(starlark.String("metadata"), meta)
+	return d
+}
+
+// applyMessage copies a script's changes back into msg.
+func applyMessage(d *starlark.Dict, msg *message.Message) error {
+	text, err := stringField(d, "text")
+	if err != nil {
+		return err
+	}
+	sender, err := stringField(d, "sender")
+	if err != nil {
+		return err
+	}
+
+	v, _, _ := d.Get(starlark.String("metadata"))
+	meta, ok := v.(*starlark.Dict)
+	if !ok {
+		return fmt.Errorf("msg[\"metadata\"] must be a dict, got %s", typeOf(v))
+	}
+	metadata := make(map[string]string, meta.Len())
+	for _, item := range meta.Items() {
+		k, ok := starlark.AsString(item[0])
+		if !ok {
+			return fmt.Errorf("metadata keys must be strings, got %s", item[0].Type())
+		}
+		if s, ok := starlark.AsString(item[1]); ok {
+			metadata[k] = s
+		} else {
+			metadata[k] = item[1].String()
+		}
+	}
+
+	msg.Text, msg.Sender, msg.Metadata = text, sender, metadata
+	return nil
+}
+
+// resultDict exposes a classification to a script.
+func resultDict(result *classifier.ClassificationResult) *starlark.Dict {
+	items := make([]starlark.Value, 0, len(result.ActionItems))
+	for _, item := range result.ActionItems {
+		d := starlark.NewDict(5)
+		_ = d.SetKey(starlark.String("title"), starlark.String(item.Title))
+		_ = d.SetKey(starlark.String("description"), starlark.String(item.Description))
+		var dt starlark.Value = starlark.None
+		switch {
+		case item.DateTime.IsZero():
+		case item.AllDay:
+			dt = starlark.String(item.DateTime.Format(time.DateOnly))
+		default:
+			dt = starlark.String(item.DateTime.Format(time.RFC3339))
+		}
+		_ = d.SetKey(starlark.String("datetime"), dt)
+		_ = d.SetKey(starlark.String("all_day"), starlark.Bool(item.AllDay))
+		_ = d.SetKey(starlark.String("duration_minutes"), starlark.MakeInt(item.DurationMinutes))
+		items = append(items, d)
+	}
+
+	d := starlark.NewDict(2)
+	_ = d.SetKey(starlark.String("urgent"), starlark.Bool(result.IsUrgent))
+	_ = d.SetKey(starlark.String("action_items"), starlark.NewList(items))
+	return d
+}
+
+// applyResult copies a script's changes back into result.
+func applyResult(d *starlark.Dict, result *classifier.ClassificationResult) error {
+	v, _, _ := d.Get(starlark.String("urgent"))
+	urgent, ok := v.(starlark.Bool)
+	if !ok {
+		return fmt.Errorf("result[\"urgent\"] must be a bool, got %s", typeOf(v))
+	}
+
+	v, _, _ = d.Get(starlark.String("action_items"))
+	list, ok := v.(*starlark.List)
+	if !ok {
+		return fmt.Errorf("result[\"action_items\"] must be a list, got %s", typeOf(v))
+	}
+	items := make([]classifier.ActionItem, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		item, ok := list.Index(i).(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("action_items[%d] must be a dict", i)
+		}
+		var a classifier.ActionItem
+		var err error
+		if a.Title, err = stringField(item, "title"); err != nil {
+			return fmt.Errorf("action_items[%d]: %w", i, err)
+		}
+		if v, found, _ := item.Get(starlark.String("description")); found && v != starlark.None {
+			a.Description, _ = starlark.AsString(v)
+		}
+		if v, found, _ := item.Get(starlark.String("datetime")); found && v != starlark.None {
+			s, _ := starlark.AsString(v)
+			if a.DateTime, a.AllDay, err = classifier.ParseDateTime(s, time.Now()); err != nil {
+				return fmt.Errorf("action_items[%d]: datetime must be RFC 3339, a local date and time or a date, got %q", i, s)
+			}
+		}
+		if v, found, _ := item.Get(starlark.String("all_day")); found && v != starlark.None {
+			if a.AllDay = bool(v.Truth()) && !a.DateTime.IsZero(); a.AllDay {
+				y, m, d := a.DateTime.Date()
+				a.DateTime = time.Date(y, m, d, 0, 0, 0, 0, a.DateTime.Location())
+			}
+		}
+		a.DurationMinutes = 30
+		if v, found, _ := item.Get(starlark.String("duration_minutes")); found && v != starlark.None {
+			n, err := starlark.AsInt32(v)
+			if err != nil {
+				return fmt.Errorf("action_items[%d]: duration_minutes: %w", i, err)
+			}
+			a.DurationMinutes = n
+		}
+		items = append(items, a)
+	}
+
+	result.IsUrgent = bool(urgent)
+	result.ActionItems = items
+	return nil
+}
+
+func stringField(d *starlark.Dict, key string) (string, error) {
+	v, _, _ := d.Get(starlark.String(key))
+	s, ok := starlark.AsString(v)
+	if !ok {
+		return "", fmt.Errorf("%q must be a string, got %s", key, typeOf(v))
+	}
+	return s, nil
+}
+
+func typeOf(v starlark.Value) string {
+	if v == nil {
+		return "nothing"
+	}
+	return v.Type()
+}
@@ -0,0 +1,292 @@
+// Package hooks runs a user-supplied Starlark script at fixed points of
+// message handling, for logic that declarative rules can't express. Scripts
+// have no file, network or module access, and every call is bounded by a
+// time limit and an execution step budget.
+//
+// A script defines any of these functions:
+//
+//	def on_receive(msg): ...             # before contacts, rules and the LLM
+//	def after_classify(msg, result): ... # once urgency is decided
+//	def before_notify(msg, reason): ...  # for each push notification
+//
+// msg is a dict with id, source, sender, text, timestamp and metadata;
+// result has urgent and action_items. Changes to text, sender, metadata,
+// urgent and action_items are kept. Returning False suppresses the message
+// (on_receive), its notifications (after_classify) or one notification
+// (before_notify).
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// Hook names.
+const (
+	OnReceive     = "on_receive"
+	AfterClassify = "after_classify"
+	BeforeNotify  = "before_notify"
+)
+
+// Engine holds the loaded script and reloads it when the file changes.
+type Engine struct {
+	path     string
+	timeout  time.Duration
+	maxSteps uint64
+
+	mu      sync.RWMutex
+	globals starlark.StringDict
+	modTime time.Time
+}
+
+// New loads the script at cfg.Path. A missing file means no hooks until one
+// is created.
+func New(cfg config.HooksConfig) (*Engine, error) {
+	e := &Engine{
+		path:     cfg.Path,
+		timeout:  time.Duration(cfg.TimeoutMillis) * time.Millisecond,
+		maxSteps: cfg.MaxSteps,
+	}
+	if e.path == "" {
+		e.path = "./hooks.star"
+	}
+	if e.timeout <= 0 {
+		e.timeout = 200 * time.Millisecond
+	}
+	if e.maxSteps == 0 {
+		e.maxSteps = 1_000_000
+	}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// predeclared is what scripts can use beyond the Starlark builtins.
+var predeclared = starlark.StringDict{
+	"json": json.Module,
+	"math": math.Module,
+}
+
+// fileOptions allows the usual Python-like statements at the top level.
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// reload re-reads the script if it changed since the last load. The current
+// script is kept when the new one fails to run.
+func (e *Engine) reload() error {
+	info, err := os.Stat(e.path)
+	if errors.Is(err, os.ErrNotExist) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if !e.modTime.IsZero() {
+			slog.Info("Hooks script removed, no hooks apply", "path", e.path)
+			e.globals, e.modTime = nil, time.Time{}
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read hooks script: %w", err)
+	}
+
+	e.mu.RLock()
+	unchanged := info.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	src, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to read hooks script: %w", err)
+	}
+	thread := e.newThread("load")
+	stop := e.deadline(thread)
+	globals, err := starlark.ExecFileOptions(fileOptions, thread, e.path, src, predeclared)
+	stop()
+	if err != nil {
+		return fmt.Errorf("failed to load hooks script %s: %w", e.path, describe(err))
+	}
+
+	var defined []string
+	for _, name := range []string{OnReceive, AfterClassify, BeforeNotify} {
+		if v, ok := globals[name]; ok {
+			if _, ok := v.(starlark.Callable); !ok {
+				return fmt.Errorf("hooks script %s: %s must be a function", e.path, name)
+			}
+			defined = append(defined, name)
+		}
+	}
+
+	e.mu.Lock()
+	e.globals, e.modTime = globals, info.ModTime()
+	e.mu.Unlock()
+
+	slog.Info("Loaded hooks", "path", e.path, "hooks", defined)
+	return nil
+}
+
+// Watch reloads the script whenever the file changes, until ctx is done.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.reload(); err != nil {
+				// Log a broken script once, not on every tick
+				if err.Error() != lastErr {
+					slog.Error("Failed to reload hooks, keeping the previous script", "error", err)
+					lastErr = err.Error()
+				}
+				continue
+			}
+			lastErr = ""
+		}
+	}
+}
+
+// Defined reports whether the script has the named hook. It is safe to call
+// on a nil Engine.
+func (e *Engine) Defined(name string) bool {
+	return e.hook(name) != nil
+}
+
+// OnReceive runs on_receive, applying its changes to msg. It reports false
+// if the hook suppressed the message.
+func (e *Engine) OnReceive(msg *message.Message) (bool, error) {
+	fn := e.hook(OnReceive)
+	if fn == nil {
+		return true, nil
+	}
+	m := messageDict(msg)
+	ret, err := e.call(fn, m)
+	if err != nil {
+		return true, err
+	}
+	if err := applyMessage(m, msg); err != nil {
+		return true, err
+	}
+	return ret != starlark.False, nil
+}
+
+// AfterClassify runs after_classify, applying its changes to msg and result.
+// It reports false if the hook suppressed the message's notifications.
+func (e *Engine) AfterClassify(msg *message.Message, result *classifier.ClassificationResult) (bool, error) {
+	fn := e.hook(AfterClassify)
+	if fn == nil {
+		return true, nil
+	}
+	m, r := messageDict(msg), resultDict(result)
+	ret, err := e.call(fn, m, r)
+	if err != nil {
+		return true, err
+	}
+	// Validate both before changing either, so a bad hook changes nothing.
+	updated := *result
+	if err := applyResult(r, &updated); err != nil {
+		return true, err
+	}
+	if err := applyMessage(m, msg); err != nil {
+		return true, err
+	}
+	updated.Trace = result.Trace
+	*result = updated
+	return ret != starlark.False, nil
+}
+
+// BeforeNotify runs before_notify on the message about to be pushed, which
+// the hook may rewrite. reason is "urgent", "action_item" or "vip". It
+// reports false if the hook suppressed the notification.
+func (e *Engine) BeforeNotify(msg *message.Message, reason string) (bool, error) {
+	fn := e.hook(BeforeNotify)
+	if fn == nil {
+		return true, nil
+	}
+	m := messageDict(msg)
+	ret, err := e.call(fn, m, starlark.String(reason))
+	if err != nil {
+		return true, err
+	}
+	if err := applyMessage(m, msg); err != nil {
+		return true, err
+	}
+	return ret != starlark.False, nil
+}
+
+func (e *Engine) hook(name string) starlark.Callable {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	fn, _ := e.globals[name].(starlark.Callable)
+	return fn
+}
+
+// call runs fn in a fresh sandboxed thread.
+func (e *Engine) call(fn starlark.Callable, args ...starlark.Value) (starlark.Value, error) {
+	thread := e.newThread(fn.Name())
+	stop := e.deadline(thread)
+	defer stop()
+
+	ret, err := starlark.Call(thread, fn, args, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn.Name(), describe(err))
+	}
+	return ret, nil
+}
+
+func (e *Engine) newThread(name string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			slog.Info("Hook output", "hook", name, "output", msg)
+		},
+		// Load is left nil so scripts can't import other files.
+	}
+	thread.SetMaxExecutionSteps(e.maxSteps)
+	return thread
+}
+
+// deadline cancels the thread once the time limit passes.
+func (e *Engine) deadline(thread *starlark.Thread) (stop func()) {
+	timer := time.AfterFunc(e.timeout, func() {
+		thread.Cancel(fmt.Sprintf("exceeded the %s time limit", e.timeout))
+	})
+	return func() { timer.Stop() }
+}
+
+// describe includes the Starlark backtrace in script errors.
+func describe(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// engine loads script from a temporary file.
+func engine(t *testing.T, script string, maxSteps uint64) *Engine {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hooks.star")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(config.HooksConfig{Path: path, TimeoutMillis: 10_000, MaxSteps: maxSteps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func newMessage(sender, text string) *message.Message {
+	msg := message.NewMessage(message.SourceSlack, sender, text)
+	msg.Metadata["channel_name"] = "alerts"
+	return msg
+}
+
+func TestOnReceive(t *testing.T) {
+	e := engine(t, `
+def on_receive(msg):
+    if msg["sender"] == "spam-bot":
+        return False
+    msg["text"] = msg["text"].replace("PROD", "production")
+    msg["sender"] = msg["sender"].title()
+    msg["metadata"]["team"] = "ops"
+    msg["metadata"]["attempt"] = 2
+    msg["metadata"].pop("channel_name")
+`, 0)
+
+	tests := []struct {
+		name     string
+		sender   string
+		text     string
+		want     bool
+		wantText string
+		wantFrom string
+		wantMeta map[string]string
+	}{
+		{
+			name: "rewrites the message", sender: "deploy bot", text: "PROD is down",
+			want: true, wantText: "production is down", wantFrom: "Deploy Bot",
+			wantMeta: map[string]string{"team": "ops", "attempt": "2"},
+		},
+		{
+			name: "suppresses the message", sender: "spam-bot", text: "Buy now",
+			want: false, wantText: "Buy now", wantFrom: "spam-bot",
+			wantMeta: map[string]string{"channel_name": "alerts"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newMessage(tt.sender, tt.text)
+			keep, err := e.OnReceive(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keep != tt.want {
+				t.Errorf("OnReceive kept the message = %v, want %v", keep, tt.want)
+			}
+			if msg.Text != tt.wantText || msg.Sender != tt.wantFrom {
+				t.Errorf("message is %q from %q, want %q from %q", msg.Text, msg.Sender, tt.wantText, tt.wantFrom)
+			}
+			if len(msg.Metadata) != len(tt.wantMeta) {
+				t.Errorf("metadata = %v, want %v", msg.Metadata, tt.wantMeta)
+			}
+			for k, v := range tt.wantMeta {
+				if msg.Metadata[k] != v {
+					t.Errorf("metadata[%q] = %q, want %q", k, msg.Metadata[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestAfterClassify(t *testing.T) {
+	e := engine(t, `
+def after_classify(msg, result):
+    if "outage" in msg["text"]:
+        result["urgent"] = True
+        result["action_items"].append({"title": "Open an incident", "datetime": "2025-03-15T14:00:00Z"})
+    if msg["metadata"].get("muted") == "yes":
+        return False
+    result["action_items"] = [i for i in result["action_items"] if i["title"] != "Ignore me"]
+`, 0)
+
+	tests := []struct {
+		name       string
+		text       string
+		meta       map[string]string
+		items      []string
+		want       bool
+		wantUrgent bool
+		wantItems  []string
+	}{
+		{
+			name: "raises urgency and adds a task", text: "Partial outage in eu-west",
+			want: true, wantUrgent: true, wantItems: []string{"Open an incident"},
+		},
+		{
+			name: "drops a task", text: "Weekly report", items: []string{"Read it", "Ignore me"},
+			want: true, wantItems: []string{"Read it"},
+		},
+		{
+			name: "suppresses notifications", text: "outage", meta: map[string]string{"muted": "yes"},
+			want: false, wantUrgent: true, wantItems: []string{"Open an incident"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newMessage("monitor", tt.text)
+			for k, v := range tt.meta {
+				msg.Metadata[k] = v
+			}
+			result := &classifier.ClassificationResult{}
+			for _, title := range tt.items {
+				result.ActionItems = append(result.ActionItems, classifier.ActionItem{Title: title})
+			}
+			notify, err := e.AfterClassify(msg, result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if notify != tt.want || result.IsUrgent != tt.wantUrgent {
+				t.Errorf("notify %v, urgent %v; want %v, %v", notify, result.IsUrgent, tt.want, tt.wantUrgent)
+			}
+			var titles []string
+			for _, item := range result.ActionItems {
+				titles = append(titles, item.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.wantItems, "|") {
+				t.Errorf("action items %q, want %q", titles, tt.wantItems)
+			}
+		})
+	}
+
+	// Dates written by the script are parsed like the LLM's
+	result := &classifier.ClassificationResult{}
+	if _, err := e.AfterClassify(newMessage("monitor", "outage"), result); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 3, 15, 14, 0, 0, 0, time.UTC); !result.ActionItems[0].DateTime.Equal(want) {
+		t.Errorf("datetime = %v, want %v", result.ActionItems[0].DateTime, want)
+	}
+}
+
+func TestAfterClassifyBadResult(t *testing.T) {
+	e := engine(t, `
+def after_classify(msg, result):
+    msg["text"] = "changed"
+    result["urgent"] = "yes"
+`, 0)
+	msg := newMessage("monitor", "original")
+	result := &classifier.ClassificationResult{IsUrgent: true}
+	if _, err := e.AfterClassify(msg, result); err == nil || !strings.Contains(err.Error(), "must be a bool") {
+		t.Fatalf("AfterClassify error = %v, want a type error", err)
+	}
+	if msg.Text != "original" || !result.IsUrgent {
+		t.Errorf("a failed hook changed the message to %q and urgency to %v", msg.Text, result.IsUrgent)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	e := engine(t, `
+def on_receive(msg):
+    n = 0
+    while True:
+        n += 1
+`, 10_000)
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.OnReceive(newMessage("bob", "hi"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "too many steps") {
+			t.Errorf("OnReceive error = %v, want the step limit", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the step limit didn't stop the script")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{"syntax error", "def on_receive(msg)\n    pass\n", "failed to load hooks script"},
+		{"not a function", "on_receive = 1\n", "on_receive must be a function"},
+		{"no imports", "load(\"os.star\", \"os\")\n", "failed to load hooks script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hooks.star")
+			if err := os.WriteFile(path, []byte(tt.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := New(config.HooksConfig{Path: path})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNilEngine(t *testing.T) {
+	var e *Engine
+	msg := newMessage("bob", "hi")
+	if keep, err := e.OnReceive(msg); !keep || err != nil {
+		t.Errorf("nil engine OnReceive = %v, %v", keep, err)
+	}
+	if e.Defined(OnReceive) {
+		t.Error("nil engine reports a defined hook")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log/slog"
+	"net/mail"
+	"strings"
 	"time"
 
 	"google.golang.org/api/gmail/v1"
@@ -22,21 +24,30 @@ type GmailListener struct {
 	service       *gmail.Service
 	out           chan<- *message.Message
 	lastHistoryID uint64
+	email         string
+	authz         googleauth.Authorizer
 }
 
 // NewGmailListener creates a new Gmail listener.
 func NewGmailListener(cfg config.GmailConfig) *GmailListener {
 	return &GmailListener{
-		BaseListener: NewBaseListener("gmail"),
+		BaseListener: NewBaseListener("gmail", message.SourceGmail, cfg.Name),
 		cfg:          cfg,
+		authz:        googleauth.TerminalAuthorizer{},
 	}
 }
 
+// UseGoogleAuthorizer replaces the terminal OAuth prompt, e.g. with the
+// dashboard's "Connect Google account" flow.
+func (g *GmailListener) UseGoogleAuthorizer(authz googleauth.Authorizer) {
+	g.authz = authz
+}
+
 func (g *GmailListener) Start(ctx context.Context, out chan<- *message.Message) error {
 	g.out = out
 
 	// Get authenticated client via shared OAuth2 helper
-	client, err := googleauth.GetOAuth2Client(ctx, g.cfg.CredentialsPath, g.cfg.TokenPath, gmail.GmailReadonlyScope)
+	client, err := googleauth.GetOAuth2Client(ctx, g.Name(), g.cfg.CredentialsPath, g.cfg.TokenPath, g.authz, gmail.GmailReadonlyScope)
 	if err != nil {
 		return fmt.Errorf("failed to get Gmail OAuth2 client: %w", err)
 	}
@@ -53,8 +64,9 @@ func (g *GmailListener) Start(ctx context.Context, out chan<- *message.Message)
 		return fmt.Errorf("failed to get profile: %w", err)
 	}
 	g.lastHistoryID = profile.HistoryId
+	g.email = profile.EmailAddress
 
-	slog.Info("Gmail listener started", "email", profile.EmailAddress)
+	slog.Info("Gmail listener started", "account", g.Name(), "email", profile.EmailAddress)
 
 	// Poll for new messages
 	pollInterval := time.Duration(g.cfg.PollInterval) * time.Second
@@ -142,16 +154,77 @@ func (g *GmailListener) processMessage(ctx context.Context, messageID string) er
 		text = fmt.Sprintf("Subject: %s\n\n%s", subject, body)
 	}
 
-	m := message.NewMessage(message.SourceGmail, from, text)
+	m := g.newMessage(from, text)
 	m.ID = messageID
 	m.Timestamp = time.UnixMilli(msg.InternalDate)
 	m.Metadata["subject"] = subject
 	m.Metadata["labels"] = fmt.Sprintf("%v", msg.LabelIds)
+	m.Metadata["account_email"] = g.email
+	m.Metadata["thread_id"] = msg.ThreadId
+	if addr, err := mail.ParseAddress(from); err == nil {
+		m.Metadata["sender_email"] = strings.ToLower(addr.Address)
+	}
+	if g.wantsMedia(message.AttachmentImage) {
+		m.Attachments = g.downloadImages(ctx, messageID, msg.Payload)
+	}
 
 	g.out <- m
 	return nil
 }
 
+// maxGmailImages caps how many image attachments are fetched per email, so
+// newsletters full of inline images don't trigger a flood of downloads.
+const maxGmailImages = 4
+
+// downloadImages fetches the image attachments of a message.
+func (g *GmailListener) downloadImages(ctx context.Context, messageID string, payload *gmail.MessagePart) []message.Attachment {
+	var attachments []message.Attachment
+	for _, part := range gmailImageParts(payload, nil) {
+		if len(attachments) >= maxGmailImages {
+			break
+		}
+		if part.Body.Size > maxMediaBytes {
+			slog.Warn("Skipping large Gmail attachment", "file", part.Filename, "size", part.Body.Size)
+			continue
+		}
+		encoded := part.Body.Data
+		if part.Body.AttachmentId != "" {
+			body, err := g.service.Users.Messages.Attachments.Get("me", messageID, part.Body.AttachmentId).
+				Context(ctx).
+				Do()
+			if err != nil {
+				slog.Warn("Failed to download Gmail attachment", "file", part.Filename, "error", err)
+				continue
+			}
+			encoded = body.Data
+		}
+		data, err := base64.URLEncoding.DecodeString(encoded)
+		if err != nil {
+			slog.Warn("Failed to decode Gmail attachment", "file", part.Filename, "error", err)
+			continue
+		}
+		attachments = append(attachments, message.Attachment{
+			Kind:     message.AttachmentImage,
+			MimeType: part.MimeType,
+			FileName: part.Filename,
+			Data:     data,
+		})
+	}
+	return attachments
+}
+
+// gmailImageParts collects the image parts of a message, depth first.
+func gmailImageParts(payload *gmail.MessagePart, parts []*gmail.MessagePart) []*gmail.MessagePart {
+	if strings.HasPrefix(payload.MimeType, "image/") && payload.Body != nil &&
+		(payload.Body.AttachmentId != "" || payload.Body.Data != "") {
+		parts = append(parts, payload)
+	}
+	for _, part := range payload.Parts {
+		parts = gmailImageParts(part, parts)
+	}
+	return parts
+}
+
 func extractGmailBody(payload *gmail.MessagePart) string {
 	// Try to get plain text body
 	if payload.MimeType == "text/plain" && payload.Body.Data != "" {
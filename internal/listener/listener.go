@@ -2,7 +2,9 @@ package listener
 
 import (
 	"context"
+	"strings"
 
+	"github.com/emirlan/notifylm/internal/login"
 	"github.com/emirlan/notifylm/internal/message"
 )
 
@@ -11,6 +13,12 @@ type Listener interface {
 	// Name returns the name of the listener for logging.
 	Name() string
 
+	// Source returns the platform this listener receives messages from.
+	Source() message.Source
+
+	// Account returns the configured account name, or "" for a single-account setup.
+	Account() string
+
 	// Start begins listening for messages and sends them to the output channel.
 	// It should block until the context is cancelled.
 	Start(ctx context.Context, out chan<- *message.Message) error
@@ -19,16 +27,95 @@ type Listener interface {
 	Stop() error
 }
 
+// MediaDownloader is implemented by listeners that can attach downloaded
+// media to the messages they emit.
+type MediaDownloader interface {
+	// DownloadMedia enables downloading attachments of the given kinds.
+	DownloadMedia(kinds ...message.AttachmentKind)
+}
+
+// InteractiveLogin is implemented by listeners whose first sign-in needs user
+// input. Without a broker they fall back to the terminal.
+type InteractiveLogin interface {
+	// UseLoginBroker routes QR codes and code/password prompts to the broker.
+	UseLoginBroker(b *login.Broker)
+}
+
+// maxMediaBytes caps the size of a downloaded attachment.
+const maxMediaBytes = 20 << 20
+
 // BaseListener provides common functionality for listeners.
 type BaseListener struct {
 	name    string
+	source  message.Source
+	account string
+	media   map[message.AttachmentKind]bool
+	login   *login.Broker
 	stopped bool
 }
 
-func NewBaseListener(name string) BaseListener {
-	return BaseListener{name: name}
+func NewBaseListener(name string, source message.Source, account string) BaseListener {
+	return BaseListener{name: name, source: source, account: account}
 }
 
+// Name returns the listener name, qualified with the account name if set
+// (e.g. "gmail:work").
 func (b *BaseListener) Name() string {
-	return b.name
+	if b.account == "" {
+		return b.name
+	}
+	return b.name + ":" + b.account
+}
+
+func (b *BaseListener) Source() message.Source {
+	return b.source
+}
+
+func (b *BaseListener) Account() string {
+	return b.account
+}
+
+// newMessage creates a message for this listener's source, tagged with the
+// account it was received on.
+func (b *BaseListener) newMessage(sender, text string) *message.Message {
+	m := message.NewMessage(b.source, sender, text)
+	if b.account != "" {
+		m.Metadata["account"] = b.account
+	}
+	return m
+}
+
+// DownloadMedia enables downloading attachments of the given kinds.
+func (b *BaseListener) DownloadMedia(kinds ...message.AttachmentKind) {
+	if b.media == nil {
+		b.media = make(map[message.AttachmentKind]bool)
+	}
+	for _, k := range kinds {
+		b.media[k] = true
+	}
+}
+
+// UseLoginBroker routes interactive login steps to the dashboard.
+func (b *BaseListener) UseLoginBroker(broker *login.Broker) {
+	b.login = broker
+}
+
+// wantsMedia reports whether attachments of the given kind should be downloaded.
+func (b *BaseListener) wantsMedia(kind message.AttachmentKind) bool {
+	return b.media[kind]
+}
+
+// audioFileName picks a file name whose extension matches the audio MIME type,
+// since transcription services detect the format from it.
+func audioFileName(mimeType string) string {
+	switch {
+	case strings.Contains(mimeType, "mp4"), strings.Contains(mimeType, "aac"):
+		return "audio.m4a"
+	case strings.Contains(mimeType, "mpeg"):
+		return "audio.mp3"
+	case strings.Contains(mimeType, "wav"):
+		return "audio.wav"
+	default:
+		return "audio.ogg"
+	}
 }
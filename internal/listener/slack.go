@@ -1,9 +1,11 @@
 package listener
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
@@ -13,22 +15,33 @@ import (
 	"github.com/emirlan/notifylm/internal/message"
 )
 
+// maxSeenSlackMessages bounds the set used to deduplicate message and
+// app_mention events that describe the same Slack message.
+const maxSeenSlackMessages = 1000
+
 // SlackListener implements the Listener interface for Slack Socket Mode.
 type SlackListener struct {
 	BaseListener
-	cfg       config.SlackConfig
-	api       *slack.Client
-	socket    *socketmode.Client
-	out       chan<- *message.Message
-	userCache map[string]string
+	cfg          config.SlackConfig
+	api          *slack.Client
+	socket       *socketmode.Client
+	out          chan<- *message.Message
+	selfUserID   string
+	teamID       string
+	teamURL      string
+	userCache    map[string]string
+	channelCache map[string]string
+	seen         map[string]struct{}
 }
 
 // NewSlackListener creates a new Slack listener.
 func NewSlackListener(cfg config.SlackConfig) *SlackListener {
 	return &SlackListener{
-		BaseListener: NewBaseListener("slack"),
+		BaseListener: NewBaseListener("slack", message.SourceSlack, cfg.Name),
 		cfg:          cfg,
 		userCache:    make(map[string]string),
+		channelCache: make(map[string]string),
+		seen:         make(map[string]struct{}),
 	}
 }
 
@@ -41,6 +54,16 @@ func (s *SlackListener) Start(ctx context.Context, out chan<- *message.Message)
 		slack.OptionAppLevelToken(s.cfg.AppToken),
 	)
 
+	// Look up our own user ID so mentions of us can be told apart from
+	// ambient channel chatter.
+	auth, err := s.api.AuthTestContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with Slack: %w", err)
+	}
+	s.selfUserID = auth.UserID
+	s.teamID = auth.TeamID
+	s.teamURL = auth.URL
+
 	// Create Socket Mode client
 	s.socket = socketmode.New(
 		s.api,
@@ -50,7 +73,7 @@ func (s *SlackListener) Start(ctx context.Context, out chan<- *message.Message)
 	// Handle events in a goroutine
 	go s.handleEvents(ctx)
 
-	slog.Info("Slack listener started (Socket Mode)")
+	slog.Info("Slack listener started (Socket Mode)", "account", s.Name(), "team", auth.Team)
 
 	// Run socket mode client (blocking)
 	return s.socket.RunContext(ctx)
@@ -64,13 +87,13 @@ func (s *SlackListener) handleEvents(ctx context.Context) {
 		case evt := <-s.socket.Events:
 			switch evt.Type {
 			case socketmode.EventTypeEventsAPI:
-				s.handleEventsAPI(evt)
+				s.handleEventsAPI(ctx, evt)
 			}
 		}
 	}
 }
 
-func (s *SlackListener) handleEventsAPI(evt socketmode.Event) {
+func (s *SlackListener) handleEventsAPI(ctx context.Context, evt socketmode.Event) {
 	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
 	if !ok {
 		return
@@ -81,38 +104,183 @@ func (s *SlackListener) handleEventsAPI(evt socketmode.Event) {
 
 	switch eventsAPIEvent.Type {
 	case slackevents.CallbackEvent:
-		s.handleCallbackEvent(eventsAPIEvent.InnerEvent)
+		s.handleCallbackEvent(ctx, eventsAPIEvent.InnerEvent)
 	}
 }
 
-func (s *SlackListener) handleCallbackEvent(innerEvent slackevents.EventsAPIInnerEvent) {
+func (s *SlackListener) handleCallbackEvent(ctx context.Context, innerEvent slackevents.EventsAPIInnerEvent) {
 	switch ev := innerEvent.Data.(type) {
 	case *slackevents.MessageEvent:
-		s.handleMessage(ev)
+		s.handleMessage(ctx, ev)
+	case *slackevents.AppMentionEvent:
+		s.handleAppMention(ev)
 	}
 }
 
-func (s *SlackListener) handleMessage(ev *slackevents.MessageEvent) {
-	// Skip bot messages and message edits
-	if ev.BotID != "" || ev.SubType != "" {
+func (s *SlackListener) handleMessage(ctx context.Context, ev *slackevents.MessageEvent) {
+	switch ev.SubType {
+	case "", "thread_broadcast", "file_share", "message_changed":
+	default:
+		// Joins, topic changes, deletions and other housekeeping events
+		return
+	}
+
+	// The event's Message field holds the message for plain messages as well as
+	// the new version of an edited one.
+	m := ev.Message
+	if m == nil || ev.BotID != "" || m.BotID != "" || m.User == s.selfUserID {
 		return
 	}
 
-	if ev.Text == "" {
+	edited := ev.SubType == "message_changed"
+	if edited {
+		// Link unfurls also arrive as message_changed with the text untouched.
+		if ev.PreviousMessage != nil && ev.PreviousMessage.Text == m.Text {
+			return
+		}
+	} else if !s.markSeen(ev.Channel, m.Timestamp) {
 		return
 	}
 
-	sender := s.resolveUser(ev.User)
+	text := slackMessageText(m.Text, m.Files)
+	if text == "" {
+		return
+	}
+
+	msg := s.newMessage(s.resolveUser(m.User), text)
+	msg.ID = m.ClientMsgID
+	if msg.ID == "" {
+		msg.ID = m.Timestamp
+	}
+	s.addMetadata(msg, ev.Channel, ev.ChannelType, m.User, m.Timestamp, m.ThreadTimestamp)
+	if strings.Contains(m.Text, "<@"+s.selfUserID+">") {
+		msg.Metadata["mentioned"] = "true"
+	}
+	if edited {
+		msg.Metadata["edited"] = "true"
+	}
+	if ev.SubType == "thread_broadcast" {
+		msg.Metadata["thread_broadcast"] = "true"
+	}
+	if names := slackFileNames(m.Files); names != "" {
+		msg.Metadata["files"] = names
+	}
+	if !edited && s.wantsMedia(message.AttachmentImage) {
+		msg.Attachments = s.downloadImages(ctx, m.Files)
+	}
+
+	s.out <- msg
+}
+
+// handleAppMention forwards app_mention events for channels the app is not
+// otherwise subscribed to. Mentions that already arrived as a message event
+// are skipped.
+func (s *SlackListener) handleAppMention(ev *slackevents.AppMentionEvent) {
+	if ev.BotID != "" || ev.Text == "" || ev.Edited != nil {
+		return
+	}
+	if !s.markSeen(ev.Channel, ev.TimeStamp) {
+		return
+	}
 
-	msg := message.NewMessage(message.SourceSlack, sender, ev.Text)
-	msg.ID = ev.ClientMsgID
-	msg.Metadata["channel"] = ev.Channel
-	msg.Metadata["channel_type"] = ev.ChannelType
-	msg.Metadata["thread_ts"] = ev.ThreadTimeStamp
+	msg := s.newMessage(s.resolveUser(ev.User), ev.Text)
+	msg.ID = ev.TimeStamp
+	s.addMetadata(msg, ev.Channel, "", ev.User, ev.TimeStamp, ev.ThreadTimeStamp)
+	msg.Metadata["mentioned"] = "true"
 
 	s.out <- msg
 }
 
+func (s *SlackListener) addMetadata(msg *message.Message, channel, channelType, userID, ts, threadTS string) {
+	msg.Metadata["team_id"] = s.teamID
+	msg.Metadata["team_url"] = s.teamURL
+	msg.Metadata["channel"] = channel
+	msg.Metadata["channel_type"] = channelType
+	msg.Metadata["user_id"] = userID
+	msg.Metadata["ts"] = ts
+	msg.Metadata["thread_ts"] = threadTS
+
+	if channelType == "im" {
+		msg.Metadata["is_dm"] = "true"
+		msg.Metadata["channel_name"] = "DM"
+	} else {
+		msg.Metadata["channel_name"] = s.ChannelName(channel)
+	}
+}
+
+// markSeen records a channel/timestamp pair and reports whether it was new.
+func (s *SlackListener) markSeen(channel, ts string) bool {
+	key := channel + ":" + ts
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	if len(s.seen) >= maxSeenSlackMessages {
+		s.seen = make(map[string]struct{})
+	}
+	s.seen[key] = struct{}{}
+	return true
+}
+
+// slackMessageText returns the message text with shared files described
+// inline, so file-only messages still carry context.
+func slackMessageText(text string, files []slack.File) string {
+	var b strings.Builder
+	b.WriteString(text)
+	for _, f := range files {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[Shared file: %s]", slackFileLabel(f))
+	}
+	return b.String()
+}
+
+func slackFileLabel(f slack.File) string {
+	switch {
+	case f.Title != "" && f.Title != f.Name:
+		return fmt.Sprintf("%s (%s)", f.Title, f.Name)
+	case f.Name != "":
+		return f.Name
+	default:
+		return f.Title
+	}
+}
+
+// downloadImages fetches the image files shared with a message. Files that
+// are too large or fail to download are skipped.
+func (s *SlackListener) downloadImages(ctx context.Context, files []slack.File) []message.Attachment {
+	var attachments []message.Attachment
+	for _, f := range files {
+		if !strings.HasPrefix(f.Mimetype, "image/") || f.URLPrivateDownload == "" {
+			continue
+		}
+		if f.Size > maxMediaBytes {
+			slog.Warn("Skipping large Slack image", "file", f.Name, "size", f.Size)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := s.api.GetFileContext(ctx, f.URLPrivateDownload, &buf); err != nil {
+			slog.Warn("Failed to download Slack file", "file", f.Name, "error", err)
+			continue
+		}
+		attachments = append(attachments, message.Attachment{
+			Kind:     message.AttachmentImage,
+			MimeType: f.Mimetype,
+			FileName: f.Name,
+			Data:     buf.Bytes(),
+		})
+	}
+	return attachments
+}
+
+func slackFileNames(files []slack.File) string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
 func (s *SlackListener) resolveUser(userID string) string {
 	if name, ok := s.userCache[userID]; ok {
 		return name
@@ -140,11 +308,19 @@ func (s *SlackListener) Stop() error {
 
 // ChannelName resolves a channel ID to its name.
 func (s *SlackListener) ChannelName(channelID string) string {
+	if name, ok := s.channelCache[channelID]; ok {
+		return name
+	}
+
 	channel, err := s.api.GetConversationInfo(&slack.GetConversationInfoInput{
 		ChannelID: channelID,
 	})
 	if err != nil {
+		slog.Warn("Failed to resolve Slack channel", "channel_id", channelID, "error", err)
 		return channelID
 	}
-	return fmt.Sprintf("#%s", channel.Name)
+
+	name := fmt.Sprintf("#%s", channel.Name)
+	s.channelCache[channelID] = name
+	return name
 }
@@ -2,34 +2,69 @@ package listener
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/downloader"
 	"github.com/gotd/td/telegram/updates"
 	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
 
 	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/login"
 	"github.com/emirlan/notifylm/internal/message"
 )
 
+// muteCacheTTL is how long Telegram notification settings are cached per chat.
+const muteCacheTTL = 5 * time.Minute
+
 // TelegramListener implements the Listener interface for Telegram userbot.
 type TelegramListener struct {
 	BaseListener
 	cfg    config.TelegramConfig
 	client *telegram.Client
 	out    chan<- *message.Message
+
+	muteMu    sync.Mutex
+	muteCache map[string]muteEntry // keyed by chat key or notify scope
+}
+
+type muteEntry struct {
+	muteUntil time.Time
+	explicit  bool // false if the chat inherits its scope's default
+	fetchedAt time.Time
+}
+
+// telegramChat describes the chat a message was sent in.
+type telegramChat struct {
+	Type     string // "user", "group", "supergroup" or "channel"
+	ID       int64
+	Username string
+	Title    string
+	Input    tg.InputPeerClass // nil if the entity wasn't included in the update
+}
+
+// key returns a stable identifier such as "channel:1234".
+func (c telegramChat) key() string {
+	return fmt.Sprintf("%s:%d", c.Type, c.ID)
 }
 
 // NewTelegramListener creates a new Telegram listener.
 func NewTelegramListener(cfg config.TelegramConfig) *TelegramListener {
 	return &TelegramListener{
-		BaseListener: NewBaseListener("telegram"),
+		BaseListener: NewBaseListener("telegram", message.SourceTelegram, cfg.Name),
 		cfg:          cfg,
+		muteCache:    make(map[string]muteEntry),
 	}
 }
 
@@ -60,6 +95,17 @@ func (t *TelegramListener) Start(ctx context.Context, out chan<- *message.Messag
 		return nil
 	})
 
+	// Supergroup and broadcast channel messages arrive as channel updates
+	dispatcher.OnNewChannelMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateNewChannelMessage) error {
+		msg, ok := update.Message.(*tg.Message)
+		if !ok || msg.Out {
+			return nil
+		}
+
+		t.handleMessage(ctx, e, msg)
+		return nil
+	})
+
 	// Create client
 	t.client = telegram.NewClient(t.cfg.AppID, t.cfg.AppHash, telegram.Options{
 		SessionStorage: &telegram.FileSessionStorage{
@@ -76,51 +122,399 @@ func (t *TelegramListener) Start(ctx context.Context, out chan<- *message.Messag
 			return fmt.Errorf("failed to get auth status: %w", err)
 		}
 
+		user := status.User
 		if !status.Authorized {
 			// Need to authenticate
 			if err := t.authenticate(ctx); err != nil {
 				return fmt.Errorf("failed to authenticate: %w", err)
 			}
+			// The status from before signing in has no user
+			if user, err = t.client.Self(ctx); err != nil {
+				return fmt.Errorf("failed to get the signed-in user: %w", err)
+			}
 		}
 
-		slog.Info("Telegram listener started", "user", status.User.Username)
+		slog.Info("Telegram listener started", "account", t.Name(), "user", user.Username)
 
 		// Run gaps handler to receive updates
-		return gaps.Run(ctx, t.client.API(), status.User.ID, updates.AuthOptions{
-			IsBot: status.User.Bot,
+		return gaps.Run(ctx, t.client.API(), user.ID, updates.AuthOptions{
+			IsBot: user.Bot,
 		})
 	})
 }
 
+// maxLoginAttempts bounds how many times a mistyped code or password can be
+// retried from the dashboard before the listener gives up.
+const maxLoginAttempts = 3
+
 func (t *TelegramListener) authenticate(ctx context.Context) error {
-	flow := auth.NewFlow(
-		terminalAuth{phone: t.cfg.Phone},
-		auth.SendCodeOptions{},
-	)
-	return t.client.Auth().IfNecessary(ctx, flow)
+	if t.login == nil {
+		flow := auth.NewFlow(
+			terminalAuth{phone: t.cfg.Phone},
+			auth.SendCodeOptions{},
+		)
+		return t.client.Auth().IfNecessary(ctx, flow)
+	}
+
+	defer t.login.Done(t.Name())
+	slog.Info("Telegram login required, enter the code on the dashboard", "account", t.Name())
+
+	a := &brokerAuth{broker: t.login, listener: t.Name(), phone: t.cfg.Phone}
+	var err error
+	for attempt := 0; attempt < maxLoginAttempts; attempt++ {
+		err = t.client.Auth().IfNecessary(ctx, auth.NewFlow(a, auth.SendCodeOptions{}))
+		if err == nil || ctx.Err() != nil || !retryableLoginError(err) {
+			return err
+		}
+		slog.Warn("Telegram login attempt failed", "account", t.Name(), "error", err)
+		a.lastErr = loginErrorMessage(err)
+	}
+	return err
+}
+
+// retryableLoginError reports whether err was caused by a mistyped code or
+// password rather than a configuration or network problem.
+func retryableLoginError(err error) bool {
+	return errors.Is(err, auth.ErrPasswordInvalid) ||
+		tgerr.Is(err, "PHONE_CODE_INVALID", "PHONE_CODE_EXPIRED", "PASSWORD_HASH_INVALID")
+}
+
+func loginErrorMessage(err error) string {
+	switch {
+	case tgerr.Is(err, "PHONE_CODE_EXPIRED"):
+		return "That code expired. A new one has been sent."
+	case tgerr.Is(err, "PHONE_CODE_INVALID"):
+		return "That code was not accepted. A new one has been sent."
+	default:
+		return "That password was not accepted."
+	}
 }
 
 func (t *TelegramListener) handleMessage(ctx context.Context, e tg.Entities, msg *tg.Message) {
-	if msg.Message == "" {
+	media := telegramMediaOf(msg)
+	if msg.Message == "" && media == nil {
+		return
+	}
+
+	chat := telegramPeer(e, msg.PeerID)
+	muted := func() bool { return t.isMuted(ctx, chat) }
+	if reason := filterTelegramChat(t.cfg, chat, muted); reason != "" {
+		slog.Debug("Skipping Telegram chat", "reason", reason, "chat", chat.key(), "title", chat.Title)
+		return
+	}
+
+	var attachments []message.Attachment
+	if media != nil && t.wantsMedia(media.kind) {
+		if a, err := t.download(ctx, media); err != nil {
+			slog.Warn("Failed to download Telegram media", "chat", chat.key(), "kind", media.kind, "error", err)
+		} else {
+			attachments = append(attachments, a)
+		}
+	}
+	if msg.Message == "" && len(attachments) == 0 {
 		return
 	}
 
 	sender := "Unknown"
+	var from *tg.User
 	if msg.FromID != nil {
 		if peerUser, ok := msg.FromID.(*tg.PeerUser); ok {
 			if user, ok := e.Users[peerUser.UserID]; ok {
 				sender = formatTelegramUser(user)
+				from = user
 			}
 		}
+	} else if chat.Type == "user" || chat.Type == "channel" {
+		// Private chats and channel posts come from the peer itself
+		sender = chat.Title
+		if chat.Type == "user" {
+			from = e.Users[chat.ID]
+		}
 	}
 
-	m := message.NewMessage(message.SourceTelegram, sender, msg.Message)
+	m := t.newMessage(sender, msg.Message)
 	m.ID = fmt.Sprintf("%d", msg.ID)
-	m.Metadata["peer_id"] = fmt.Sprintf("%v", msg.PeerID)
+	m.Timestamp = time.Unix(int64(msg.Date), 0)
+	m.Attachments = attachments
+	if media != nil {
+		m.Metadata["media_type"] = media.mediaType
+	}
+	m.Metadata["peer_id"] = chat.key()
+	m.Metadata["peer_type"] = chat.Type
+	m.Metadata["chat_id"] = fmt.Sprintf("%d", chat.ID)
+	m.Metadata["chat_username"] = chat.Username
+	m.Metadata["chat_title"] = chat.Title
+	if chat.Type != "user" {
+		m.Metadata["is_group"] = "true"
+	}
+	if from != nil {
+		m.Metadata["sender_id"] = fmt.Sprintf("%d", from.ID)
+		if from.Username != "" {
+			m.Metadata["sender_username"] = from.Username
+		}
+		if from.Phone != "" {
+			m.Metadata["sender_phone"] = from.Phone // only visible for contacts
+		}
+	}
 
 	t.out <- m
 }
 
+// telegramMedia is a downloadable photo or audio file attached to a message.
+type telegramMedia struct {
+	kind      message.AttachmentKind
+	mediaType string // "photo", "voice" or "audio"
+	location  tg.InputFileLocationClass
+	mimeType  string
+	size      int64
+}
+
+// telegramMediaOf returns the photo or audio attached to a message, if any.
+func telegramMediaOf(msg *tg.Message) *telegramMedia {
+	switch media := msg.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		photo, ok := media.Photo.(*tg.Photo)
+		if !ok {
+			return nil
+		}
+		sizeType, size := largestPhotoSize(photo)
+		if sizeType == "" {
+			return nil
+		}
+		return &telegramMedia{
+			kind:      message.AttachmentImage,
+			mediaType: "photo",
+			location: &tg.InputPhotoFileLocation{
+				ID:            photo.ID,
+				AccessHash:    photo.AccessHash,
+				FileReference: photo.FileReference,
+				ThumbSize:     sizeType,
+			},
+			mimeType: "image/jpeg",
+			size:     int64(size),
+		}
+	case *tg.MessageMediaDocument:
+		doc, ok := media.Document.(*tg.Document)
+		if !ok {
+			return nil
+		}
+		for _, attr := range doc.Attributes {
+			if audio, ok := attr.(*tg.DocumentAttributeAudio); ok {
+				mediaType := "audio"
+				if audio.Voice {
+					mediaType = "voice"
+				}
+				return &telegramMedia{
+					kind:      message.AttachmentAudio,
+					mediaType: mediaType,
+					location:  doc.AsInputDocumentFileLocation(),
+					mimeType:  doc.MimeType,
+					size:      doc.Size,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// largestPhotoSize returns the type and byte size of a photo's largest rendition.
+func largestPhotoSize(photo *tg.Photo) (string, int) {
+	var bestType string
+	var bestArea, bestSize int
+	for _, ps := range photo.Sizes {
+		var typ string
+		var w, h, size int
+		switch p := ps.(type) {
+		case *tg.PhotoSize:
+			typ, w, h, size = p.Type, p.W, p.H, p.Size
+		case *tg.PhotoSizeProgressive:
+			if len(p.Sizes) == 0 {
+				continue
+			}
+			typ, w, h, size = p.Type, p.W, p.H, p.Sizes[len(p.Sizes)-1]
+		default:
+			continue
+		}
+		if w*h > bestArea {
+			bestType, bestArea, bestSize = typ, w*h, size
+		}
+	}
+	return bestType, bestSize
+}
+
+// download fetches an attachment into memory.
+func (t *TelegramListener) download(ctx context.Context, media *telegramMedia) (message.Attachment, error) {
+	if media.size > maxMediaBytes {
+		return message.Attachment{}, fmt.Errorf("file too large (%d bytes)", media.size)
+	}
+
+	var buf bytes.Buffer
+	if _, err := downloader.NewDownloader().
+		Download(t.client.API(), media.location).
+		Stream(ctx, &buf); err != nil {
+		return message.Attachment{}, err
+	}
+
+	var fileName string
+	if media.kind == message.AttachmentAudio {
+		fileName = audioFileName(media.mimeType)
+	}
+	return message.Attachment{
+		Kind:     media.kind,
+		MimeType: media.mimeType,
+		FileName: fileName,
+		Data:     buf.Bytes(),
+	}, nil
+}
+
+// filterTelegramChat applies an account's allow/deny lists, channel policy
+// and mute setting to chat. It returns why messages from the chat are
+// skipped, or "" to forward them. muted is only called when the account
+// respects mutes, since it may ask Telegram.
+func filterTelegramChat(cfg config.TelegramConfig, chat telegramChat, muted func() bool) string {
+	if matchesTelegramChat(cfg.DenyChats, chat) {
+		return "denied"
+	}
+	if len(cfg.AllowChats) > 0 {
+		if !matchesTelegramChat(cfg.AllowChats, chat) {
+			return "not allowed"
+		}
+	} else if chat.Type == "channel" && !cfg.ForwardChannels() {
+		return "channel"
+	}
+	if cfg.SkipMuted() && muted() {
+		return "muted"
+	}
+	return ""
+}
+
+// matchesTelegramChat reports whether any entry names the chat. Entries are
+// numeric IDs (Bot API style "-100..." IDs are accepted) or usernames with or
+// without a leading "@".
+func matchesTelegramChat(entries []string, chat telegramChat) bool {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if id, err := strconv.ParseInt(entry, 10, 64); err == nil {
+			if normalizeTelegramID(id) == chat.ID {
+				return true
+			}
+			continue
+		}
+		name := strings.TrimPrefix(entry, "@")
+		if chat.Username != "" && strings.EqualFold(name, chat.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTelegramID converts Bot API chat IDs (negative, with a -100 prefix
+// for channels) to the raw IDs used by MTProto.
+func normalizeTelegramID(id int64) int64 {
+	const channelOffset = 1000000000000
+	switch {
+	case id <= -channelOffset:
+		return -id - channelOffset
+	case id < 0:
+		return -id
+	default:
+		return id
+	}
+}
+
+// isMuted reports whether the chat is muted in Telegram, either explicitly or
+// through the default settings of its scope (private chats, groups, channels).
+func (t *TelegramListener) isMuted(ctx context.Context, chat telegramChat) bool {
+	if chat.Input == nil {
+		return false
+	}
+
+	entry, err := t.notifySettings(ctx, chat.key(), &tg.InputNotifyPeer{Peer: chat.Input})
+	if err != nil {
+		slog.Warn("Failed to get Telegram notify settings", "chat", chat.key(), "error", err)
+		return false
+	}
+	if !entry.explicit {
+		var scope tg.InputNotifyPeerClass
+		switch chat.Type {
+		case "user":
+			scope = &tg.InputNotifyUsers{}
+		case "channel":
+			scope = &tg.InputNotifyBroadcasts{}
+		default:
+			scope = &tg.InputNotifyChats{}
+		}
+		entry, err = t.notifySettings(ctx, "scope:"+chat.Type, scope)
+		if err != nil {
+			slog.Warn("Failed to get Telegram default notify settings", "scope", chat.Type, "error", err)
+			return false
+		}
+	}
+	return entry.muteUntil.After(time.Now())
+}
+
+func (t *TelegramListener) notifySettings(ctx context.Context, key string, peer tg.InputNotifyPeerClass) (muteEntry, error) {
+	t.muteMu.Lock()
+	entry, ok := t.muteCache[key]
+	t.muteMu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < muteCacheTTL {
+		return entry, nil
+	}
+
+	settings, err := t.client.API().AccountGetNotifySettings(ctx, peer)
+	if err != nil {
+		return muteEntry{}, err
+	}
+
+	entry = muteEntry{fetchedAt: time.Now()}
+	if until, ok := settings.GetMuteUntil(); ok {
+		entry.explicit = true
+		entry.muteUntil = time.Unix(int64(until), 0)
+	}
+
+	t.muteMu.Lock()
+	t.muteCache[key] = entry
+	t.muteMu.Unlock()
+	return entry, nil
+}
+
+// telegramPeer resolves the chat a message was sent in using the entities
+// delivered with the update.
+func telegramPeer(e tg.Entities, peer tg.PeerClass) telegramChat {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		chat := telegramChat{Type: "user", ID: p.UserID}
+		if user, ok := e.Users[p.UserID]; ok {
+			chat.Username = user.Username
+			chat.Title = formatTelegramUser(user)
+			chat.Input = user.AsInputPeer()
+		}
+		return chat
+	case *tg.PeerChat:
+		chat := telegramChat{Type: "group", ID: p.ChatID}
+		if c, ok := e.Chats[p.ChatID]; ok {
+			chat.Title = c.Title
+			chat.Input = c.AsInputPeer()
+		}
+		return chat
+	case *tg.PeerChannel:
+		// Without the entity we can't tell, so assume a supergroup rather
+		// than drop the message as a broadcast channel post
+		chat := telegramChat{Type: "supergroup", ID: p.ChannelID}
+		if c, ok := e.Channels[p.ChannelID]; ok {
+			if !c.Megagroup {
+				chat.Type = "channel"
+			}
+			chat.Username = c.Username
+			chat.Title = c.Title
+			chat.Input = c.AsInputPeer()
+		}
+		return chat
+	default:
+		return telegramChat{Type: "unknown"}
+	}
+}
+
 func formatTelegramUser(user *tg.User) string {
 	if user.FirstName != "" {
 		name := user.FirstName
@@ -167,6 +561,43 @@ func (a terminalAuth) AcceptTermsOfService(_ context.Context, _ tg.HelpTermsOfSe
 	return nil
 }
 
+// brokerAuth implements auth.UserAuthenticator by asking for the code and
+// password through the dashboard.
+type brokerAuth struct {
+	broker   *login.Broker
+	listener string
+	phone    string
+	lastErr  string // shown with the next prompt after a failed attempt
+}
+
+func (a *brokerAuth) Phone(_ context.Context) (string, error) {
+	return a.phone, nil
+}
+
+func (a *brokerAuth) Password(ctx context.Context) (string, error) {
+	return a.broker.Ask(ctx, a.listener, login.KindPassword,
+		"Enter your Telegram two-step verification password.", a.takeError())
+}
+
+func (a *brokerAuth) Code(ctx context.Context, _ *tg.AuthSentCode) (string, error) {
+	return a.broker.Ask(ctx, a.listener, login.KindCode,
+		fmt.Sprintf("Enter the login code Telegram sent to %s.", a.phone), a.takeError())
+}
+
+func (a *brokerAuth) takeError() string {
+	msg := a.lastErr
+	a.lastErr = ""
+	return msg
+}
+
+func (a *brokerAuth) SignUp(_ context.Context) (auth.UserInfo, error) {
+	return auth.UserInfo{}, fmt.Errorf("sign up not supported")
+}
+
+func (a *brokerAuth) AcceptTermsOfService(_ context.Context, _ tg.HelpTermsOfService) error {
+	return nil
+}
+
 func readLine() (string, error) {
 	reader := bufio.NewReader(os.Stdin)
 	line, err := reader.ReadString('\n')
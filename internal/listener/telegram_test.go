@@ -0,0 +1,106 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+func TestTelegramPeer(t *testing.T) {
+	e := tg.Entities{
+		Channels: map[int64]*tg.Channel{
+			10: {ID: 10, Title: "News", Username: "news"},
+			11: {ID: 11, Title: "Team", Megagroup: true},
+		},
+	}
+	tests := []struct {
+		name     string
+		peer     tg.PeerClass
+		wantType string
+		wantUser string
+	}{
+		{"broadcast channel", &tg.PeerChannel{ChannelID: 10}, "channel", "news"},
+		{"supergroup", &tg.PeerChannel{ChannelID: 11}, "supergroup", ""},
+		{"channel not in the update", &tg.PeerChannel{ChannelID: 12}, "supergroup", ""},
+		{"basic group", &tg.PeerChat{ChatID: 20}, "group", ""},
+		{"user", &tg.PeerUser{UserID: 30}, "user", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := telegramPeer(e, tt.peer)
+			if chat.Type != tt.wantType || chat.Username != tt.wantUser {
+				t.Errorf("telegramPeer = %s @%q, want %s @%q", chat.Type, chat.Username, tt.wantType, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestFilterTelegramChat(t *testing.T) {
+	on, off := true, false
+	channel := telegramChat{Type: "channel", ID: 10, Username: "news"}
+	group := telegramChat{Type: "group", ID: 20}
+	// A PeerChannel whose entity wasn't in the update
+	unknown := telegramPeer(tg.Entities{}, &tg.PeerChannel{ChannelID: 12})
+
+	tests := []struct {
+		name  string
+		cfg   config.TelegramConfig
+		chat  telegramChat
+		muted bool
+		want  string
+	}{
+		{"group", config.TelegramConfig{}, group, false, ""},
+		{"channels are skipped by default", config.TelegramConfig{}, channel, false, "channel"},
+		{"include_channels", config.TelegramConfig{IncludeChannels: &on}, channel, false, ""},
+		{"include_channels turned off", config.TelegramConfig{IncludeChannels: &off}, channel, false, "channel"},
+		{"unknown channel peer", config.TelegramConfig{IncludeChannels: &off}, unknown, false, ""},
+		{"allow list overrides the channel policy", config.TelegramConfig{AllowChats: []string{"@News"}}, channel, false, ""},
+		{"not on the allow list", config.TelegramConfig{AllowChats: []string{"-100999"}}, group, false, "not allowed"},
+		{"bot api id on the allow list", config.TelegramConfig{AllowChats: []string{"-20"}}, group, false, ""},
+		{"deny list wins", config.TelegramConfig{AllowChats: []string{"news"}, DenyChats: []string{"-1000000000010"}}, channel, false, "denied"},
+		{"muted ignored by default", config.TelegramConfig{}, group, true, ""},
+		{"respect_mute", config.TelegramConfig{RespectMute: &on}, group, true, "muted"},
+		{"respect_mute turned off", config.TelegramConfig{RespectMute: &off}, group, true, ""},
+		{"respect_mute, not muted", config.TelegramConfig{RespectMute: &on}, group, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := false
+			muted := func() bool { asked = true; return tt.muted }
+			if got := filterTelegramChat(tt.cfg, tt.chat, muted); got != tt.want {
+				t.Errorf("filterTelegramChat = %q, want %q", got, tt.want)
+			}
+			if asked && !tt.cfg.SkipMuted() {
+				t.Error("checked the mute setting although the account ignores mutes")
+			}
+		})
+	}
+}
+
+func TestFilterTelegramAccounts(t *testing.T) {
+	on, off := true, false
+	cfg := config.TelegramConfig{
+		IncludeChannels: &on,
+		RespectMute:     &on,
+		Accounts: []config.TelegramConfig{
+			{Name: "personal"},
+			{Name: "work", IncludeChannels: &off, RespectMute: &off},
+		},
+	}
+	channel := telegramChat{Type: "channel", ID: 10}
+	muted := func() bool { return true }
+	group := telegramChat{Type: "group", ID: 20}
+
+	want := map[string][2]string{
+		"personal": {"", "muted"}, // inherits the top-level settings
+		"work":     {"channel", ""},
+	}
+	for _, a := range cfg.AccountConfigs() {
+		got := [2]string{filterTelegramChat(a, channel, func() bool { return false }), filterTelegramChat(a, group, muted)}
+		if got != want[a.Name] {
+			t.Errorf("account %q: channel %q, muted group %q; want %q, %q", a.Name, got[0], got[1], want[a.Name][0], want[a.Name][1])
+		}
+	}
+}
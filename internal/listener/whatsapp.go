@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
 
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
 
@@ -24,18 +27,24 @@ type WhatsAppListener struct {
 	cfg    config.WhatsAppConfig
 	client *whatsmeow.Client
 	out    chan<- *message.Message
+	ctx    context.Context
+
+	groupMu    sync.Mutex
+	groupNames map[types.JID]string
 }
 
 // NewWhatsAppListener creates a new WhatsApp listener.
 func NewWhatsAppListener(cfg config.WhatsAppConfig) *WhatsAppListener {
 	return &WhatsAppListener{
-		BaseListener: NewBaseListener("whatsapp"),
+		BaseListener: NewBaseListener("whatsapp", message.SourceWhatsApp, cfg.Name),
 		cfg:          cfg,
+		groupNames:   make(map[types.JID]string),
 	}
 }
 
 func (w *WhatsAppListener) Start(ctx context.Context, out chan<- *message.Message) error {
 	w.out = out
+	w.ctx = ctx
 
 	// Ensure storage directory exists
 	if err := os.MkdirAll(w.cfg.StoragePath, 0755); err != nil {
@@ -69,14 +78,8 @@ func (w *WhatsAppListener) Start(ctx context.Context, out chan<- *message.Messag
 			return fmt.Errorf("failed to connect: %w", err)
 		}
 
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				slog.Info("WhatsApp QR code (scan with phone)", "qr", evt.Code)
-				fmt.Println("WhatsApp QR Code:")
-				fmt.Println(evt.Code)
-			} else {
-				slog.Info("WhatsApp login event", "event", evt.Event)
-			}
+		if err := w.awaitQRLogin(qrChan); err != nil {
+			return err
 		}
 	} else {
 		if err := w.client.Connect(); err != nil {
@@ -84,13 +87,42 @@ func (w *WhatsAppListener) Start(ctx context.Context, out chan<- *message.Messag
 		}
 	}
 
-	slog.Info("WhatsApp listener started")
+	slog.Info("WhatsApp listener started", "account", w.Name())
 
 	// Block until context is cancelled
 	<-ctx.Done()
 	return ctx.Err()
 }
 
+// awaitQRLogin shows QR codes until the phone links this device. Codes go to
+// the dashboard when a login broker is set, otherwise to stdout.
+func (w *WhatsAppListener) awaitQRLogin(qrChan <-chan whatsmeow.QRChannelItem) error {
+	if w.login != nil {
+		defer w.login.Done(w.Name())
+	}
+
+	last := ""
+	for evt := range qrChan {
+		last = evt.Event
+		switch {
+		case evt.Event == "code" && w.login != nil:
+			slog.Info("WhatsApp login required, scan the QR code on the dashboard", "account", w.Name())
+			w.login.ShowQR(w.Name(), evt.Code, "Open WhatsApp on your phone, go to Linked devices and scan this code.")
+		case evt.Event == "code":
+			slog.Info("WhatsApp QR code (scan with phone)", "qr", evt.Code)
+			fmt.Println("WhatsApp QR Code:")
+			fmt.Println(evt.Code)
+		default:
+			slog.Info("WhatsApp login event", "event", evt.Event)
+		}
+	}
+
+	if last != whatsmeow.QRChannelSuccess.Event {
+		return fmt.Errorf("WhatsApp login did not complete: %s", last)
+	}
+	return nil
+}
+
 func (w *WhatsAppListener) handleEvent(evt interface{}) {
 	switch v := evt.(type) {
 	case *events.Message:
@@ -99,12 +131,13 @@ func (w *WhatsAppListener) handleEvent(evt interface{}) {
 }
 
 func (w *WhatsAppListener) handleMessage(evt *events.Message) {
-	var text string
-	if msg := evt.Message; msg != nil {
-		text = extractWhatsAppText(msg)
+	if evt.Message == nil {
+		return
 	}
 
-	if text == "" {
+	content := extractWhatsAppContent(evt.Message)
+	attachments := w.downloadAttachments(evt.Message)
+	if content.text == "" && len(attachments) == 0 {
 		return
 	}
 
@@ -113,23 +146,169 @@ func (w *WhatsAppListener) handleMessage(evt *events.Message) {
 		sender = evt.Info.PushName
 	}
 
-	msg := message.NewMessage(message.SourceWhatsApp, sender, text)
+	msg := w.newMessage(sender, content.text)
 	msg.ID = evt.Info.ID
 	msg.Timestamp = evt.Info.Timestamp
+	msg.Attachments = attachments
 	msg.Metadata["chat_id"] = evt.Info.Chat.String()
+	msg.Metadata["sender_phone"] = evt.Info.Sender.User
 	msg.Metadata["is_group"] = fmt.Sprintf("%v", evt.Info.IsGroup)
+	msg.Metadata["media_type"] = content.mediaType
+	if content.fileName != "" {
+		msg.Metadata["file_name"] = content.fileName
+	}
+	if evt.Info.IsGroup {
+		msg.Metadata["chat_title"] = w.groupName(evt.Info.Chat)
+	}
+	if content.reactionTo != "" {
+		msg.Metadata["reaction_to"] = content.reactionTo
+	}
+	if quoted := content.quoted; quoted != nil {
+		if q := extractWhatsAppContent(quoted.GetQuotedMessage()); q.text != "" {
+			msg.Metadata["quoted_text"] = q.text
+			msg.Metadata["quoted_id"] = quoted.GetStanzaID()
+		}
+	}
 
 	w.out <- msg
 }
 
-func extractWhatsAppText(msg *waE2E.Message) string {
-	if msg.Conversation != nil {
-		return *msg.Conversation
+// downloadAttachments fetches the media the pipeline has asked for.
+func (w *WhatsAppListener) downloadAttachments(msg *waE2E.Message) []message.Attachment {
+	var (
+		media    whatsmeow.DownloadableMessage
+		kind     message.AttachmentKind
+		mimeType string
+		size     uint64
+		fileName string
+	)
+	switch {
+	case msg.GetAudioMessage() != nil:
+		m := msg.GetAudioMessage()
+		media, kind, mimeType, size = m, message.AttachmentAudio, m.GetMimetype(), m.GetFileLength()
+		fileName = audioFileName(mimeType)
+	case msg.GetImageMessage() != nil:
+		m := msg.GetImageMessage()
+		media, kind, mimeType, size = m, message.AttachmentImage, m.GetMimetype(), m.GetFileLength()
+	default:
+		return nil
+	}
+
+	if !w.wantsMedia(kind) {
+		return nil
 	}
-	if msg.ExtendedTextMessage != nil && msg.ExtendedTextMessage.Text != nil {
-		return *msg.ExtendedTextMessage.Text
+	if size > maxMediaBytes {
+		slog.Warn("Skipping oversized WhatsApp media", "kind", kind, "bytes", size)
+		return nil
+	}
+
+	data, err := w.client.Download(w.ctx, media)
+	if err != nil {
+		slog.Warn("Failed to download WhatsApp media", "kind", kind, "error", err)
+		return nil
+	}
+	return []message.Attachment{{
+		Kind:     kind,
+		MimeType: mimeType,
+		FileName: fileName,
+		Data:     data,
+	}}
+}
+
+// groupName resolves a group JID to its subject, caching the result.
+func (w *WhatsAppListener) groupName(jid types.JID) string {
+	w.groupMu.Lock()
+	name, ok := w.groupNames[jid]
+	w.groupMu.Unlock()
+	if ok {
+		return name
+	}
+
+	info, err := w.client.GetGroupInfo(w.ctx, jid)
+	if err != nil {
+		slog.Warn("Failed to resolve WhatsApp group", "jid", jid.String(), "error", err)
+		return ""
+	}
+
+	w.groupMu.Lock()
+	w.groupNames[jid] = info.Name
+	w.groupMu.Unlock()
+	return info.Name
+}
+
+// whatsAppContent is the classifiable content of a WhatsApp message.
+type whatsAppContent struct {
+	text       string
+	mediaType  string // "text", "image", "video", "audio", "voice", "document", "sticker", "location", "contact" or "reaction"
+	fileName   string
+	reactionTo string             // ID of the message reacted to
+	quoted     *waE2E.ContextInfo // reply context, if the message quotes another
+}
+
+// extractWhatsAppContent returns the text of a message, using captions,
+// document names and placeholders for media so attachments aren't dropped.
+// Media without any text (e.g. an uncaptioned photo) yields empty text.
+func extractWhatsAppContent(msg *waE2E.Message) whatsAppContent {
+	switch {
+	case msg == nil:
+		return whatsAppContent{}
+	case msg.Conversation != nil:
+		return whatsAppContent{text: msg.GetConversation(), mediaType: "text"}
+	case msg.ExtendedTextMessage != nil:
+		m := msg.GetExtendedTextMessage()
+		return whatsAppContent{text: m.GetText(), mediaType: "text", quoted: m.GetContextInfo()}
+	case msg.ImageMessage != nil:
+		m := msg.GetImageMessage()
+		return whatsAppContent{text: m.GetCaption(), mediaType: "image", quoted: m.GetContextInfo()}
+	case msg.VideoMessage != nil:
+		m := msg.GetVideoMessage()
+		return whatsAppContent{text: m.GetCaption(), mediaType: "video", quoted: m.GetContextInfo()}
+	case msg.PtvMessage != nil:
+		m := msg.GetPtvMessage()
+		return whatsAppContent{text: m.GetCaption(), mediaType: "video", quoted: m.GetContextInfo()}
+	case msg.DocumentMessage != nil:
+		m := msg.GetDocumentMessage()
+		name := m.GetFileName()
+		if name == "" {
+			name = m.GetTitle()
+		}
+		text := fmt.Sprintf("[Document: %s]", name)
+		if caption := m.GetCaption(); caption != "" {
+			text = caption + "\n" + text
+		}
+		return whatsAppContent{text: text, mediaType: "document", fileName: name, quoted: m.GetContextInfo()}
+	case msg.AudioMessage != nil:
+		m := msg.GetAudioMessage()
+		mediaType := "audio"
+		if m.GetPTT() {
+			mediaType = "voice"
+		}
+		return whatsAppContent{mediaType: mediaType, quoted: m.GetContextInfo()}
+	case msg.StickerMessage != nil:
+		return whatsAppContent{mediaType: "sticker"}
+	case msg.LocationMessage != nil:
+		m := msg.GetLocationMessage()
+		place := strings.TrimSpace(m.GetName() + " " + m.GetAddress())
+		if place == "" {
+			place = fmt.Sprintf("%.5f, %.5f", m.GetDegreesLatitude(), m.GetDegreesLongitude())
+		}
+		return whatsAppContent{text: fmt.Sprintf("[Location: %s]", place), mediaType: "location"}
+	case msg.ContactMessage != nil:
+		m := msg.GetContactMessage()
+		return whatsAppContent{text: fmt.Sprintf("[Contact: %s]", m.GetDisplayName()), mediaType: "contact"}
+	case msg.ReactionMessage != nil:
+		m := msg.GetReactionMessage()
+		if m.GetText() == "" {
+			// An empty reaction removes a previous one
+			return whatsAppContent{}
+		}
+		return whatsAppContent{
+			text:       fmt.Sprintf("Reacted %s to a message", m.GetText()),
+			mediaType:  "reaction",
+			reactionTo: m.GetKey().GetID(),
+		}
 	}
-	return ""
+	return whatsAppContent{}
 }
 
 func (w *WhatsAppListener) Stop() error {
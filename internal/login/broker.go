@@ -0,0 +1,191 @@
+// Package login lets listeners that need interactive sign-in (a WhatsApp QR
+// scan, a Telegram code or 2FA password) wait for the user to complete it
+// from the dashboard instead of a terminal.
+package login
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind is the type of input a listener is waiting for.
+type Kind string
+
+const (
+	KindQR       Kind = "qr"       // scan a QR code shown on the dashboard
+	KindCode     Kind = "code"     // enter a one-time login code
+	KindPassword Kind = "password" // enter a two-factor password
+	KindLink     Kind = "link"     // follow a link, e.g. an OAuth consent screen
+)
+
+// Request describes a pending login step.
+type Request struct {
+	Listener  string
+	Kind      Kind
+	Prompt    string // human-readable instructions
+	QRCode    string // payload to render for KindQR
+	URL       string // where to send the user for KindLink
+	LinkLabel string // button text for KindLink
+	Error     string // why the previous attempt failed, if it did
+	Submitted bool   // a value was submitted and the listener is checking it
+	CreatedAt time.Time
+}
+
+// StatusFunc is called whenever a listener starts or stops waiting for login.
+type StatusFunc func(listener string, awaiting bool)
+
+// Broker coordinates login requests between listeners and the dashboard.
+type Broker struct {
+	mu       sync.Mutex
+	pending  map[string]*pending // keyed by listener name
+	onStatus StatusFunc
+}
+
+type pending struct {
+	req      Request
+	response chan string // nil for QR requests and once a value was submitted
+}
+
+// NewBroker creates a broker. onStatus may be nil.
+func NewBroker(onStatus StatusFunc) *Broker {
+	return &Broker{
+		pending:  make(map[string]*pending),
+		onStatus: onStatus,
+	}
+}
+
+// ShowQR publishes (or replaces) the QR code a listener wants scanned. The
+// request stays pending until Done is called.
+func (b *Broker) ShowQR(listener, code, prompt string) {
+	b.show(listener, Request{Kind: KindQR, QRCode: code, Prompt: prompt})
+}
+
+// ShowLink publishes a link the user has to follow to log in. The request
+// stays pending until Done is called.
+func (b *Broker) ShowLink(listener, url, label, prompt string) {
+	b.show(listener, Request{Kind: KindLink, URL: url, LinkLabel: label, Prompt: prompt})
+}
+
+func (b *Broker) show(listener string, req Request) {
+	b.mu.Lock()
+	p, existed := b.pending[listener]
+	if !existed {
+		p = &pending{}
+		b.pending[listener] = p
+		req.CreatedAt = time.Now()
+	} else {
+		req.CreatedAt = p.req.CreatedAt
+	}
+	req.Listener = listener
+	p.req = req
+	p.response = nil
+	b.mu.Unlock()
+
+	if !existed {
+		b.status(listener, true)
+	}
+}
+
+// Ask blocks until the user submits a value for the listener from the
+// dashboard, or ctx is cancelled. errMsg is shown with the prompt when a
+// previous attempt was rejected.
+func (b *Broker) Ask(ctx context.Context, listener string, kind Kind, prompt, errMsg string) (string, error) {
+	response := make(chan string, 1)
+
+	b.mu.Lock()
+	_, existed := b.pending[listener]
+	b.pending[listener] = &pending{
+		req: Request{
+			Listener:  listener,
+			Kind:      kind,
+			Prompt:    prompt,
+			Error:     errMsg,
+			CreatedAt: time.Now(),
+		},
+		response: response,
+	}
+	b.mu.Unlock()
+
+	if !existed {
+		b.status(listener, true)
+	}
+
+	select {
+	case value := <-response:
+		return value, nil
+	case <-ctx.Done():
+		b.Done(listener)
+		return "", ctx.Err()
+	}
+}
+
+// Submit delivers the user's answer to a waiting listener.
+func (b *Broker) Submit(listener, value string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.pending[listener]
+	if !ok {
+		return fmt.Errorf("no login pending for %s", listener)
+	}
+	if p.req.Kind == KindQR || p.req.Kind == KindLink {
+		return fmt.Errorf("%s is not waiting for input", listener)
+	}
+	if p.response == nil {
+		return fmt.Errorf("%s is already checking a submitted value", listener)
+	}
+
+	// Keep the request visible (without a response channel) until the
+	// listener reports the outcome via Done or a new Ask.
+	p.response <- value
+	p.response = nil
+	p.req.Submitted = true
+	p.req.Error = ""
+	return nil
+}
+
+// Done marks a listener's login as finished, successfully or not.
+func (b *Broker) Done(listener string) {
+	b.mu.Lock()
+	_, ok := b.pending[listener]
+	delete(b.pending, listener)
+	b.mu.Unlock()
+
+	if ok {
+		b.status(listener, false)
+	}
+}
+
+// Get returns the pending request for a listener.
+func (b *Broker) Get(listener string) (Request, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.pending[listener]
+	if !ok {
+		return Request{}, false
+	}
+	return p.req, true
+}
+
+// Pending returns all pending requests, ordered by listener name.
+func (b *Broker) Pending() []Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]Request, 0, len(b.pending))
+	for _, p := range b.pending {
+		result = append(result, p.req)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Listener < result[j].Listener })
+	return result
+}
+
+func (b *Broker) status(listener string, awaiting bool) {
+	if b.onStatus != nil {
+		b.onStatus(listener, awaiting)
+	}
+}
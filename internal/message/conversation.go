@@ -0,0 +1,41 @@
+package message
+
+// ConversationKey identifies the chat, Slack thread or email thread the
+// message belongs to, or returns "" if the listener didn't record one.
+func (m *Message) ConversationKey() string {
+	var id string
+	switch m.Source {
+	case SourceWhatsApp:
+		id = m.Metadata["chat_id"]
+	case SourceTelegram:
+		id = m.Metadata["peer_id"]
+	case SourceSlack:
+		id = m.Metadata["channel"]
+		if thread := m.Metadata["thread_ts"]; id != "" && thread != "" && thread != m.Metadata["ts"] {
+			id += "/" + thread
+		}
+	case SourceGmail:
+		id = m.Metadata["thread_id"]
+	}
+	if id == "" {
+		return ""
+	}
+	return string(m.Source) + ":" + m.Metadata["account"] + ":" + id
+}
+
+// SameConversation reports whether other belongs to m's conversation. A
+// Slack thread also includes the channel message that started it.
+func (m *Message) SameConversation(other *Message) bool {
+	key := m.ConversationKey()
+	if key == "" {
+		return false
+	}
+	if other.ConversationKey() == key {
+		return true
+	}
+	thread := m.Metadata["thread_ts"]
+	return m.Source == SourceSlack && other.Source == SourceSlack && thread != "" &&
+		other.Metadata["ts"] == thread &&
+		other.Metadata["channel"] == m.Metadata["channel"] &&
+		other.Metadata["account"] == m.Metadata["account"]
+}
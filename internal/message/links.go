@@ -0,0 +1,121 @@
+package message
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DeepLink returns a native app URL (slack://, tg://, whatsapp://) that opens
+// the message's conversation, or "" if the message lacks the metadata needed.
+func (m *Message) DeepLink() string {
+	switch m.Source {
+	case SourceSlack:
+		team, channel := m.Metadata["team_id"], m.Metadata["channel"]
+		if team == "" || channel == "" {
+			return ""
+		}
+		return fmt.Sprintf("slack://channel?team=%s&id=%s", url.QueryEscape(team), url.QueryEscape(channel))
+	case SourceTelegram:
+		return telegramLink(m, "tg")
+	case SourceWhatsApp:
+		if phone := whatsAppPhone(m.Metadata["chat_id"]); phone != "" {
+			return "whatsapp://send?phone=" + phone
+		}
+	}
+	return ""
+}
+
+// WebLink returns an https URL pointing at the message, or "" if none can be
+// constructed.
+func (m *Message) WebLink() string {
+	switch m.Source {
+	case SourceSlack:
+		teamURL, channel, ts := m.Metadata["team_url"], m.Metadata["channel"], m.Metadata["ts"]
+		if teamURL == "" || channel == "" || ts == "" {
+			return ""
+		}
+		link := fmt.Sprintf("%sarchives/%s/p%s", ensureTrailingSlash(teamURL), channel, strings.ReplaceAll(ts, ".", ""))
+		if thread := m.Metadata["thread_ts"]; thread != "" && thread != ts {
+			link += fmt.Sprintf("?thread_ts=%s&cid=%s", thread, channel)
+		}
+		return link
+	case SourceTelegram:
+		return telegramLink(m, "https")
+	case SourceWhatsApp:
+		if phone := whatsAppPhone(m.Metadata["chat_id"]); phone != "" {
+			return "https://wa.me/" + phone
+		}
+	case SourceGmail:
+		if m.ID == "" {
+			return ""
+		}
+		if email := m.Metadata["account_email"]; email != "" {
+			return fmt.Sprintf("https://mail.google.com/mail/?authuser=%s#all/%s", url.QueryEscape(email), m.ID)
+		}
+		return fmt.Sprintf("https://mail.google.com/mail/u/0/#all/%s", m.ID)
+	}
+	return ""
+}
+
+// AppLink returns the deep link for the message, falling back to its web link.
+func (m *Message) AppLink() string {
+	if link := m.DeepLink(); link != "" {
+		return link
+	}
+	return m.WebLink()
+}
+
+// telegramLink builds a tg:// or https://t.me link to a Telegram message.
+// Public chats are addressed by username; private channels and supergroups by
+// their internal ID. Private user chats and basic groups have no message links.
+func telegramLink(m *Message, scheme string) string {
+	msgID := m.ID
+	username := m.Metadata["chat_username"]
+	peerType := m.Metadata["peer_type"]
+
+	switch {
+	case username != "" && peerType == "user":
+		if scheme == "tg" {
+			return "tg://resolve?domain=" + username
+		}
+		return "https://t.me/" + username
+	case username != "":
+		if scheme == "tg" {
+			return fmt.Sprintf("tg://resolve?domain=%s&post=%s", username, msgID)
+		}
+		return fmt.Sprintf("https://t.me/%s/%s", username, msgID)
+	case peerType == "channel" || peerType == "supergroup":
+		chatID := m.Metadata["chat_id"]
+		if chatID == "" {
+			return ""
+		}
+		if scheme == "tg" {
+			return fmt.Sprintf("tg://privatepost?channel=%s&post=%s", chatID, msgID)
+		}
+		return fmt.Sprintf("https://t.me/c/%s/%s", chatID, msgID)
+	case peerType == "user" && scheme == "tg":
+		if chatID := m.Metadata["chat_id"]; chatID != "" {
+			return "tg://user?id=" + chatID
+		}
+	}
+	return ""
+}
+
+// whatsAppPhone extracts the phone number from a one-to-one chat JID such as
+// "15551234567@s.whatsapp.net". Group chats have no phone number.
+func whatsAppPhone(jid string) string {
+	user, server, ok := strings.Cut(jid, "@")
+	if !ok || server != "s.whatsapp.net" {
+		return ""
+	}
+	user, _, _ = strings.Cut(user, ":")
+	return user
+}
+
+func ensureTrailingSlash(s string) string {
+	if strings.HasSuffix(s, "/") {
+		return s
+	}
+	return s + "/"
+}
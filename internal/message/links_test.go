@@ -0,0 +1,141 @@
+package message
+
+import "testing"
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   Source
+		id       string
+		metadata map[string]string
+		deep     string
+		web      string
+		app      string
+	}{
+		{
+			name:   "slack",
+			source: SourceSlack,
+			metadata: map[string]string{
+				"team_id": "T01", "team_url": "https://acme.slack.com", "channel": "C02", "ts": "1710000000.000100",
+			},
+			deep: "slack://channel?team=T01&id=C02",
+			web:  "https://acme.slack.com/archives/C02/p1710000000000100",
+			app:  "slack://channel?team=T01&id=C02",
+		},
+		{
+			name:   "slack thread reply",
+			source: SourceSlack,
+			metadata: map[string]string{
+				"team_id": "T01", "team_url": "https://acme.slack.com/", "channel": "C02",
+				"ts": "1710000000.000200", "thread_ts": "1710000000.000100",
+			},
+			deep: "slack://channel?team=T01&id=C02",
+			web:  "https://acme.slack.com/archives/C02/p1710000000000200?thread_ts=1710000000.000100&cid=C02",
+			app:  "slack://channel?team=T01&id=C02",
+		},
+		{
+			name:     "slack without a team id",
+			source:   SourceSlack,
+			metadata: map[string]string{"team_url": "https://acme.slack.com/", "channel": "C02", "ts": "1710000000.000100"},
+			web:      "https://acme.slack.com/archives/C02/p1710000000000100",
+			app:      "https://acme.slack.com/archives/C02/p1710000000000100",
+		},
+		{
+			name:     "telegram public channel",
+			source:   SourceTelegram,
+			id:       "42",
+			metadata: map[string]string{"peer_type": "channel", "chat_username": "news", "chat_id": "10"},
+			deep:     "tg://resolve?domain=news&post=42",
+			web:      "https://t.me/news/42",
+			app:      "tg://resolve?domain=news&post=42",
+		},
+		{
+			name:     "telegram private channel",
+			source:   SourceTelegram,
+			id:       "42",
+			metadata: map[string]string{"peer_type": "channel", "chat_id": "1234567890"},
+			deep:     "tg://privatepost?channel=1234567890&post=42",
+			web:      "https://t.me/c/1234567890/42",
+			app:      "tg://privatepost?channel=1234567890&post=42",
+		},
+		{
+			name:     "telegram private supergroup",
+			source:   SourceTelegram,
+			id:       "7",
+			metadata: map[string]string{"peer_type": "supergroup", "chat_id": "55"},
+			deep:     "tg://privatepost?channel=55&post=7",
+			web:      "https://t.me/c/55/7",
+			app:      "tg://privatepost?channel=55&post=7",
+		},
+		{
+			name:     "telegram user with a username",
+			source:   SourceTelegram,
+			id:       "42",
+			metadata: map[string]string{"peer_type": "user", "chat_username": "ann", "chat_id": "30"},
+			deep:     "tg://resolve?domain=ann",
+			web:      "https://t.me/ann",
+			app:      "tg://resolve?domain=ann",
+		},
+		{
+			name:     "telegram user without a username",
+			source:   SourceTelegram,
+			id:       "42",
+			metadata: map[string]string{"peer_type": "user", "chat_id": "30"},
+			deep:     "tg://user?id=30",
+			app:      "tg://user?id=30",
+		},
+		{
+			name:     "telegram basic group",
+			source:   SourceTelegram,
+			id:       "42",
+			metadata: map[string]string{"peer_type": "group", "chat_id": "20"},
+		},
+		{
+			name:     "whatsapp one-to-one",
+			source:   SourceWhatsApp,
+			metadata: map[string]string{"chat_id": "15551234567@s.whatsapp.net"},
+			deep:     "whatsapp://send?phone=15551234567",
+			web:      "https://wa.me/15551234567",
+			app:      "whatsapp://send?phone=15551234567",
+		},
+		{
+			name:     "whatsapp linked device",
+			source:   SourceWhatsApp,
+			metadata: map[string]string{"chat_id": "15551234567:12@s.whatsapp.net"},
+			deep:     "whatsapp://send?phone=15551234567",
+			web:      "https://wa.me/15551234567",
+			app:      "whatsapp://send?phone=15551234567",
+		},
+		{
+			name:     "whatsapp group",
+			source:   SourceWhatsApp,
+			metadata: map[string]string{"chat_id": "120363025246125486@g.us"},
+		},
+		{
+			name:     "gmail",
+			source:   SourceGmail,
+			id:       "18e1f",
+			metadata: map[string]string{"account_email": "me@example.com"},
+			web:      "https://mail.google.com/mail/?authuser=me%40example.com#all/18e1f",
+			app:      "https://mail.google.com/mail/?authuser=me%40example.com#all/18e1f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMessage(tt.source, "sender", "text")
+			m.ID = tt.id
+			for k, v := range tt.metadata {
+				m.Metadata[k] = v
+			}
+			if got := m.DeepLink(); got != tt.deep {
+				t.Errorf("DeepLink() = %q, want %q", got, tt.deep)
+			}
+			if got := m.WebLink(); got != tt.web {
+				t.Errorf("WebLink() = %q, want %q", got, tt.web)
+			}
+			if got := m.AppLink(); got != tt.app {
+				t.Errorf("AppLink() = %q, want %q", got, tt.app)
+			}
+		})
+	}
+}
@@ -14,12 +14,30 @@ const (
 
 // Message represents a unified message from any source.
 type Message struct {
-	ID        string
-	Source    Source
-	Sender    string
-	Text      string
-	Timestamp time.Time
-	Metadata  map[string]string
+	ID          string
+	Source      Source
+	Sender      string
+	Text        string
+	Timestamp   time.Time
+	Metadata    map[string]string
+	Attachments []Attachment
+}
+
+// AttachmentKind classifies downloaded media.
+type AttachmentKind string
+
+const (
+	AttachmentAudio AttachmentKind = "audio" // includes voice notes
+	AttachmentImage AttachmentKind = "image"
+)
+
+// Attachment is a piece of media downloaded by a listener for further
+// processing. Data is released once the message has been processed.
+type Attachment struct {
+	Kind     AttachmentKind
+	MimeType string
+	FileName string
+	Data     []byte
 }
 
 // NewMessage creates a new message with the given parameters.
@@ -20,14 +20,33 @@ type Notifier interface {
 type PushoverNotifier struct {
 	app       *pushover.Pushover
 	recipient *pushover.Recipient
+	device    string
+	priority  int
+	sound     string
 }
 
-// NewPushoverNotifier creates a new Pushover notifier.
+// NewPushoverNotifier creates a new Pushover notifier. Notifications are sent
+// at high priority with the persistent sound unless configured otherwise.
 func NewPushoverNotifier(cfg config.PushoverConfig) *PushoverNotifier {
-	return &PushoverNotifier{
+	p := &PushoverNotifier{
 		app:       pushover.New(cfg.AppToken),
 		recipient: pushover.NewRecipient(cfg.UserToken),
+		device:    cfg.Device,
+		priority:  pushover.PriorityHigh,
+		sound:     cfg.Sound,
 	}
+	switch cfg.Priority {
+	case "lowest":
+		p.priority = pushover.PriorityLowest
+	case "low":
+		p.priority = pushover.PriorityLow
+	case "normal":
+		p.priority = pushover.PriorityNormal
+	}
+	if p.sound == "" {
+		p.sound = pushover.SoundPersistent
+	}
+	return p
 }
 
 // Notify sends a push notification for an urgent message.
@@ -36,14 +55,15 @@ func (p *PushoverNotifier) Notify(msg *message.Message) error {
 	body := formatBody(msg)
 
 	notification := &pushover.Message{
-		Title:    title,
-		Message:  body,
-		Priority: pushover.PriorityHigh,
-		Sound:    pushover.SoundPersistent,
+		Title:      title,
+		Message:    body,
+		Priority:   p.priority,
+		Sound:      p.sound,
+		DeviceName: p.device,
 	}
 
-	// Add URL for context if applicable
-	if url := getMessageURL(msg); url != "" {
+	// Link straight to the message in its app, or on the web as a fallback
+	if url := msg.AppLink(); url != "" {
 		notification.URL = url
 		notification.URLTitle = "Open in app"
 	}
@@ -89,19 +109,6 @@ func getSourceIcon(source message.Source) string {
 	}
 }
 
-func getMessageURL(msg *message.Message) string {
-	switch msg.Source {
-	case message.SourceSlack:
-		// Could construct a Slack deep link
-		return ""
-	case message.SourceGmail:
-		if msg.ID != "" {
-			return fmt.Sprintf("https://mail.google.com/mail/u/0/#inbox/%s", msg.ID)
-		}
-	}
-	return ""
-}
-
 // MockNotifier is a notifier that just logs instead of sending.
 type MockNotifier struct{}
 
@@ -113,7 +120,8 @@ func (m *MockNotifier) Notify(msg *message.Message) error {
 	slog.Info("MOCK NOTIFICATION",
 		"source", msg.Source,
 		"sender", msg.Sender,
-		"text", truncate(msg.Text, 100))
+		"text", truncate(msg.Text, 100),
+		"open_in_app", msg.AppLink())
 	return nil
 }
 
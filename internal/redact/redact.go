@@ -0,0 +1,277 @@
+// Package redact masks personal data in text sent to cloud LLMs. Each value
+// is replaced by a placeholder such as [EMAIL_1], and the placeholders are
+// swapped back for the real values in what the LLM returns, so action items
+// still name the right phone number or address.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+// Built-in kinds of data.
+const (
+	KindEmail = "email"
+	KindPhone = "phone"
+	KindCard  = "card"
+	KindIBAN  = "iban"
+	KindOTP   = "otp"
+)
+
+// detector finds one kind of value. If group is set, only that submatch is
+// masked, so "code: 123456" keeps the word "code".
+type detector struct {
+	label string // placeholder prefix, e.g. "EMAIL"
+	re    *regexp.Regexp
+	group int
+	valid func(string) bool // optional checksum
+}
+
+var builtin = map[string][]detector{
+	KindEmail: {{
+		label: "EMAIL",
+		re:    regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}},
+	KindIBAN: {{
+		label: "IBAN",
+		re:    regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		valid: validIBAN,
+	}},
+	KindCard: {{
+		label: "CARD",
+		re:    regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid: luhn,
+	}},
+	KindOTP: {
+		{
+			label: "OTP",
+			re:    regexp.MustCompile(`(?i)\b(?:code|otp|pin|passcode|password|verification)\b[^\d\n]{0,20}\b(\d{4,8})\b`),
+			group: 1,
+		},
+		{
+			label: "OTP",
+			re:    regexp.MustCompile(`(?i)\b(\d{4,8})\b[^\d\n]{0,10}\bis your\b`),
+			group: 1,
+		},
+	},
+	// Only unambiguous formats, so dates and times aren't mistaken for phones.
+	KindPhone: {
+		{
+			label: "PHONE",
+			re:    regexp.MustCompile(`\+\d(?:[ ().-]{0,2}\d){6,14}\b`),
+		},
+		{
+			label: "PHONE",
+			re:    regexp.MustCompile(`(?:\(\d{3}\)\s?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`),
+		},
+	},
+}
+
+// order applies overlapping kinds most specific first.
+var order = []string{KindEmail, KindIBAN, KindCard, KindOTP, KindPhone}
+
+// Redactor masks the configured kinds of data. A nil Redactor masks
+// nothing.
+type Redactor struct {
+	detectors []detector
+	senders   bool
+}
+
+// New builds a redactor from cfg. Kinds default to all built-in ones.
+func New(cfg config.RedactionConfig) (*Redactor, error) {
+	kinds := cfg.Types
+	if len(kinds) == 0 {
+		kinds = order
+	}
+	r := &Redactor{senders: cfg.Senders}
+
+	for i, p := range cfg.Patterns {
+		if p.Name == "" {
+			return nil, fmt.Errorf("redaction pattern %d needs a name", i+1)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p.Name, err)
+		}
+		r.detectors = append(r.detectors, detector{label: placeholderLabel(p.Name), re: re})
+	}
+	for _, kind := range kinds {
+		if _, ok := builtin[kind]; !ok {
+			return nil, fmt.Errorf("unknown redaction type %q (want %s)", kind, strings.Join(order, ", "))
+		}
+	}
+	for _, kind := range order {
+		if slices.Contains(kinds, kind) {
+			r.detectors = append(r.detectors, builtin[kind]...)
+		}
+	}
+	return r, nil
+}
+
+// placeholderLabel turns a pattern name into an upper-case placeholder
+// prefix, e.g. "order id" becomes "ORDER_ID".
+func placeholderLabel(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, strings.TrimSpace(name))
+}
+
+// Session masks the text of one LLM call and restores the values in its
+// reply. The same value always gets the same placeholder within a session.
+type Session struct {
+	r       *Redactor
+	names   []string
+	values  map[string]string // placeholder -> original
+	byValue map[string]string // original -> placeholder
+	counts  map[string]int
+}
+
+// Session starts masking a new call. It is safe to call on a nil Redactor.
+func (r *Redactor) Session() *Session {
+	return &Session{
+		r:       r,
+		values:  make(map[string]string),
+		byValue: make(map[string]string),
+		counts:  make(map[string]int),
+	}
+}
+
+// Sender marks name as a sender to mask, if senders are redacted.
+func (s *Session) Sender(name string) {
+	if s.r == nil || !s.r.senders || strings.TrimSpace(name) == "" || slices.Contains(s.names, name) {
+		return
+	}
+	s.names = append(s.names, name)
+}
+
+// Redact masks the values in text.
+func (s *Session) Redact(text string) string {
+	if s.r == nil {
+		return text
+	}
+	// Longest names first, so "Ann Lee" isn't masked as "Ann" plus "Lee"
+	slices.SortFunc(s.names, func(a, b string) int { return len(b) - len(a) })
+	for _, name := range s.names {
+		text = nameRe(name).ReplaceAllLiteralString(text, s.placeholder("SENDER", name))
+	}
+
+	for _, d := range s.r.detectors {
+		text = d.re.ReplaceAllStringFunc(text, func(match string) string {
+			value := match
+			if d.group > 0 {
+				sub := d.re.FindStringSubmatch(match)
+				if len(sub) <= d.group || sub[d.group] == "" {
+					return match
+				}
+				value = sub[d.group]
+			}
+			if isPlaceholder(value) || (d.valid != nil && !d.valid(value)) {
+				return match
+			}
+			if d.group > 0 {
+				i := strings.LastIndex(match, value)
+				return match[:i] + s.placeholder(d.label, value) + match[i+len(value):]
+			}
+			return s.placeholder(d.label, value)
+		})
+	}
+	return text
+}
+
+// nameRe matches name as a whole word, so a sender called "Al" doesn't mask
+// part of "Already".
+func nameRe(name string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(name)
+	if r := []rune(name); isWordRune(r[0]) && isWordRune(r[len(r)-1]) {
+		pattern = `\b` + pattern + `\b`
+	}
+	return regexp.MustCompile(pattern)
+}
+
+func isWordRune(r rune) bool {
+	return r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// Restore puts the original values back in place of placeholders.
+func (s *Session) Restore(text string) string {
+	if len(s.values) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(p string) string {
+		if v, ok := s.values[p]; ok {
+			return v
+		}
+		return p
+	})
+}
+
+// Count returns how many distinct values were masked.
+func (s *Session) Count() int {
+	return len(s.values)
+}
+
+var placeholderRe = regexp.MustCompile(`\[[A-Z0-9_]+_\d+\]`)
+
+func isPlaceholder(s string) bool {
+	return placeholderRe.MatchString(s)
+}
+
+func (s *Session) placeholder(label, value string) string {
+	if p, ok := s.byValue[value]; ok {
+		return p
+	}
+	s.counts[label]++
+	p := fmt.Sprintf("[%s_%d]", label, s.counts[label])
+	s.values[p] = value
+	s.byValue[value] = p
+	return p
+}
+
+// luhn validates a card number's check digit.
+func luhn(number string) bool {
+	var sum, n int
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// validIBAN checks an IBAN's mod-97 checksum.
+func validIBAN(iban string) bool {
+	iban = strings.ReplaceAll(iban, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	rem := 0
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
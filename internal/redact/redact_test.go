@@ -0,0 +1,187 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"5500005555555559", true},
+		{"378282246310005", true}, // 15-digit Amex
+		{"4111111111111112", false},
+		{"1234567812345678", false},
+		{"000000000000", false}, // too short, even though the sum is 0
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.number); got != tt.want {
+			t.Errorf("luhn(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestValidIBAN(t *testing.T) {
+	tests := []struct {
+		iban string
+		want bool
+	}{
+		{"DE89370400440532013000", true},
+		{"DE89 3704 0044 0532 0130 00", true},
+		{"GB82WEST12345698765432", true},
+		{"NO9386011117947", true}, // shortest in use
+		{"DE89370400440532013001", false},
+		{"GB82WEST12345698765433", false},
+		{"DE8937040044", false},           // too short
+		{"de89370400440532013000", false}, // lower case isn't accepted
+		{"DE89-3704-0044-0532-0130-00", false},
+	}
+	for _, tt := range tests {
+		if got := validIBAN(tt.iban); got != tt.want {
+			t.Errorf("validIBAN(%q) = %v, want %v", tt.iban, got, tt.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	r, err := New(config.RedactionConfig{
+		Patterns: []config.RedactionPattern{{Name: "order id", Pattern: `ORD-\d{6}`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "Write to ann.lee@example.com today", "Write to [EMAIL_1] today"},
+		{"same value twice", "a@b.io or a@b.io", "[EMAIL_1] or [EMAIL_1]"},
+		{"two values", "a@b.io or c@d.io", "[EMAIL_1] or [EMAIL_2]"},
+		{"card", "Card 4111 1111 1111 1111 was charged", "Card [CARD_1] was charged"},
+		{"not a card", "Ticket 4111 1111 1111 1112 is open", "Ticket 4111 1111 1111 1112 is open"},
+		{"iban", "Pay to DE89 3704 0044 0532 0130 00 by Friday", "Pay to [IBAN_1] by Friday"},
+		{"bad iban", "Ref DE89 3704 0044 0532 0130 02", "Ref DE89 3704 0044 0532 0130 02"},
+		{"otp keeps the word", "Your code: 482913", "Your code: [OTP_1]"},
+		{"otp before phrase", "482913 is your verification code", "[OTP_1] is your verification code"},
+		{"international phone", "Call +1 555 010 9999", "Call [PHONE_1]"},
+		{"us phone", "Call (555) 010-9999 now", "Call [PHONE_1] now"},
+		{"dates aren't phones", "Due 2025-03-15 at 14:00", "Due 2025-03-15 at 14:00"},
+		{"custom pattern", "Order ORD-123456 shipped", "Order [ORDER_ID_1] shipped"},
+		{"nothing to mask", "See you at lunch", "See you at lunch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := r.Session()
+			got := s.Redact(tt.in)
+			if got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if back := s.Restore(got); back != tt.in {
+				t.Errorf("Restore(%q) = %q, want %q", got, back, tt.in)
+			}
+		})
+	}
+}
+
+func TestRedactSenders(t *testing.T) {
+	tests := []struct {
+		name    string
+		senders bool
+		names   []string
+		in      string
+		want    string
+	}{
+		{"disabled", false, []string{"Ann"}, "Ann says hi", "Ann says hi"},
+		{"whole word only", true, []string{"Al"}, "Al already left", "[SENDER_1] already left"},
+		{"longest name first", true, []string{"Ann", "Ann Lee"}, "Ann Lee and Ann", "[SENDER_1] and [SENDER_2]"},
+		{"blank name ignored", true, []string{" "}, "hello there", "hello there"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(config.RedactionConfig{Types: []string{KindEmail}, Senders: tt.senders})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := r.Session()
+			for _, name := range tt.names {
+				s.Sender(name)
+			}
+			if got := s.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	r, err := New(config.RedactionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := r.Session()
+	s.Redact("Mail ann@example.com or call +44 20 7946 0958")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Reply to [EMAIL_1]", "Reply to ann@example.com"},
+		{"Call [PHONE_1] about [EMAIL_1]", "Call +44 20 7946 0958 about ann@example.com"},
+		{"Unknown [EMAIL_2] stays", "Unknown [EMAIL_2] stays"},
+		{"[not a placeholder]", "[not a placeholder]"},
+		{"no brackets", "no brackets"},
+	}
+	for _, tt := range tests {
+		if got := s.Restore(tt.in); got != tt.want {
+			t.Errorf("Restore(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+	s := r.Session()
+	s.Sender("Ann")
+	in := "Ann: ann@example.com"
+	if got := s.Redact(in); got != in {
+		t.Errorf("nil Redactor changed %q to %q", in, got)
+	}
+	if s.Count() != 0 {
+		t.Errorf("nil Redactor masked %d values", s.Count())
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RedactionConfig
+		wantErr string
+	}{
+		{"defaults", config.RedactionConfig{}, ""},
+		{"some types", config.RedactionConfig{Types: []string{KindEmail, KindOTP}}, ""},
+		{"unknown type", config.RedactionConfig{Types: []string{"ssn"}}, `unknown redaction type "ssn"`},
+		{"unnamed pattern", config.RedactionConfig{Patterns: []config.RedactionPattern{{Pattern: `x`}}}, "needs a name"},
+		{"bad pattern", config.RedactionConfig{Patterns: []config.RedactionPattern{{Name: "x", Pattern: `(`}}}, "invalid redaction pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("New failed: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("New error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// Engine holds the current rules and reloads them when the file changes.
+type Engine struct {
+	path string
+
+	mu      sync.RWMutex
+	file    *File
+	loc     *time.Location
+	modTime time.Time
+}
+
+// Load reads the rules file at path. A missing file means no rules until
+// one is created.
+func Load(path string) (*Engine, error) {
+	if path == "" {
+		path = "./rules.yaml"
+	}
+	e := &Engine{path: path, file: &File{}, loc: time.Local}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// reload re-reads the file if it changed since the last load. The current
+// rules are kept when the new file is invalid.
+func (e *Engine) reload() error {
+	info, err := os.Stat(e.path)
+	if errors.Is(err, os.ErrNotExist) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if !e.modTime.IsZero() {
+			slog.Info("Rules file removed, no rules apply", "path", e.path)
+			e.file, e.loc, e.modTime = &File{}, time.Local, time.Time{}
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	e.mu.RLock()
+	unchanged := info.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid rules file %s: %w", e.path, err)
+	}
+	loc := time.Local
+	if f.Timezone != "" {
+		loc, _ = time.LoadLocation(f.Timezone) // checked by Parse
+	}
+
+	e.mu.Lock()
+	e.file, e.loc, e.modTime = f, loc, info.ModTime()
+	e.mu.Unlock()
+
+	slog.Info("Loaded rules", "path", e.path, "rules", len(f.Rules))
+	return nil
+}
+
+// Watch reloads the rules whenever the file changes, until ctx is done.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.reload(); err != nil {
+				// Log a broken file once, not on every tick
+				if err.Error() != lastErr {
+					slog.Error("Failed to reload rules, keeping the previous ones", "error", err)
+					lastErr = err.Error()
+				}
+				continue
+			}
+			lastErr = ""
+		}
+	}
+}
+
+// Routes returns the notifier routes the rules refer to.
+func (e *Engine) Routes() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var routes []string
+	for _, r := range e.file.Rules {
+		if r.Then.Notify != "" && !slices.Contains(routes, r.Then.Notify) {
+			routes = append(routes, r.Then.Notify)
+		}
+	}
+	return routes
+}
+
+// Match is a rule that applied to a message.
+type Match struct {
+	Rule   string
+	Action Action
+}
+
+// Decision is the combined effect of the rules that matched a message. Later
+// rules override earlier ones; tags accumulate.
+type Decision struct {
+	Matches  []Match
+	Drop     bool
+	SkipLLM  bool
+	Urgent   *bool
+	Priority string
+	Notify   string
+	Tags     []string
+
+	file   *File // the rules evaluated before the LLM, reused after it
+	loc    *time.Location
+	stopAt int // rules from this index on are skipped after a stop
+}
+
+// Before evaluates the rules that don't depend on the classification. It is
+// safe to call on a nil Engine.
+func (e *Engine) Before(msg *message.Message) *Decision {
+	d := &Decision{file: &File{}, loc: time.Local}
+	if e == nil {
+		return d
+	}
+	e.mu.RLock()
+	d.file, d.loc = e.file, e.loc
+	e.mu.RUnlock()
+	d.stopAt = len(d.file.Rules)
+
+	d.evaluate(msg, nil, false)
+	return d
+}
+
+// After evaluates the rules that depend on the classification, against the
+// same rules Before used, and returns the ones that matched.
+func (d *Decision) After(msg *message.Message, result *classifier.ClassificationResult) []Match {
+	n := len(d.Matches)
+	d.evaluate(msg, result, true)
+	return d.Matches[n:]
+}
+
+func (d *Decision) evaluate(msg *message.Message, result *classifier.ClassificationResult, after bool) {
+	at := msg.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	at = at.In(d.loc)
+
+	for i := 0; i < d.stopAt; i++ {
+		r := &d.file.Rules[i]
+		if r.After() != after || !r.When.matches(msg, result, at) {
+			continue
+		}
+		d.apply(r)
+		if r.Stop {
+			d.stopAt = i + 1
+		}
+	}
+}
+
+func (d *Decision) apply(r *Rule) {
+	a := r.Then
+	d.Matches = append(d.Matches, Match{Rule: r.Name, Action: a})
+	d.Drop = d.Drop || a.Drop
+	d.SkipLLM = d.SkipLLM || a.SkipLLM
+	if a.Urgent != nil {
+		d.Urgent = a.Urgent
+	}
+	if a.Priority != "" {
+		d.Priority = a.Priority
+	}
+	if a.Notify != "" {
+		d.Notify = a.Notify
+	}
+	for _, t := range a.Tags {
+		if !slices.Contains(d.Tags, t) {
+			d.Tags = append(d.Tags, t)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package rules evaluates a YAML file of declarative rules against messages,
+// before the LLM sees them and again once they are classified, so routine
+// policy ("anything from the CI bot in #alerts is urgent") needs no code.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// Priorities a rule can set, with the same meaning as a contact's.
+const (
+	PriorityHigh = "high" // hinted to the classifier as important
+	PriorityLow  = "low"  // never urgent
+)
+
+// File is the rules file.
+type File struct {
+	Timezone string `yaml:"timezone"` // for hours and days, defaults to local time
+	Rules    []Rule `yaml:"rules"`
+}
+
+// Rule applies its actions to messages matching all of its conditions.
+type Rule struct {
+	Name string    `yaml:"name"`
+	When Condition `yaml:"when"`
+	Then Action    `yaml:"then"`
+	Stop bool      `yaml:"stop"` // don't evaluate later rules after a match
+}
+
+// Condition is a set of tests that must all pass. Within a list, any entry
+// may match. Sender, chat and metadata values are case-insensitive globs.
+type Condition struct {
+	Source   List              `yaml:"source"`
+	Account  List              `yaml:"account"`
+	Sender   List              `yaml:"sender"`
+	Chat     List              `yaml:"chat"`     // chat title or Slack channel, with or without "#"
+	Metadata map[string]string `yaml:"metadata"` // "*" only requires the key to be set
+	Text     string            `yaml:"text"`     // regular expression
+	Hours    string            `yaml:"hours"`    // "09:00-18:00"; may wrap past midnight
+	Days     List              `yaml:"days"`     // "mon" … "sun"
+
+	// Conditions on the classification, which move the rule after the LLM.
+	Urgent         *bool `yaml:"urgent"`
+	HasActionItems *bool `yaml:"has_action_items"`
+
+	text           *regexp.Regexp
+	fromMin, toMin int
+	days           map[time.Weekday]bool
+	hasHours       bool
+}
+
+// Action is what a matching rule does.
+type Action struct {
+	Drop     bool     `yaml:"drop"`     // record the message but don't classify or notify
+	SkipLLM  bool     `yaml:"skip_llm"` // don't call the classifier
+	Urgent   *bool    `yaml:"urgent"`   // force urgency on or off
+	Priority string   `yaml:"priority"` // "high" or "low"
+	Notify   string   `yaml:"notify"`   // notifier route, from pushover.routes
+	Tags     []string `yaml:"tags"`
+}
+
+// List is a string list that may also be written as a single string.
+type List []string
+
+// UnmarshalYAML accepts a scalar or a sequence.
+func (l *List) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = List{node.Value}
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// Parse decodes and compiles a rules file.
+func Parse(data []byte) (*File, error) {
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+	if f.Timezone != "" {
+		if _, err := time.LoadLocation(f.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", f.Timezone, err)
+		}
+	}
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+	}
+	return &f, nil
+}
+
+func (r *Rule) compile() error {
+	c := &r.When
+	if c.Text != "" {
+		re, err := regexp.Compile(c.Text)
+		if err != nil {
+			return fmt.Errorf("invalid text pattern: %w", err)
+		}
+		c.text = re
+	}
+	if c.Hours != "" {
+		from, to, ok := strings.Cut(c.Hours, "-")
+		var err error
+		if !ok {
+			return fmt.Errorf("hours must look like 09:00-18:00, not %q", c.Hours)
+		}
+		if c.fromMin, err = parseClock(from); err != nil {
+			return err
+		}
+		if c.toMin, err = parseClock(to); err != nil {
+			return err
+		}
+		c.hasHours = true
+	}
+	if len(c.Days) > 0 {
+		c.days = make(map[time.Weekday]bool)
+		for _, d := range c.Days {
+			day := strings.ToLower(strings.TrimSpace(d))
+			wd, ok := weekdays[day[:min(3, len(day))]]
+			if !ok {
+				return fmt.Errorf("unknown day %q", d)
+			}
+			c.days[wd] = true
+		}
+	}
+	for _, patterns := range [][]string{c.Source, c.Account, c.Sender, c.Chat} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q", p)
+			}
+		}
+	}
+
+	a := r.Then
+	switch a.Priority {
+	case "", PriorityHigh, PriorityLow:
+	default:
+		return fmt.Errorf("unknown priority %q (want %q or %q)", a.Priority, PriorityHigh, PriorityLow)
+	}
+	if r.After() && a.SkipLLM {
+		return errors.New("skip_llm can't depend on the classification")
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses "HH:MM" into minutes past midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// After reports whether the rule looks at the classification, and so runs
+// after the LLM rather than before.
+func (r *Rule) After() bool {
+	return r.When.Urgent != nil || r.When.HasActionItems != nil
+}
+
+// matches reports whether msg (and its classification, for rules that run
+// after the LLM) passes every condition. at is the message time in the
+// rules' timezone.
+func (c *Condition) matches(msg *message.Message, result *classifier.ClassificationResult, at time.Time) bool {
+	if len(c.Source) > 0 && !matchAny(c.Source, string(msg.Source)) {
+		return false
+	}
+	if len(c.Account) > 0 && !matchAny(c.Account, msg.Metadata["account"]) {
+		return false
+	}
+	if len(c.Sender) > 0 && !matchAny(c.Sender, msg.Sender) {
+		return false
+	}
+	if len(c.Chat) > 0 {
+		chat := msg.Metadata["chat_title"]
+		if chat == "" {
+			chat = msg.Metadata["channel_name"]
+		}
+		if !matchAny(c.Chat, strings.TrimPrefix(chat, "#")) {
+			return false
+		}
+	}
+	for k, pattern := range c.Metadata {
+		v, ok := msg.Metadata[k]
+		if !ok || v == "" || !matchAny([]string{pattern}, v) {
+			return false
+		}
+	}
+	if c.text != nil && !c.text.MatchString(msg.Text) {
+		return false
+	}
+	if c.hasHours {
+		m := at.Hour()*60 + at.Minute()
+		if c.fromMin <= c.toMin {
+			if m < c.fromMin || m >= c.toMin {
+				return false
+			}
+		} else if m < c.fromMin && m >= c.toMin { // wraps past midnight
+			return false
+		}
+	}
+	if c.days != nil && !c.days[at.Weekday()] {
+		return false
+	}
+
+	if c.Urgent != nil && (result == nil || result.IsUrgent != *c.Urgent) {
+		return false
+	}
+	if c.HasActionItems != nil && (result == nil || (len(result.ActionItems) > 0) != *c.HasActionItems) {
+		return false
+	}
+	return true
+}
+
+// matchAny reports whether s matches any of the case-insensitive globs. A
+// leading "#" is ignored on both sides so "#alerts" and "alerts" agree.
+func matchAny(patterns []string, s string) bool {
+	s = strings.ToLower(strings.TrimPrefix(s, "#"))
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimPrefix(p, "#"))
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String summarizes the action for the message's outcomes.
+func (a Action) String() string {
+	var parts []string
+	if a.Drop {
+		parts = append(parts, "drop")
+	}
+	if a.SkipLLM {
+		parts = append(parts, "skip LLM")
+	}
+	if a.Urgent != nil {
+		if *a.Urgent {
+			parts = append(parts, "urgent")
+		} else {
+			parts = append(parts, "not urgent")
+		}
+	}
+	if a.Priority != "" {
+		parts = append(parts, a.Priority+" priority")
+	}
+	if a.Notify != "" {
+		parts = append(parts, "notify via "+a.Notify)
+	}
+	if len(a.Tags) > 0 {
+		parts = append(parts, "tag "+strings.Join(a.Tags, ", "))
+	}
+	if len(parts) == 0 {
+		return "no action"
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,268 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// engine loads rules from YAML written to a temporary file.
+func engine(t *testing.T, rules string) *Engine {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// newMessage returns a Slack message sent on Wednesday, 2025-03-12 at 10:30 UTC.
+func newMessage(sender, text string, metadata map[string]string) *message.Message {
+	msg := message.NewMessage(message.SourceSlack, sender, text)
+	msg.Timestamp = time.Date(2025, 3, 12, 10, 30, 0, 0, time.UTC)
+	for k, v := range metadata {
+		msg.Metadata[k] = v
+	}
+	return msg
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		when string
+		msg  *message.Message
+		want bool
+	}{
+		{"source", `source: slack`, newMessage("bob", "hi", nil), true},
+		{"other source", `source: [gmail, telegram]`, newMessage("bob", "hi", nil), false},
+		{"sender glob", `sender: "ci-*"`, newMessage("CI-Bot", "hi", nil), true},
+		{"sender mismatch", `sender: "ci-*"`, newMessage("bob", "hi", nil), false},
+		{"account", `account: work`, newMessage("bob", "hi", map[string]string{"account": "work"}), true},
+		{"channel with hash", `chat: "#alerts"`, newMessage("bob", "hi", map[string]string{"channel_name": "alerts"}), true},
+		{"chat title", `chat: "family*"`, newMessage("bob", "hi", map[string]string{"chat_title": "Family chat"}), true},
+		{"no chat", `chat: alerts`, newMessage("bob", "hi", nil), false},
+		{"metadata set", `metadata: {severity: "*"}`, newMessage("bob", "hi", map[string]string{"severity": "low"}), true},
+		{"metadata missing", `metadata: {severity: "*"}`, newMessage("bob", "hi", nil), false},
+		{"metadata value", `metadata: {severity: critical}`, newMessage("bob", "hi", map[string]string{"severity": "warning"}), false},
+		{"text", `text: "(?i)deploy(ed)? failed"`, newMessage("bob", "Deploy failed on prod", nil), true},
+		{"text mismatch", `text: "(?i)deploy(ed)? failed"`, newMessage("bob", "Deploy succeeded", nil), false},
+		{"within hours", `hours: "09:00-18:00"`, newMessage("bob", "hi", nil), true},
+		{"outside hours", `hours: "18:00-09:00"`, newMessage("bob", "hi", nil), false},
+		{"day", `days: [mon, wednesday]`, newMessage("bob", "hi", nil), true},
+		{"other day", `days: sat`, newMessage("bob", "hi", nil), false},
+		{"all must hold", "sender: bob\ntext: urgent", newMessage("bob", "hi", nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := "timezone: UTC\nrules:\n  - when:\n" + indent(tt.when, 6) + "\n    then: {tags: [hit]}\n"
+			d := engine(t, rules).Before(tt.msg)
+			if got := len(d.Matches) == 1; got != tt.want {
+				t.Errorf("rule %q matched = %v, want %v", tt.when, got, tt.want)
+			}
+		})
+	}
+}
+
+func indent(s string, n int) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func TestHoursWrapPastMidnight(t *testing.T) {
+	e := engine(t, `
+timezone: Europe/Berlin
+rules:
+  - name: night
+    when: {hours: "22:00-07:00"}
+    then: {priority: low}
+`)
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2025, 3, 12, 22, 30, 0, 0, time.UTC), true},  // 23:30 in Berlin
+		{time.Date(2025, 3, 12, 5, 59, 0, 0, time.UTC), true},   // 06:59
+		{time.Date(2025, 3, 12, 6, 0, 0, 0, time.UTC), false},   // 07:00
+		{time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC), false},  // 13:00
+		{time.Date(2025, 3, 12, 20, 59, 0, 0, time.UTC), false}, // 21:59
+	}
+	for _, tt := range tests {
+		msg := newMessage("bob", "hi", nil)
+		msg.Timestamp = tt.at
+		if got := e.Before(msg).Priority == PriorityLow; got != tt.want {
+			t.Errorf("at %v matched = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestDecision(t *testing.T) {
+	e := engine(t, `
+rules:
+  - name: ci bot
+    when: {sender: ci-bot}
+    then: {skip_llm: true, urgent: true, priority: high, tags: [ci]}
+  - name: quiet builds
+    when: {sender: ci-bot, text: "build passed"}
+    then: {urgent: false, priority: low, notify: quiet, tags: [ci, build]}
+  - name: newsletters
+    when: {sender: "*newsletter*"}
+    then: {drop: true}
+    stop: true
+  - name: never reached for newsletters
+    when: {text: "."}
+    then: {tags: [seen]}
+`)
+	tests := []struct {
+		name     string
+		sender   string
+		text     string
+		matches  []string
+		drop     bool
+		skipLLM  bool
+		urgent   *bool
+		priority string
+		notify   string
+		tags     []string
+	}{
+		{
+			name: "no rule", sender: "alice", text: "",
+		},
+		{
+			name: "skip the LLM and force urgency", sender: "ci-bot", text: "build failed",
+			matches: []string{"ci bot", "never reached for newsletters"},
+			skipLLM: true, urgent: ptr(true), priority: PriorityHigh, tags: []string{"ci", "seen"},
+		},
+		{
+			name: "later rules override earlier ones", sender: "ci-bot", text: "build passed",
+			matches: []string{"ci bot", "quiet builds", "never reached for newsletters"},
+			skipLLM: true, urgent: ptr(false), priority: PriorityLow, notify: "quiet", tags: []string{"ci", "build", "seen"},
+		},
+		{
+			name: "stop ends the list", sender: "weekly-newsletter", text: "news.",
+			matches: []string{"newsletters"}, drop: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := e.Before(newMessage(tt.sender, tt.text, nil))
+			var matched []string
+			for _, m := range d.Matches {
+				matched = append(matched, m.Rule)
+			}
+			if !slices.Equal(matched, tt.matches) {
+				t.Errorf("matched %q, want %q", matched, tt.matches)
+			}
+			if d.Drop != tt.drop || d.SkipLLM != tt.skipLLM {
+				t.Errorf("drop %v, skip_llm %v; want %v, %v", d.Drop, d.SkipLLM, tt.drop, tt.skipLLM)
+			}
+			if (d.Urgent == nil) != (tt.urgent == nil) || (d.Urgent != nil && *d.Urgent != *tt.urgent) {
+				t.Errorf("urgent = %v, want %v", fmtBool(d.Urgent), fmtBool(tt.urgent))
+			}
+			if d.Priority != tt.priority || d.Notify != tt.notify {
+				t.Errorf("priority %q, notify %q; want %q, %q", d.Priority, d.Notify, tt.priority, tt.notify)
+			}
+			if !slices.Equal(d.Tags, tt.tags) {
+				t.Errorf("tags %q, want %q", d.Tags, tt.tags)
+			}
+		})
+	}
+}
+
+func TestAfterClassification(t *testing.T) {
+	e := engine(t, `
+rules:
+  - name: urgent alerts
+    when: {chat: alerts, urgent: true}
+    then: {notify: pager}
+  - name: tasks
+    when: {has_action_items: true}
+    then: {tags: [todo]}
+  - name: before
+    when: {chat: alerts}
+    then: {priority: high}
+    stop: true
+  - name: after the stop
+    when: {urgent: false}
+    then: {tags: [unreachable]}
+`)
+	msg := newMessage("bob", "disk full", map[string]string{"channel_name": "alerts"})
+	d := e.Before(msg)
+	if len(d.Matches) != 1 || d.Matches[0].Rule != "before" || d.Priority != PriorityHigh {
+		t.Fatalf("before the LLM, matched %v with priority %q", d.Matches, d.Priority)
+	}
+
+	result := &classifier.ClassificationResult{
+		IsUrgent:    true,
+		ActionItems: []classifier.ActionItem{{Title: "Free up disk space"}},
+	}
+	after := d.After(msg, result)
+	var names []string
+	for _, m := range after {
+		names = append(names, m.Rule)
+	}
+	if !slices.Equal(names, []string{"urgent alerts", "tasks"}) {
+		t.Errorf("after the LLM, matched %q", names)
+	}
+	if d.Notify != "pager" || !slices.Equal(d.Tags, []string{"todo"}) {
+		t.Errorf("notify %q, tags %q", d.Notify, d.Tags)
+	}
+}
+
+func TestNilEngine(t *testing.T) {
+	var e *Engine
+	d := e.Before(newMessage("bob", "hi", nil))
+	if len(d.Matches) != 0 || d.Drop || d.SkipLLM {
+		t.Errorf("nil engine decided %+v", d)
+	}
+	if m := d.After(newMessage("bob", "hi", nil), &classifier.ClassificationResult{IsUrgent: true}); len(m) != 0 {
+		t.Errorf("nil engine matched %v after the LLM", m)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{"bad regexp", `rules: [{when: {text: "("}}]`, "invalid text pattern"},
+		{"bad hours", `rules: [{when: {hours: "9-5"}}]`, "invalid time"},
+		{"hours without range", `rules: [{when: {hours: "09:00"}}]`, "hours must look like"},
+		{"bad day", `rules: [{when: {days: [someday]}}]`, "unknown day"},
+		{"bad glob", `rules: [{when: {sender: "[a"}}]`, "invalid pattern"},
+		{"bad priority", `rules: [{then: {priority: urgent}}]`, "unknown priority"},
+		{"skip_llm after the LLM", `rules: [{when: {urgent: true}, then: {skip_llm: true}}]`, "skip_llm can't depend"},
+		{"bad timezone", `timezone: Mars/Olympus`, "invalid timezone"},
+		{"not yaml", `rules: [`, "failed to parse rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.rules))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ptr(b bool) *bool { return &b }
+
+func fmtBool(b *bool) string {
+	if b == nil {
+		return "unset"
+	}
+	if *b {
+		return "true"
+	}
+	return "false"
+}
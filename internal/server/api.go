@@ -0,0 +1,607 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/contacts"
+	"github.com/emirlan/notifylm/internal/feedback"
+	"github.com/emirlan/notifylm/internal/message"
+	"github.com/emirlan/notifylm/internal/store"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+const (
+	defaultAPILimit = 50
+	maxAPILimit     = 500
+)
+
+// registerAPI mounts the versioned JSON API. Its types are decoupled from
+// the store's so the wire format stays stable as internals change.
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/messages", s.apiMessages)
+	mux.HandleFunc("GET /api/v1/messages/{seq}", s.apiMessage)
+	mux.HandleFunc("POST /api/v1/messages/{seq}/feedback", s.apiAddFeedback)
+	mux.HandleFunc("GET /api/v1/feedback", s.apiFeedback)
+	mux.HandleFunc("GET /api/v1/contacts", s.apiContacts)
+	mux.HandleFunc("PUT /api/v1/contacts", s.apiReplaceContacts)
+	mux.HandleFunc("GET /api/v1/action-items", s.apiActionItems)
+	mux.HandleFunc("GET /api/v1/notifications", s.apiNotifications)
+	mux.HandleFunc("GET /api/v1/stats", s.apiStats)
+	mux.HandleFunc("GET /api/v1/listeners", s.apiListeners)
+	mux.HandleFunc("GET /api/v1/usage", s.apiUsage)
+	mux.HandleFunc("GET /api/v1/openapi.yaml", s.apiSpec)
+}
+
+// --- Wire types ---
+
+type apiMessage struct {
+	Seq           uint64            `json:"seq"`
+	ID            string            `json:"id"`
+	Source        message.Source    `json:"source"`
+	Account       string            `json:"account,omitempty"`
+	Sender        string            `json:"sender"`
+	Text          string            `json:"text"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Classified    bool              `json:"classified"`
+	ClassifiedBy  string            `json:"classified_by,omitempty"`
+	Urgent        bool              `json:"urgent"`
+	ActionItems   []apiActionItem   `json:"action_items"`
+	NotifiedAt    *time.Time        `json:"notified_at,omitempty"`
+	EventsCreated int               `json:"events_created"`
+	ProcessedAt   time.Time         `json:"processed_at"`
+	AppLink       string            `json:"app_link,omitempty"`
+	WebLink       string            `json:"web_link,omitempty"`
+}
+
+// apiMessageDetail adds how a message was classified and what was done about it.
+type apiMessageDetail struct {
+	apiMessage
+	ClassifyError string       `json:"classify_error,omitempty"`
+	Trace         *apiTrace    `json:"trace,omitempty"`
+	Outcomes      []apiOutcome `json:"outcomes"`
+}
+
+type apiTrace struct {
+	Method       string `json:"method"`
+	Model        string `json:"model,omitempty"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	UserPrompt   string `json:"user_prompt,omitempty"`
+	RawResponse  string `json:"raw_response,omitempty"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	LatencyMS    int64  `json:"latency_ms"`
+	Note         string `json:"note,omitempty"`
+
+	Fallbacks        []string `json:"fallbacks,omitempty"`
+	Redacted         int      `json:"redacted,omitempty"`
+	PromptTokens     int      `json:"prompt_tokens,omitempty"`
+	CompletionTokens int      `json:"completion_tokens,omitempty"`
+	CostUSD          float64  `json:"cost_usd,omitempty"`
+}
+
+type apiUsage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+func toAPIUsage(u classifier.Usage) apiUsage {
+	return apiUsage{Calls: u.Calls, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, CostUSD: u.Cost}
+}
+
+func toAPIUsageBySource(bySource map[message.Source]classifier.Usage) map[message.Source]apiUsage {
+	out := make(map[message.Source]apiUsage, len(bySource))
+	for src, u := range bySource {
+		out[src] = toAPIUsage(u)
+	}
+	return out
+}
+
+type apiOutcome struct {
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"`
+	Status  string    `json:"status"`
+	Detail  string    `json:"detail,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// apiClassification is the parsed result shown on the message detail page.
+type apiClassification struct {
+	Urgent      bool            `json:"urgent"`
+	ActionItems []apiActionItem `json:"action_items"`
+}
+
+type apiCorrection struct {
+	Source     message.Source `json:"source"`
+	Account    string         `json:"account,omitempty"`
+	Sender     string         `json:"sender"`
+	MessageID  string         `json:"message_id,omitempty"`
+	Text       string         `json:"text"`
+	Label      string         `json:"label"`
+	ActionItem string         `json:"action_item,omitempty"`
+	Note       string         `json:"note,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type apiActionItem struct {
+	Title           string     `json:"title"`
+	Description     string     `json:"description,omitempty"`
+	DateTime        *time.Time `json:"datetime,omitempty"`
+	DurationMinutes int        `json:"duration_minutes,omitempty"`
+	AllDay          bool       `json:"all_day,omitempty"`
+}
+
+// apiMessageRef identifies the message an action item or notification came from.
+type apiMessageRef struct {
+	ID      string         `json:"id"`
+	Source  message.Source `json:"source"`
+	Account string         `json:"account,omitempty"`
+	Sender  string         `json:"sender"`
+	Text    string         `json:"text"`
+}
+
+type apiActionItemWithContext struct {
+	apiActionItem
+	EventCreated bool          `json:"event_created"`
+	TaskCreated  bool          `json:"task_created"`
+	ProcessedAt  time.Time     `json:"processed_at"`
+	Message      apiMessageRef `json:"message"`
+}
+
+type apiNotification struct {
+	Reason  string        `json:"reason"`
+	SentAt  time.Time     `json:"sent_at"`
+	Message apiMessageRef `json:"message"`
+}
+
+type apiStats struct {
+	TotalMessages     int                    `json:"total_messages"`
+	UrgentMessages    int                    `json:"urgent_messages"`
+	TotalActionItems  int                    `json:"total_action_items"`
+	NotificationsSent int                    `json:"notifications_sent"`
+	EventsCreated     int                    `json:"events_created"`
+	BySource          map[message.Source]int `json:"by_source"`
+	LLMCalls          int                    `json:"llm_calls"`
+	CacheHits         int                    `json:"cache_hits"`
+	SimilarHits       int                    `json:"similar_hits"`
+}
+
+type apiListener struct {
+	Name          string         `json:"name"`
+	Source        message.Source `json:"source"`
+	Account       string         `json:"account,omitempty"`
+	Connected     bool           `json:"connected"`
+	AwaitingLogin bool           `json:"awaiting_login"`
+	MessageCount  int            `json:"message_count"`
+	LastMessage   *time.Time     `json:"last_message,omitempty"`
+}
+
+func toAPIMessage(pm store.ProcessedMessage) apiMessage {
+	m := pm.Message
+	out := apiMessage{
+		Seq:           pm.Seq,
+		ID:            m.ID,
+		Source:        m.Source,
+		Account:       m.Metadata["account"],
+		Sender:        m.Sender,
+		Text:          m.Text,
+		Timestamp:     m.Timestamp,
+		Metadata:      m.Metadata,
+		ActionItems:   []apiActionItem{},
+		NotifiedAt:    pm.NotifiedAt,
+		EventsCreated: pm.EventsCreated,
+		ProcessedAt:   pm.ProcessedAt,
+		AppLink:       m.AppLink(),
+		WebLink:       m.WebLink(),
+	}
+	if c := pm.Classification; c != nil {
+		out.Classified = true
+		out.ClassifiedBy = pm.ClassifiedBy()
+		out.Urgent = c.IsUrgent
+		for _, item := range c.ActionItems {
+			out.ActionItems = append(out.ActionItems, toAPIActionItem(item))
+		}
+	}
+	return out
+}
+
+func toAPIMessageDetail(pm store.ProcessedMessage) apiMessageDetail {
+	out := apiMessageDetail{
+		apiMessage:    toAPIMessage(pm),
+		ClassifyError: pm.ClassifyError,
+		Outcomes:      []apiOutcome{},
+	}
+	if pm.Classification != nil {
+		if t := pm.Classification.Trace; t != nil {
+			out.Trace = &apiTrace{
+				Method:       t.Method,
+				Model:        t.Model,
+				SystemPrompt: t.SystemPrompt,
+				UserPrompt:   t.UserPrompt,
+				RawResponse:  t.RawResponse,
+				FinishReason: t.FinishReason,
+				LatencyMS:    t.Latency.Milliseconds(),
+				Note:         t.Note,
+
+				Fallbacks:        t.Fallbacks,
+				Redacted:         t.Redacted,
+				PromptTokens:     t.PromptTokens,
+				CompletionTokens: t.CompletionTokens,
+				CostUSD:          t.Cost,
+			}
+		}
+	}
+	for _, o := range pm.Outcomes {
+		out.Outcomes = append(out.Outcomes, apiOutcome{
+			Kind:    o.Kind,
+			Subject: o.Subject,
+			Status:  o.Status,
+			Detail:  o.Detail,
+			At:      o.At,
+		})
+	}
+	return out
+}
+
+func toAPIClassification(c *classifier.ClassificationResult) apiClassification {
+	out := apiClassification{Urgent: c.IsUrgent, ActionItems: []apiActionItem{}}
+	for _, item := range c.ActionItems {
+		out.ActionItems = append(out.ActionItems, toAPIActionItem(item))
+	}
+	return out
+}
+
+func toAPICorrection(c feedback.Correction) apiCorrection {
+	return apiCorrection{
+		Source:     c.Source,
+		Account:    c.Account,
+		Sender:     c.Sender,
+		MessageID:  c.MessageID,
+		Text:       c.Text,
+		Label:      c.Label,
+		ActionItem: c.ActionItem,
+		Note:       c.Note,
+		CreatedAt:  c.CreatedAt,
+	}
+}
+
+func toAPIActionItem(item classifier.ActionItem) apiActionItem {
+	out := apiActionItem{
+		Title:           item.Title,
+		Description:     item.Description,
+		DurationMinutes: item.DurationMinutes,
+		AllDay:          item.AllDay,
+	}
+	if !item.DateTime.IsZero() {
+		t := item.DateTime
+		out.DateTime = &t
+	}
+	return out
+}
+
+func toAPIMessageRef(m *message.Message) apiMessageRef {
+	if m == nil {
+		return apiMessageRef{}
+	}
+	return apiMessageRef{
+		ID:      m.ID,
+		Source:  m.Source,
+		Account: m.Metadata["account"],
+		Sender:  m.Sender,
+		Text:    m.Text,
+	}
+}
+
+// --- Handlers ---
+
+func (s *Server) apiMessages(w http.ResponseWriter, r *http.Request) {
+	q, err := parseMessageQuery(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Fetch one extra to know whether another page exists.
+	limit := q.Limit
+	q.Limit++
+	results := s.store.QueryMessages(q)
+
+	resp := struct {
+		Messages   []apiMessage `json:"messages"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}{Messages: []apiMessage{}}
+
+	if len(results) > limit {
+		results = results[:limit]
+		resp.NextCursor = strconv.FormatUint(results[limit-1].Seq, 10)
+	}
+	for _, pm := range results {
+		resp.Messages = append(resp.Messages, toAPIMessage(pm))
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) apiMessage(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.ParseUint(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid seq %q", r.PathValue("seq")))
+		return
+	}
+	pm, ok := s.store.GetMessage(seq)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("message %d not found", seq))
+		return
+	}
+	writeJSON(w, toAPIMessageDetail(pm))
+}
+
+func (s *Server) apiAddFeedback(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.ParseUint(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid seq %q", r.PathValue("seq")))
+		return
+	}
+
+	var req struct {
+		Label      string `json:"label"`
+		ActionItem string `json:"action_item"`
+		Note       string `json:"note"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+
+	c, status, err := s.recordFeedback(seq, req.Label, req.ActionItem, req.Note)
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, toAPICorrection(c))
+}
+
+func (s *Server) apiFeedback(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	corrections := []apiCorrection{}
+	if s.feedback != nil {
+		for _, c := range s.feedback.Recent(limit) {
+			corrections = append(corrections, toAPICorrection(c))
+		}
+	}
+	writeJSON(w, map[string]any{"feedback": corrections})
+}
+
+func (s *Server) apiContacts(w http.ResponseWriter, r *http.Request) {
+	list := []contacts.Contact{}
+	if s.contacts != nil {
+		list = append(list, s.contacts.All()...)
+	}
+	writeJSON(w, map[string]any{"contacts": list})
+}
+
+// apiReplaceContacts replaces the whole address book.
+func (s *Server) apiReplaceContacts(w http.ResponseWriter, r *http.Request) {
+	if s.contacts == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("contacts are disabled"))
+		return
+	}
+
+	var req struct {
+		Contacts []contacts.Contact `json:"contacts"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+	if err := s.contacts.Replace(req.Contacts); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, map[string]any{"contacts": s.contacts.All()})
+}
+
+// parseMessageQuery reads the /api/v1/messages filters and paging from the
+// query string.
+func parseMessageQuery(r *http.Request) (store.MessageQuery, error) {
+	v := r.URL.Query()
+	q, err := parseMessageFilters(v)
+	if err != nil {
+		return q, err
+	}
+
+	if q.Limit, err = parseLimit(v.Get("limit")); err != nil {
+		return q, err
+	}
+	if c := v.Get("cursor"); c != "" {
+		if q.Before, err = strconv.ParseUint(c, 10, 64); err != nil {
+			return q, fmt.Errorf("invalid cursor %q", c)
+		}
+	}
+	return q, nil
+}
+
+// parseMessageFilters reads the message filters shared by the API and the
+// dashboard's message feed.
+func parseMessageFilters(v url.Values) (store.MessageQuery, error) {
+	q := store.MessageQuery{
+		Text:   v.Get("q"),
+		Source: message.Source(v.Get("source")),
+		Sender: v.Get("sender"),
+	}
+
+	var err error
+	if q.Urgent, err = parseBoolParam("urgent", v.Get("urgent")); err != nil {
+		return q, err
+	}
+	if q.HasActionItems, err = parseBoolParam("has_action_items", v.Get("has_action_items")); err != nil {
+		return q, err
+	}
+	if q.Since, err = parseTimeParam(v.Get("since")); err != nil {
+		return q, fmt.Errorf("invalid since: %w", err)
+	}
+	if q.Until, err = parseTimeParam(v.Get("until")); err != nil {
+		return q, fmt.Errorf("invalid until: %w", err)
+	}
+	return q, nil
+}
+
+func (s *Server) apiActionItems(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	items := []apiActionItemWithContext{}
+	for _, a := range s.store.GetActionItems(limit) {
+		items = append(items, apiActionItemWithContext{
+			apiActionItem: toAPIActionItem(a.Item),
+			EventCreated:  a.EventCreated,
+			TaskCreated:   a.TaskCreated,
+			ProcessedAt:   a.ProcessedAt,
+			Message:       toAPIMessageRef(a.SourceMsg),
+		})
+	}
+	writeJSON(w, map[string]any{"action_items": items})
+}
+
+func (s *Server) apiNotifications(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	notifications := []apiNotification{}
+	for _, n := range s.store.GetRecentNotifications(limit) {
+		notifications = append(notifications, apiNotification{
+			Reason:  n.Reason,
+			SentAt:  n.SentAt,
+			Message: toAPIMessageRef(n.Message),
+		})
+	}
+	writeJSON(w, map[string]any{"notifications": notifications})
+}
+
+func (s *Server) apiStats(w http.ResponseWriter, r *http.Request) {
+	st := s.store.GetStats()
+	writeJSON(w, apiStats{
+		TotalMessages:     st.TotalMessages,
+		UrgentMessages:    st.UrgentMessages,
+		TotalActionItems:  st.TotalActionItems,
+		NotificationsSent: st.NotificationsSent,
+		EventsCreated:     st.EventsCreated,
+		BySource:          st.BySource,
+		LLMCalls:          st.LLMCalls,
+		CacheHits:         st.CacheHits,
+		SimilarHits:       st.SimilarHits,
+	})
+}
+
+func (s *Server) apiUsage(w http.ResponseWriter, r *http.Request) {
+	u := s.store.GetUsage(time.Now())
+	writeJSON(w, map[string]any{
+		"today":           toAPIUsage(u.Today),
+		"month":           toAPIUsage(u.Month),
+		"today_by_source": toAPIUsageBySource(u.TodayBySource),
+		"month_by_source": toAPIUsageBySource(u.MonthBySource),
+		"budget": map[string]any{
+			"daily_usd":      s.budget.DailyUSD,
+			"monthly_usd":    s.budget.MonthlyUSD,
+			"daily_tokens":   s.budget.DailyTokens,
+			"monthly_tokens": s.budget.MonthlyTokens,
+		},
+	})
+}
+
+func (s *Server) apiListeners(w http.ResponseWriter, r *http.Request) {
+	listeners := []apiListener{}
+	for _, ls := range s.store.GetListenerStatuses() {
+		listeners = append(listeners, apiListener{
+			Name:          ls.Name,
+			Source:        ls.Source,
+			Account:       ls.Account,
+			Connected:     ls.Connected,
+			AwaitingLogin: ls.AwaitingLogin,
+			MessageCount:  ls.MessageCount,
+			LastMessage:   ls.LastMessage,
+		})
+	}
+	writeJSON(w, map[string]any{"listeners": listeners})
+}
+
+func (s *Server) apiSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+// --- Helpers ---
+
+func parseLimit(v string) (int, error) {
+	if v == "" {
+		return defaultAPILimit, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid limit %q: must be a positive integer", v)
+	}
+	return min(n, maxAPILimit), nil
+}
+
+// parseBoolParam returns nil for an empty value, meaning "don't filter".
+func parseBoolParam(name, v string) (*bool, error) {
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: must be true or false", name, v)
+	}
+	return &b, nil
+}
+
+// parseTimeParam accepts RFC 3339 timestamps and plain dates (UTC midnight).
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)
+	}
+	return t, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write API response", "error", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
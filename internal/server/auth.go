@@ -0,0 +1,388 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+const (
+	sessionCookie = "notifylm_session"
+	signInCookie  = "notifylm_signin"
+	csrfField     = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// identity is who a request was authenticated as.
+type identity struct {
+	user      string // "" when auth is disabled
+	sessionID string // set for password sessions
+	bearer    bool   // authenticated with an API token, exempt from CSRF checks
+	signIn    string // pre-session cookie of a browser on the sign-in page
+}
+
+type identityKey struct{}
+
+// authenticator guards every route except the sign-in page. Sessions live in
+// memory, so a restart signs everyone out.
+type authenticator struct {
+	cfg     config.AuthConfig
+	secure  bool // set the Secure flag on cookies
+	secret  []byte
+	trusted []*net.IPNet
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+func newAuthenticator(cfg config.AuthConfig, secure bool) (*authenticator, error) {
+	a := &authenticator{
+		cfg:      cfg,
+		secure:   secure,
+		secret:   make([]byte, 32),
+		ttl:      time.Duration(cfg.SessionHours) * time.Hour,
+		sessions: make(map[string]session),
+	}
+	if a.ttl <= 0 {
+		a.ttl = 7 * 24 * time.Hour
+	}
+	if a.cfg.Mode == "" {
+		a.cfg.Mode = "none"
+	}
+	if a.cfg.ProxyHeader == "" {
+		a.cfg.ProxyHeader = "X-Forwarded-User"
+	}
+	if _, err := rand.Read(a.secret); err != nil {
+		return nil, fmt.Errorf("failed to generate CSRF secret: %w", err)
+	}
+
+	for _, p := range cfg.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if strings.Contains(p, ":") {
+				p += "/128"
+			} else {
+				p += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		a.trusted = append(a.trusted, ipNet)
+	}
+	return a, nil
+}
+
+// middleware authenticates the request and enforces CSRF tokens on
+// state-changing requests made by browsers.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/signin" {
+			id := identity{signIn: a.preSession(w, r)}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+			return
+		}
+
+		id, ok, reason := a.identify(r)
+		if !ok {
+			a.deny(w, r, reason)
+			return
+		}
+
+		if !id.bearer && !isSafeMethod(r.Method) && !a.validCSRF(r, id) {
+			http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// identify returns the caller's identity, or why it couldn't be established.
+func (a *authenticator) identify(r *http.Request) (identity, bool, string) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		token, found := strings.CutPrefix(h, "Bearer ")
+		if !found || !a.validAPIToken(token) {
+			return identity{}, false, "invalid bearer token"
+		}
+		return identity{user: "api", bearer: true}, true, ""
+	}
+
+	switch a.cfg.Mode {
+	case "password":
+		c, err := r.Cookie(sessionCookie)
+		if err != nil {
+			return identity{}, false, "not signed in"
+		}
+		sess, ok := a.session(c.Value)
+		if !ok {
+			return identity{}, false, "session expired"
+		}
+		return identity{user: sess.user, sessionID: c.Value}, true, ""
+	case "proxy":
+		if !a.fromTrustedProxy(r) {
+			return identity{}, false, "request did not come through a trusted proxy"
+		}
+		user := r.Header.Get(a.cfg.ProxyHeader)
+		if user == "" {
+			return identity{}, false, "missing " + a.cfg.ProxyHeader + " header"
+		}
+		return identity{user: user}, true, ""
+	default:
+		return identity{}, true, ""
+	}
+}
+
+// deny sends API clients and HTMX requests a 401, and browsers to sign in.
+func (a *authenticator) deny(w http.ResponseWriter, r *http.Request, reason string) {
+	if a.cfg.Mode == "password" && r.Method == http.MethodGet &&
+		!strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/sse" && r.Header.Get("HX-Request") == "" {
+		http.Redirect(w, r, "/signin?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+
+	slog.Debug("Unauthorized request", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", reason)
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="notifylm"`)
+		writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized: %s", reason))
+		return
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+func (a *authenticator) validAPIToken(token string) bool {
+	valid := false
+	for _, t := range a.cfg.APITokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (a *authenticator) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, n := range a.trusted {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Sessions ---
+
+func (a *authenticator) session(id string) (session, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sess, ok := a.sessions[id]
+	if !ok {
+		return session{}, false
+	}
+	if time.Now().After(sess.expires) {
+		delete(a.sessions, id)
+		return session{}, false
+	}
+	return sess, true
+}
+
+func (a *authenticator) newSession(user string) string {
+	id := randomToken()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for k, sess := range a.sessions {
+		if now.After(sess.expires) {
+			delete(a.sessions, k)
+		}
+	}
+	a.sessions[id] = session{user: user, expires: now.Add(a.ttl)}
+	return id
+}
+
+func (a *authenticator) endSession(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, id)
+}
+
+// checkPassword verifies the configured user's credentials.
+func (a *authenticator) checkPassword(user, password string) bool {
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.cfg.Username)) == 1
+	// Always run bcrypt so timing doesn't reveal whether the username matched.
+	passOK := bcrypt.CompareHashAndPassword([]byte(a.cfg.PasswordHash), []byte(password)) == nil
+	return userOK && passOK
+}
+
+// preSession returns the browser's sign-in cookie, setting a new one if it
+// has none, so the sign-in form's CSRF token differs per browser. Without it
+// every visitor would share one token, and another site could sign the user
+// in to an account of its choosing.
+func (a *authenticator) preSession(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(signInCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	value := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     signInCookie,
+		Value:    value,
+		Path:     "/signin",
+		MaxAge:   int(time.Hour.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return value
+}
+
+// --- CSRF ---
+
+// csrfToken returns the token forms and HTMX requests must send back. It is
+// bound to the session (or user, or sign-in cookie) so it can't be replayed
+// across them.
+func (a *authenticator) csrfToken(r *http.Request) string {
+	id, _ := r.Context().Value(identityKey{}).(identity)
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(id.sessionID + "\x00" + id.user + "\x00" + id.signIn))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *authenticator) validCSRF(r *http.Request, id identity) bool {
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.FormValue(csrfField)
+	}
+	want := a.csrfToken(r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	return token != "" && hmac.Equal([]byte(token), []byte(want))
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// --- Sign-in handlers ---
+
+// SignInData is passed to the sign-in page.
+type SignInData struct {
+	Next      string
+	Error     string
+	CSRFToken string
+}
+
+func (s *Server) handleSignInPage(w http.ResponseWriter, r *http.Request) {
+	s.renderSignIn(w, r, "")
+}
+
+func (s *Server) renderSignIn(w http.ResponseWriter, r *http.Request, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if errMsg != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	data := SignInData{
+		Next:      safeNext(r.FormValue("next")),
+		Error:     errMsg,
+		CSRFToken: s.auth.csrfToken(r),
+	}
+	if err := s.signInTmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render sign-in page", "error", err)
+	}
+}
+
+func (s *Server) handleSignIn(w http.ResponseWriter, r *http.Request) {
+	if s.auth.cfg.Mode != "password" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	id, _ := r.Context().Value(identityKey{}).(identity)
+	if id.signIn == "" || !s.auth.validCSRF(r, id) {
+		http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+		return
+	}
+
+	user := r.FormValue("username")
+	if !s.auth.checkPassword(user, r.FormValue("password")) {
+		slog.Warn("Failed dashboard sign-in", "user", user, "remote", r.RemoteAddr)
+		s.renderSignIn(w, r, "Incorrect username or password.")
+		return
+	}
+
+	// The sign-in cookie has done its job
+	http.SetCookie(w, &http.Cookie{
+		Name:     signInCookie,
+		Value:    "",
+		Path:     "/signin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.auth.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.auth.newSession(user),
+		Path:     "/",
+		MaxAge:   int(s.auth.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   s.auth.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.Info("Dashboard sign-in", "user", user, "remote", r.RemoteAddr)
+	http.Redirect(w, r, safeNext(r.FormValue("next")), http.StatusSeeOther)
+}
+
+func (s *Server) handleSignOut(w http.ResponseWriter, r *http.Request) {
+	if id, _ := r.Context().Value(identityKey{}).(identity); id.sessionID != "" {
+		s.auth.endSession(id.sessionID)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.auth.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/signin", http.StatusSeeOther)
+}
+
+// safeNext only allows redirects to local paths after sign-in.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/store"
+)
+
+func TestSafeNext(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/", "/"},
+		{"/messages/42?tab=trace", "/messages/42?tab=trace"},
+		{"", "/"},
+		{"https://evil.example", "/"},
+		{"//evil.example", "/"},
+		{"/\\evil.example", "/"},
+		{"evil.example", "/"},
+		{"javascript:alert(1)", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeNext(tt.next); got != tt.want {
+			t.Errorf("safeNext(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAuthenticator(config.AuthConfig{Mode: "password", Username: "admin", PasswordHash: string(hash)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user, password string
+		want           bool
+	}{
+		{"admin", "s3cret", true},
+		{"admin", "wrong", false},
+		{"admin", "", false},
+		{"Admin", "s3cret", false},
+		{"other", "s3cret", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := a.checkPassword(tt.user, tt.password); got != tt.want {
+			t.Errorf("checkPassword(%q, %q) = %v, want %v", tt.user, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		want    bool
+		wantErr bool
+	}{
+		{name: "single IPv4", proxies: []string{"10.0.0.5"}, remote: "10.0.0.5:4000", want: true},
+		{name: "other IPv4", proxies: []string{"10.0.0.5"}, remote: "10.0.0.6:4000", want: false},
+		{name: "IPv4 range", proxies: []string{"172.16.0.0/12"}, remote: "172.20.1.1:4000", want: true},
+		{name: "outside range", proxies: []string{"172.16.0.0/12"}, remote: "172.32.0.1:4000", want: false},
+		{name: "single IPv6", proxies: []string{"::1"}, remote: "[::1]:4000", want: true},
+		{name: "IPv6 range", proxies: []string{"fd00::/8"}, remote: "[fd12::3]:4000", want: true},
+		{name: "second entry", proxies: []string{"10.0.0.5", "127.0.0.1"}, remote: "127.0.0.1:4000", want: true},
+		{name: "no port", proxies: []string{"10.0.0.5"}, remote: "10.0.0.5", want: false},
+		{name: "invalid address", proxies: []string{"10.0.0.300"}, wantErr: true},
+		{name: "invalid range", proxies: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "hostname", proxies: []string{"proxy.local"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAuthenticator(config.AuthConfig{Mode: "proxy", TrustedProxies: tt.proxies}, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("newAuthenticator accepted %q", tt.proxies)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if got := a.fromTrustedProxy(r); got != tt.want {
+				t.Errorf("fromTrustedProxy(%q) = %v, want %v", tt.remote, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	a, err := newAuthenticator(config.AuthConfig{Mode: "password"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenFor := func(id identity) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		return a.csrfToken(r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	}
+	alice := identity{user: "alice", sessionID: "s1"}
+	token := tokenFor(alice)
+
+	tests := []struct {
+		name   string
+		id     identity
+		header string
+		form   string
+		want   bool
+	}{
+		{name: "header", id: alice, header: token, want: true},
+		{name: "form field", id: alice, form: token, want: true},
+		{name: "missing", id: alice, want: false},
+		{name: "wrong token", id: alice, header: strings.Repeat("0", len(token)), want: false},
+		{name: "other session", id: identity{user: "alice", sessionID: "s2"}, header: token, want: false},
+		{name: "other user", id: identity{user: "bob", sessionID: "s1"}, header: token, want: false},
+		{name: "sign-in page", id: identity{signIn: "s1"}, header: token, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := url.Values{}
+			if tt.form != "" {
+				body.Set(csrfField, tt.form)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.header != "" {
+				r.Header.Set(csrfHeader, tt.header)
+			}
+			if got := a.validCSRF(r, tt.id); got != tt.want {
+				t.Errorf("validCSRF = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(config.ServerConfig{Auth: config.AuthConfig{
+		Mode:         "password",
+		Username:     "admin",
+		PasswordHash: string(hash),
+	}}, store.NewStore(10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Each browser gets its own sign-in cookie and token
+	page := func() (cookie *http.Cookie, token string) {
+		w := serve(httptest.NewRequest(http.MethodGet, "/signin", nil))
+		for _, c := range w.Result().Cookies() {
+			if c.Name == signInCookie {
+				cookie = c
+			}
+		}
+		m := regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`).FindStringSubmatch(w.Body.String())
+		if cookie == nil || m == nil {
+			t.Fatalf("sign-in page lacks a cookie or token:\n%s", w.Body.String())
+		}
+		return cookie, m[1]
+	}
+	cookie, token := page()
+	other, otherToken := page()
+	if cookie.Value == other.Value || token == otherToken {
+		t.Fatal("two browsers got the same sign-in cookie or token")
+	}
+
+	tests := []struct {
+		name     string
+		cookie   *http.Cookie
+		token    string
+		password string
+		next     string
+		wantCode int
+		wantNext string
+	}{
+		{name: "success", cookie: cookie, token: token, password: "s3cret", next: "/messages/1", wantCode: http.StatusSeeOther, wantNext: "/messages/1"},
+		{name: "offsite next", cookie: cookie, token: token, password: "s3cret", next: "//evil.example", wantCode: http.StatusSeeOther, wantNext: "/"},
+		{name: "wrong password", cookie: cookie, token: token, password: "nope", wantCode: http.StatusUnauthorized},
+		{name: "no token", cookie: cookie, password: "s3cret", wantCode: http.StatusForbidden},
+		{name: "no cookie", token: token, password: "s3cret", wantCode: http.StatusForbidden},
+		{name: "another browser's token", cookie: cookie, token: otherToken, password: "s3cret", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"username": {"admin"}, "password": {tt.password}, "next": {tt.next}}
+			if tt.token != "" {
+				form.Set(csrfField, tt.token)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/signin", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := serve(r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantNext == "" {
+				return
+			}
+			if got := w.Header().Get("Location"); got != tt.wantNext {
+				t.Errorf("redirected to %q, want %q", got, tt.wantNext)
+			}
+			signedIn := false
+			for _, c := range w.Result().Cookies() {
+				signedIn = signedIn || (c.Name == sessionCookie && c.Value != "")
+			}
+			if !signedIn {
+				t.Error("no session cookie was set")
+			}
+		})
+	}
+}
@@ -3,42 +3,121 @@ package server
 import (
 	"context"
 	"embed"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"rsc.io/qr"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+	"github.com/emirlan/notifylm/internal/contacts"
+	"github.com/emirlan/notifylm/internal/feedback"
+	"github.com/emirlan/notifylm/internal/login"
 	"github.com/emirlan/notifylm/internal/message"
 	"github.com/emirlan/notifylm/internal/store"
 )
 
-//go:embed templates/dashboard.html
+//go:embed templates/dashboard.html templates/login.html templates/signin.html templates/message.html templates/contacts.html
 var templateFS embed.FS
 
 // DashboardData holds all data passed to the main dashboard template.
 type DashboardData struct {
-	Messages      []store.ProcessedMessage
-	Listeners     []store.ListenerStatus
-	Stats         store.Stats
-	ActionItems   []store.ActionItemWithContext
-	Notifications []store.Notification
-	Uptime        string
+	Messages        []store.ProcessedMessage
+	Listeners       []store.ListenerStatus
+	Stats           store.Stats
+	ActionItems     []store.ActionItemWithContext
+	Notifications   []store.Notification
+	Logins          []login.Request
+	Uptime          string
+	User            string // signed-in user, empty when auth is disabled
+	FeedbackEnabled bool
+	ContactsEnabled bool
+	CanSignOut      bool
+	CSRFToken       string
+	Usage           UsageData
+}
+
+// UsageData is passed to the LLM usage partial.
+type UsageData struct {
+	store.UsageReport
+	Budget config.BudgetConfig
+}
+
+// MessagesData is passed to the message feed partial.
+type MessagesData struct {
+	Messages        []store.ProcessedMessage
+	Filtered        bool
+	Error           string
+	FeedbackEnabled bool
+}
+
+// MessageDetailData is passed to the message detail page.
+type MessageDetailData struct {
+	store.ProcessedMessage
+	ParsedJSON      string // the classification as returned by the JSON API
+	FeedbackEnabled bool
+	CSRFToken       string
+}
+
+// ContactsData is passed to the contacts editor.
+type ContactsData struct {
+	Enabled   bool
+	Contacts  []contacts.Contact
+	YAML      string // the editor's contents
+	Path      string
+	Error     string
+	Saved     bool
+	CSRFToken string
+}
+
+// LoginData is passed to the login page and its HTMX partial.
+type LoginData struct {
+	Listener  string
+	Request   login.Request
+	Pending   bool
+	CSRFToken string
 }
 
 // Server serves the HTMX dashboard and provides API endpoints for live updates.
 type Server struct {
 	store     *store.Store
+	login     *login.Broker     // nil if interactive login is handled on the terminal
+	feedback  *feedback.Store   // nil if feedback is disabled
+	contacts  *contacts.Book    // nil if contacts are disabled
+	cache     *classifier.Cache // nil if the classification cache is disabled
+	budget    config.BudgetConfig
+	auth      *authenticator
 	srv       *http.Server
+	cfg       config.ServerConfig
 	tmpl      *template.Template
+	loginTmpl *template.Template
 	startedAt time.Time
 
+	signInTmpl   *template.Template
+	detailTmpl   *template.Template
+	contactsTmpl *template.Template
+
 	// HTMX partial templates
 	messagesTmpl      *template.Template
 	statsTmpl         *template.Template
 	listenersTmpl     *template.Template
 	actionsTmpl       *template.Template
+	usageTmpl         *template.Template
 	notificationsTmpl *template.Template
+	loginPanelTmpl    *template.Template
+	loginsTmpl        *template.Template
+
+	mux *http.ServeMux
 }
 
 // Template helper functions.
@@ -47,17 +126,33 @@ var funcMap = template.FuncMap{
 	"truncateText": truncateText,
 	"sourceIcon":   sourceIcon,
 	"sourceColor":  sourceColor,
+	"appLink":      appLink,
+	"webLink":      webLink,
+	"qrImage":      qrImage,
+	"hasLabel":     hasLabel,
+	"usd":          usd,
 }
 
-// New creates a new Server with the given store and port.
-// If port is 0, it defaults to 8080.
-func New(st *store.Store, port int) *Server {
-	if port == 0 {
-		port = 8080
+// New creates a new Server for the given store. The port defaults to 8080
+// and the bind address to 127.0.0.1. broker may be nil.
+func New(cfg config.ServerConfig, st *store.Store, broker *login.Broker) (*Server, error) {
+	if cfg.Port == 0 {
+		cfg.Port = 8080
+	}
+	if cfg.BindAddress == "" {
+		cfg.BindAddress = "127.0.0.1"
+	}
+
+	auth, err := newAuthenticator(cfg.Auth, cfg.TLSEnabled())
+	if err != nil {
+		return nil, err
 	}
 
 	s := &Server{
 		store:     st,
+		login:     broker,
+		auth:      auth,
+		cfg:       cfg,
 		startedAt: time.Now(),
 	}
 
@@ -71,33 +166,89 @@ func New(st *store.Store, port int) *Server {
 	s.statsTmpl = template.Must(template.New("stats").Funcs(funcMap).Parse(statsPartial))
 	s.listenersTmpl = template.Must(template.New("listeners").Funcs(funcMap).Parse(listenersPartial))
 	s.actionsTmpl = template.Must(template.New("actions").Funcs(funcMap).Parse(actionsPartial))
+	s.usageTmpl = template.Must(template.New("usage").Funcs(funcMap).Parse(usagePartial))
 	s.notificationsTmpl = template.Must(template.New("notifications").Funcs(funcMap).Parse(notificationsPartial))
+	s.loginPanelTmpl = template.Must(template.New("login-panel").Funcs(funcMap).Parse(loginPanelPartial))
+	s.loginsTmpl = template.Must(template.New("logins").Funcs(funcMap).Parse(loginsPartial))
+	s.loginTmpl = template.Must(
+		template.Must(s.loginPanelTmpl.Clone()).New("login.html").ParseFS(templateFS, "templates/login.html"),
+	)
+	s.signInTmpl = template.Must(template.New("signin.html").ParseFS(templateFS, "templates/signin.html"))
+	s.detailTmpl = template.Must(template.New("message.html").Funcs(funcMap).ParseFS(templateFS, "templates/message.html"))
+	s.contactsTmpl = template.Must(template.New("contacts.html").ParseFS(templateFS, "templates/contacts.html"))
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /", s.handleDashboard)
 	mux.HandleFunc("GET /api/messages", s.handleMessages)
+	mux.HandleFunc("GET /messages/{seq}", s.handleMessageDetail)
+	mux.HandleFunc("POST /messages/{seq}/feedback", s.handleFeedback)
+	mux.HandleFunc("GET /contacts", s.handleContactsPage)
+	mux.HandleFunc("POST /contacts", s.handleContactsSave)
 	mux.HandleFunc("GET /api/stats", s.handleStats)
 	mux.HandleFunc("GET /api/listeners", s.handleListeners)
 	mux.HandleFunc("GET /api/actions", s.handleActions)
+	mux.HandleFunc("GET /api/usage", s.handleUsage)
 	mux.HandleFunc("GET /api/notifications", s.handleNotifications)
 	mux.HandleFunc("GET /sse", s.handleSSE)
+	mux.HandleFunc("GET /login/{listener}", s.handleLoginPage)
+	mux.HandleFunc("POST /login/{listener}", s.handleLoginSubmit)
+	mux.HandleFunc("GET /api/login/{listener}", s.handleLoginPanel)
+	mux.HandleFunc("GET /api/logins", s.handleLogins)
+	mux.HandleFunc("GET /signin", s.handleSignInPage)
+	mux.HandleFunc("POST /signin", s.handleSignIn)
+	mux.HandleFunc("POST /signout", s.handleSignOut)
+	s.registerAPI(mux)
+	s.mux = mux
 
 	s.srv = &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      mux,
+		Addr:         net.JoinHostPort(cfg.BindAddress, strconv.Itoa(cfg.Port)),
+		Handler:      auth.middleware(mux),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	return s
+	return s, nil
+}
+
+// Handle mounts an additional handler, such as the Google OAuth callback.
+// It must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// UseFeedback enables the correction buttons, recording them in fb.
+func (s *Server) UseFeedback(fb *feedback.Store) {
+	s.feedback = fb
+}
+
+// UseContacts enables the contacts editor for book.
+func (s *Server) UseContacts(book *contacts.Book) {
+	s.contacts = book
+}
+
+// UseCache makes corrections clear cached classifications for the sender, so
+// the same mistake isn't repeated from the cache.
+func (s *Server) UseCache(cache *classifier.Cache) {
+	s.cache = cache
+}
+
+// UseBudget shows the LLM budget alongside usage on the dashboard.
+func (s *Server) UseBudget(budget config.BudgetConfig) {
+	s.budget = budget
 }
 
 // Start starts the HTTP server in a background goroutine.
 func (s *Server) Start() error {
-	slog.Info("Starting dashboard server", "addr", s.srv.Addr)
+	slog.Info("Starting dashboard server", "addr", s.srv.Addr, "tls", s.cfg.TLSEnabled(), "auth", s.auth.cfg.Mode)
 	go func() {
-		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.cfg.TLSEnabled() {
+			err = s.srv.ListenAndServeTLS(s.cfg.TLSCertPath, s.cfg.TLSKeyPath)
+		} else {
+			err = s.srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			slog.Error("Dashboard server error", "error", err)
 		}
 	}()
@@ -119,12 +270,21 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := DashboardData{
-		Messages:      s.store.GetRecentMessages(50),
-		Listeners:     s.store.GetListenerStatuses(),
-		Stats:         s.store.GetStats(),
-		ActionItems:   s.store.GetActionItems(20),
-		Notifications: s.store.GetRecentNotifications(20),
-		Uptime:        timeAgo(s.startedAt),
+		Messages:        s.store.GetRecentMessages(50),
+		Listeners:       s.store.GetListenerStatuses(),
+		Stats:           s.store.GetStats(),
+		ActionItems:     s.store.GetActionItems(20),
+		Notifications:   s.store.GetRecentNotifications(20),
+		Logins:          s.pendingLogins(),
+		Uptime:          timeAgo(s.startedAt),
+		FeedbackEnabled: s.feedback != nil,
+		ContactsEnabled: s.contacts != nil,
+		CanSignOut:      s.auth.cfg.Mode == "password",
+		CSRFToken:       s.auth.csrfToken(r),
+		Usage:           s.usage(),
+	}
+	if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+		data.User = id.user
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -135,14 +295,158 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
-	messages := s.store.GetRecentMessages(50)
+	data := MessagesData{FeedbackEnabled: s.feedback != nil}
+	q, err := parseMessageFilters(r.URL.Query())
+	if err != nil {
+		data.Error = err.Error()
+	} else {
+		// The date picker's "to" day is inclusive.
+		if len(r.URL.Query().Get("until")) == len(time.DateOnly) {
+			q.Until = q.Until.AddDate(0, 0, 1)
+		}
+		q.Limit = 50
+		data.Messages = s.store.QueryMessages(q)
+		data.Filtered = q != store.MessageQuery{Limit: 50}
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.messagesTmpl.Execute(w, messages); err != nil {
+	if err := s.messagesTmpl.Execute(w, data); err != nil {
 		slog.Error("Failed to render messages partial", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
+func (s *Server) handleMessageDetail(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.ParseUint(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	pm, ok := s.store.GetMessage(seq)
+	if !ok {
+		http.Error(w, "Message not found; it may have left the buffer.", http.StatusNotFound)
+		return
+	}
+
+	data := MessageDetailData{
+		ProcessedMessage: pm,
+		FeedbackEnabled:  s.feedback != nil,
+		CSRFToken:        s.auth.csrfToken(r),
+	}
+	if pm.Classification != nil {
+		parsed, _ := json.MarshalIndent(toAPIClassification(pm.Classification), "", "  ")
+		data.ParsedJSON = string(parsed)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.detailTmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render message detail", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleFeedback records a correction from the feed (HTMX) or the detail page.
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.ParseUint(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, status, err := s.recordFeedback(seq, r.FormValue("label"), r.FormValue("action_item"), r.FormValue("note")); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<span class="tag action">thanks</span>`)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/messages/%d", seq), http.StatusSeeOther)
+}
+
+// recordFeedback persists a correction for a buffered message, returning the
+// HTTP status to use on failure.
+func (s *Server) recordFeedback(seq uint64, label, actionItem, note string) (feedback.Correction, int, error) {
+	if s.feedback == nil {
+		return feedback.Correction{}, http.StatusNotFound, fmt.Errorf("feedback is disabled")
+	}
+	pm, ok := s.store.GetMessage(seq)
+	if !ok {
+		return feedback.Correction{}, http.StatusNotFound, fmt.Errorf("message %d not found", seq)
+	}
+
+	actionItem = strings.TrimSpace(actionItem)
+	if !feedback.ValidLabel(label) {
+		return feedback.Correction{}, http.StatusBadRequest, fmt.Errorf("unknown label %q", label)
+	}
+	if label == feedback.LabelWrongActionItem && actionItem == "" {
+		return feedback.Correction{}, http.StatusBadRequest, fmt.Errorf("action_item is required for %s", label)
+	}
+
+	c := feedback.NewCorrection(pm.Message, label, actionItem, strings.TrimSpace(note))
+	if err := s.feedback.Add(c); err != nil {
+		slog.Error("Failed to record feedback", "seq", seq, "error", err)
+		return c, http.StatusInternalServerError, err
+	}
+
+	stored := label
+	if label == feedback.LabelWrongActionItem {
+		stored += ":" + c.ActionItem
+	}
+	s.store.AddFeedback(seq, stored)
+	if s.cache != nil {
+		s.cache.Forget(pm.Message)
+	}
+	slog.Info("Classification feedback recorded", "seq", seq, "label", label, "source", pm.Message.Source, "sender", pm.Message.Sender)
+	return c, http.StatusOK, nil
+}
+
+func (s *Server) handleContactsPage(w http.ResponseWriter, r *http.Request) {
+	data := ContactsData{Enabled: s.contacts != nil, Saved: r.URL.Query().Get("saved") != ""}
+	if s.contacts != nil {
+		data.Contacts = s.contacts.All()
+		data.YAML = s.contacts.YAML()
+	}
+	s.renderContacts(w, r, http.StatusOK, data)
+}
+
+// handleContactsSave replaces the address book with the editor's YAML.
+func (s *Server) handleContactsSave(w http.ResponseWriter, r *http.Request) {
+	if s.contacts == nil {
+		http.Error(w, "Contacts are disabled", http.StatusNotFound)
+		return
+	}
+
+	yamlText := r.FormValue("yaml")
+	list, err := contacts.Parse([]byte(yamlText))
+	if err == nil {
+		err = s.contacts.Replace(list)
+	}
+	if err != nil {
+		s.renderContacts(w, r, http.StatusBadRequest, ContactsData{
+			Enabled:  true,
+			Contacts: s.contacts.All(),
+			YAML:     yamlText,
+			Error:    err.Error(),
+		})
+		return
+	}
+	http.Redirect(w, r, "/contacts?saved=1", http.StatusSeeOther)
+}
+
+func (s *Server) renderContacts(w http.ResponseWriter, r *http.Request, status int, data ContactsData) {
+	data.CSRFToken = s.auth.csrfToken(r)
+	if s.contacts != nil {
+		data.Path = s.contacts.Path()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := s.contactsTmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render contacts page", "error", err)
+	}
+}
+
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	stats := s.store.GetStats()
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -170,6 +474,18 @@ func (s *Server) handleActions(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.usageTmpl.Execute(w, s.usage()); err != nil {
+		slog.Error("Failed to render usage partial", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) usage() UsageData {
+	return UsageData{UsageReport: s.store.GetUsage(time.Now()), Budget: s.budget}
+}
+
 func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
 	notifications := s.store.GetRecentNotifications(20)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -179,6 +495,72 @@ func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) loginData(r *http.Request) LoginData {
+	data := LoginData{
+		Listener:  r.PathValue("listener"),
+		CSRFToken: s.auth.csrfToken(r),
+	}
+	if s.login != nil {
+		data.Request, data.Pending = s.login.Get(data.Listener)
+	}
+	return data
+}
+
+// pendingLogins returns the logins waiting for the user.
+func (s *Server) pendingLogins() []login.Request {
+	if s.login == nil {
+		return nil
+	}
+	return s.login.Pending()
+}
+
+func (s *Server) handleLogins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.loginsTmpl.Execute(w, s.pendingLogins()); err != nil {
+		slog.Error("Failed to render logins partial", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.loginTmpl.ExecuteTemplate(w, "login.html", s.loginData(r)); err != nil {
+		slog.Error("Failed to render login page", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleLoginPanel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.loginPanelTmpl.Execute(w, s.loginData(r)); err != nil {
+		slog.Error("Failed to render login partial", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleLoginSubmit(w http.ResponseWriter, r *http.Request) {
+	listener := r.PathValue("listener")
+	if s.login == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	value := strings.TrimSpace(r.FormValue("value"))
+	if value == "" {
+		http.Error(w, "Missing value", http.StatusBadRequest)
+		return
+	}
+	if err := s.login.Submit(listener, value); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	slog.Info("Login input submitted from dashboard", "listener", listener)
+	http.Redirect(w, r, "/login/"+url.PathEscape(listener), http.StatusSeeOther)
+}
+
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -246,6 +628,15 @@ func timeAgo(v any) string {
 	}
 }
 
+// usd formats an amount in US dollars, with more precision for the small
+// amounts a single day of cheap models adds up to.
+func usd(v float64) string {
+	if v < 1 {
+		return fmt.Sprintf("$%.4f", v)
+	}
+	return fmt.Sprintf("$%.2f", v)
+}
+
 // truncateText truncates a string to max characters and appends "..." if truncated.
 func truncateText(s string, max int) string {
 	runes := []rune(s)
@@ -271,6 +662,52 @@ func sourceIcon(s message.Source) string {
 	}
 }
 
+// linkSchemes lists the URL schemes the dashboard will render as links.
+var linkSchemes = []string{"https://", "slack://", "tg://", "whatsapp://"}
+
+// appLink returns the message's deep link (or web fallback) marked safe for
+// use in an href, since html/template rejects non-http schemes by default.
+func appLink(msg *message.Message) template.URL {
+	return safeLink(msg.AppLink())
+}
+
+// webLink returns the message's https permalink, if it differs from its app link.
+func webLink(msg *message.Message) template.URL {
+	if web := msg.WebLink(); web != msg.AppLink() {
+		return safeLink(web)
+	}
+	return ""
+}
+
+func safeLink(link string) template.URL {
+	for _, scheme := range linkSchemes {
+		if strings.HasPrefix(link, scheme) {
+			return template.URL(link)
+		}
+	}
+	return ""
+}
+
+// hasLabel reports whether the user already gave one of the correction labels.
+func hasLabel(given []string, labels ...string) bool {
+	for _, l := range labels {
+		if slices.Contains(given, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// qrImage renders a QR code payload as an inline PNG data URL.
+func qrImage(payload string) template.URL {
+	code, err := qr.Encode(payload, qr.L)
+	if err != nil {
+		slog.Error("Failed to encode QR code", "error", err)
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()))
+}
+
 // sourceColor returns a CSS class name for the given message source.
 func sourceColor(s message.Source) string {
 	switch s {
@@ -289,25 +726,30 @@ func sourceColor(s message.Source) string {
 
 // --- HTMX Partial Templates (match dashboard.html CSS classes) ---
 
-const messagesPartial = `{{range .}}
+const messagesPartial = `{{with .Error}}<div class="filter-error">{{.}}</div>{{end}}{{range .Messages}}
 <div class="message-item{{if .Classification}}{{if .Classification.IsUrgent}} urgent{{end}}{{end}}">
   <div class="message-header">
     <span class="source-badge {{sourceColor .Message.Source}}">{{sourceIcon .Message.Source}} {{.Message.Source}}</span>
     <span class="sender">{{.Message.Sender}}</span>
+    {{with index .Message.Metadata "account"}}<span class="account">{{.}}</span>{{end}}
     <span class="timestamp">{{timeAgo .Message.Timestamp}}</span>
   </div>
   <div class="message-body">{{truncateText .Message.Text 120}}</div>
   <div class="message-tags">
     {{if .Classification}}{{if .Classification.IsUrgent}}<span class="tag urgent">urgent</span>{{end}}{{end}}
     {{if .Classification}}{{if .Classification.ActionItems}}<span class="tag action">{{len .Classification.ActionItems}} action{{if gt (len .Classification.ActionItems) 1}}s{{end}}</span>{{end}}{{end}}
+    {{with appLink .Message}}<a class="tag link" href="{{.}}">Open in app</a>{{end}}
+    {{with webLink .Message}}<a class="tag link" href="{{.}}" target="_blank" rel="noopener">web</a>{{end}}
+    <a class="tag link" href="/messages/{{.Seq}}">details</a>
+    {{if and $.FeedbackEnabled .Classification}}{{if or (hasLabel .Feedback "urgent") (hasLabel .Feedback "not_urgent")}}<span class="tag action">corrected</span>{{else if .Classification.IsUrgent}}<button class="tag link feedback" hx-post="/messages/{{.Seq}}/feedback" hx-vals='{"label": "not_urgent"}' hx-swap="outerHTML">not urgent?</button>{{else}}<button class="tag link feedback" hx-post="/messages/{{.Seq}}/feedback" hx-vals='{"label": "urgent"}' hx-swap="outerHTML">urgent?</button>{{end}}{{end}}
   </div>
 </div>
-{{else}}
+{{else}}{{if not .Error}}
 <div class="empty-state">
   <div class="empty-state-icon">&#x1f4e1;</div>
-  <div class="empty-state-text">Waiting for messages...</div>
+  <div class="empty-state-text">{{if .Filtered}}No matching messages{{else}}Waiting for messages...{{end}}</div>
 </div>
-{{end}}`
+{{end}}{{end}}`
 
 const statsPartial = `<div class="stat-card">
   <div class="stat-value">{{.TotalMessages}}</div>
@@ -333,15 +775,43 @@ const statsPartial = `<div class="stat-card">
 <div class="stat-card">
   <div class="stat-value">{{.EventsCreated}}</div>
   <div class="stat-label">Events</div>
+</div>
+<div class="stat-card">
+  <div class="stat-value">{{.CacheHitRate}}%</div>
+  <div class="stat-label">Cache Hits</div>
+  <div class="source-breakdown">
+    <span class="source-mini">{{.CacheHits}} identical</span>
+    <span class="source-mini">{{.SimilarHits}} similar</span>
+  </div>
+</div>`
+
+const usagePartial = `<div class="listener-item">
+  <div class="listener-info">
+    <div class="listener-name">Today</div>
+    <div class="listener-meta">{{.Today.Tokens}} tokens &middot; {{.Today.Calls}} calls{{if .Budget.DailyUSD}} &middot; of {{usd .Budget.DailyUSD}}{{end}}{{if .Budget.DailyTokens}} &middot; of {{.Budget.DailyTokens}} tokens{{end}}</div>
+  </div>
+  <div class="listener-count">{{usd .Today.Cost}}</div>
+</div>
+<div class="listener-item">
+  <div class="listener-info">
+    <div class="listener-name">This month</div>
+    <div class="listener-meta">{{.Month.Tokens}} tokens &middot; {{.Month.Calls}} calls{{if .Budget.MonthlyUSD}} &middot; of {{usd .Budget.MonthlyUSD}}{{end}}{{if .Budget.MonthlyTokens}} &middot; of {{.Budget.MonthlyTokens}} tokens{{end}}</div>
+    <div class="source-breakdown">
+      {{range $source, $u := .MonthBySource}}
+      <span class="source-mini"><span class="dot {{$source}}"></span>{{usd $u.Cost}}</span>
+      {{end}}
+    </div>
+  </div>
+  <div class="listener-count">{{usd .Month.Cost}}</div>
 </div>`
 
 const listenersPartial = `{{range .}}
 <div class="listener-item">
-  <span class="status-dot {{if .Connected}}connected{{else}}disconnected{{end}}"></span>
+  <span class="status-dot {{if .AwaitingLogin}}awaiting{{else if .Connected}}connected{{else}}disconnected{{end}}"></span>
   <div class="listener-info">
     <div class="listener-name">{{.Name}}</div>
     <div class="listener-meta">
-      {{if .LastMessage}}Last: {{timeAgo .LastMessage}}{{else}}No messages yet{{end}}
+      {{if .AwaitingLogin}}<a class="login-link" href="/login/{{.Name}}">Awaiting login &rarr;</a>{{else if .LastMessage}}Last: {{timeAgo .LastMessage}}{{else}}No messages yet{{end}}
     </div>
   </div>
   <div class="listener-count">{{.MessageCount}}</div>
@@ -357,14 +827,14 @@ const actionsPartial = `{{range .}}
 <div class="action-item">
   <div class="action-header">
     <span class="action-title">{{.Item.Title}}</span>
-    <span class="action-check {{if .EventCreated}}created{{else}}pending{{end}}">
-      {{if .EventCreated}}&#x2713;{{else}}&#x2026;{{end}}
+    <span class="action-check {{if .Saved}}created{{else}}pending{{end}}">
+      {{if .Saved}}&#x2713;{{else}}&#x2026;{{end}}
     </span>
   </div>
   {{if .Item.Description}}<div class="action-description">{{truncateText .Item.Description 80}}</div>{{end}}
   <div class="action-meta">
-    {{if not .Item.DateTime.IsZero}}<span>&#x1f4c5; {{.Item.DateTime.Format "Jan 2, 15:04"}}</span>{{end}}
-    {{if gt .Item.DurationMinutes 0}}<span>&#x23f1; {{.Item.DurationMinutes}}min</span>{{end}}
+    {{if .Item.DateTime.IsZero}}<span>&#x1f4dd; task</span>{{else}}<span>&#x1f4c5; {{if .Item.AllDay}}{{.Item.DateTime.Format "Jan 2"}}, all day{{else}}{{.Item.DateTime.Format "Jan 2, 15:04"}}{{end}}</span>{{end}}
+    {{if and (gt .Item.DurationMinutes 0) (not .Item.AllDay) (not .Item.DateTime.IsZero)}}<span>&#x23f1; {{.Item.DurationMinutes}}min</span>{{end}}
     <span>via {{.SourceMsg.Sender}}</span>
   </div>
 </div>
@@ -390,3 +860,39 @@ const notificationsPartial = `{{range .}}
   <div class="empty-state-text">No notifications sent yet</div>
 </div>
 {{end}}`
+
+// loginPanelPartial polls while there is nothing to type (a QR code that
+// rotates, a submitted value being checked) and stays put while showing a form.
+const loginPanelPartial = `{{if not .Pending}}
+<div class="login-panel">
+  <p class="login-prompt">No login is pending for <strong>{{.Listener}}</strong>.</p>
+  <p><a class="login-link" href="/">Back to dashboard</a></p>
+</div>
+{{else if eq .Request.Kind "qr"}}
+<div class="login-panel" hx-get="/api/login/{{.Listener}}" hx-trigger="every 2s" hx-swap="outerHTML">
+  <p class="login-prompt">{{.Request.Prompt}}</p>
+  {{with qrImage .Request.QRCode}}<img class="login-qr" src="{{.}}" alt="Login QR code">{{end}}
+  <p class="login-hint">The code refreshes automatically.</p>
+</div>
+{{else if eq .Request.Kind "link"}}
+<div class="login-panel" hx-get="/api/login/{{.Listener}}" hx-trigger="every 2s" hx-swap="outerHTML">
+  <p class="login-prompt">{{.Request.Prompt}}</p>
+  <p><a class="login-button" href="{{.Request.URL}}">{{.Request.LinkLabel}}</a></p>
+</div>
+{{else if .Request.Submitted}}
+<div class="login-panel" hx-get="/api/login/{{.Listener}}" hx-trigger="every 2s" hx-swap="outerHTML">
+  <p class="login-prompt">Checking&hellip;</p>
+</div>
+{{else}}
+<div class="login-panel">
+  <p class="login-prompt">{{.Request.Prompt}}</p>
+  {{with .Request.Error}}<p class="login-error">{{.}}</p>{{end}}
+  <form method="post" action="/login/{{.Listener}}">
+    <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+    <input class="login-input" name="value" {{if eq .Request.Kind "password"}}type="password" autocomplete="current-password"{{else}}type="text" inputmode="numeric" autocomplete="one-time-code"{{end}} required autofocus>
+    <button class="login-button" type="submit">Continue</button>
+  </form>
+</div>
+{{end}}`
+
+const loginsPartial = `{{range .}}<a class="login-link" href="/login/{{.Listener}}">{{if eq .Kind "link"}}{{.LinkLabel}}{{else}}Sign in{{end}} &middot; {{.Listener}}</a>{{end}}`
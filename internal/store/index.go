@@ -0,0 +1,86 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// textIndex is an inverted index over the sender and text of buffered
+// messages, keyed by Seq. It is maintained under Store.mu.
+type textIndex struct {
+	postings map[string]map[uint64]struct{} // token -> message seqs
+	tokens   map[uint64][]string            // seq -> its tokens, for removal
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings: make(map[string]map[uint64]struct{}),
+		tokens:   make(map[uint64][]string),
+	}
+}
+
+// add indexes the given fields of the message with this seq.
+func (x *textIndex) add(seq uint64, fields ...string) {
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		for _, tok := range tokenize(f) {
+			if seen[tok] {
+				continue
+			}
+			seen[tok] = true
+
+			p, ok := x.postings[tok]
+			if !ok {
+				p = make(map[uint64]struct{})
+				x.postings[tok] = p
+			}
+			p[seq] = struct{}{}
+			x.tokens[seq] = append(x.tokens[seq], tok)
+		}
+	}
+}
+
+// remove drops the message with this seq from the index.
+func (x *textIndex) remove(seq uint64) {
+	for _, tok := range x.tokens[seq] {
+		p := x.postings[tok]
+		delete(p, seq)
+		if len(p) == 0 {
+			delete(x.postings, tok)
+		}
+	}
+	delete(x.tokens, seq)
+}
+
+// search returns the seqs of messages containing every term, each matched
+// as a prefix so "meet" finds "meeting". terms must come from tokenize.
+func (x *textIndex) search(terms []string) map[uint64]struct{} {
+	var result map[uint64]struct{}
+	for _, term := range terms {
+		hits := make(map[uint64]struct{})
+		for tok, p := range x.postings {
+			if !strings.HasPrefix(tok, term) {
+				continue
+			}
+			for seq := range p {
+				if result == nil {
+					hits[seq] = struct{}{}
+				} else if _, ok := result[seq]; ok {
+					hits[seq] = struct{}{}
+				}
+			}
+		}
+		result = hits
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// tokenize lowercases s and splits it into words of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
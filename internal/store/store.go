@@ -1,6 +1,8 @@
 package store
 
 import (
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,26 +12,66 @@ import (
 
 // ProcessedMessage holds a message along with its classification results and processing metadata.
 type ProcessedMessage struct {
+	Seq            uint64 // increasing insertion order, assigned by the store
 	Message        *message.Message
 	Classification *classifier.ClassificationResult
+	ClassifyError  string     // set when classification failed
+	Skipped        bool       // dropped by a block, rule or hook before it was acted on
 	NotifiedAt     *time.Time // nil if notification wasn't sent
 	EventsCreated  int        // number of calendar events created
+	Outcomes       []Outcome  // notifications, calendar events and tasks attempted
+	Feedback       []string   // correction labels the user gave, e.g. "not_urgent"
 	ProcessedAt    time.Time
 }
 
+// ClassifiedBy names what classified the message: the model that answered,
+// or the method ("keyword", "rule", "cache", ...) when none was called.
+func (pm ProcessedMessage) ClassifiedBy() string {
+	if pm.Classification == nil || pm.Classification.Trace == nil {
+		return ""
+	}
+	t := pm.Classification.Trace
+	if (t.Method == "llm" || t.Method == "fallback") && t.Model != "" {
+		return t.Model
+	}
+	return t.Method
+}
+
+// created reports whether an outcome of kind was created for subject.
+func (pm ProcessedMessage) created(kind, subject string) bool {
+	for _, o := range pm.Outcomes {
+		if o.Kind == kind && o.Subject == subject && o.Status == "created" {
+			return true
+		}
+	}
+	return false
+}
+
+// Outcome records what the pipeline did (or deliberately didn't do) for a
+// message, shown on its detail page.
+type Outcome struct {
+	Kind    string // "notification", "calendar", "task", "contact", "rule" or "hook"
+	Subject string // "urgent", "vip", an action item, contact, rule or hook name
+	Status  string // "sent", "created", "failed", "skipped" or "applied"
+	Detail  string // error or reason for skipping
+	At      time.Time
+}
+
 // ListenerStatus tracks the state of each message listener.
 type ListenerStatus struct {
-	Name         string
-	Source       message.Source
-	Connected    bool
-	MessageCount int
-	LastMessage  *time.Time
+	Name          string
+	Source        message.Source
+	Account       string
+	Connected     bool
+	AwaitingLogin bool // waiting for a QR scan or login code on the dashboard
+	MessageCount  int
+	LastMessage   *time.Time
 }
 
 // Notification records a sent push notification.
 type Notification struct {
 	Message *message.Message
-	Reason  string // "urgent", "action_item"
+	Reason  string // "urgent", "action_item", "vip"
 	SentAt  time.Time
 }
 
@@ -38,9 +80,15 @@ type ActionItemWithContext struct {
 	Item         classifier.ActionItem
 	SourceMsg    *message.Message
 	EventCreated bool
+	TaskCreated  bool // an undated item was saved to the task list
 	ProcessedAt  time.Time
 }
 
+// Saved reports whether the item made it onto the calendar or task list.
+func (a ActionItemWithContext) Saved() bool {
+	return a.EventCreated || a.TaskCreated
+}
+
 // Stats holds aggregate statistics.
 type Stats struct {
 	TotalMessages     int
@@ -49,6 +97,22 @@ type Stats struct {
 	NotificationsSent int
 	EventsCreated     int
 	BySource          map[message.Source]int
+
+	// How classifications were reached: by calling the LLM, or by reusing
+	// the result for an identical or similar earlier message.
+	LLMCalls    int
+	CacheHits   int
+	SimilarHits int
+}
+
+// CacheHitRate returns the percentage of LLM classifications answered from
+// the cache.
+func (s Stats) CacheHitRate() int {
+	hits := s.CacheHits + s.SimilarHits
+	if hits+s.LLMCalls == 0 {
+		return 0
+	}
+	return 100 * hits / (hits + s.LLMCalls)
 }
 
 const maxNotifications = 100
@@ -60,11 +124,15 @@ type Store struct {
 	capacity int
 	writeIdx int
 	count    int
+	nextSeq  uint64
+	index    *textIndex
 
 	listeners     map[string]*ListenerStatus // keyed by listener name
 	notifications []Notification             // capped at maxNotifications
 
-	stats Stats
+	stats     Stats
+	usage     map[string]map[message.Source]classifier.Usage // by local day, see dayKey
+	usagePath string                                         // where usage is saved, "" to keep it in memory only
 
 	// SSE subscribers
 	ssemu       sync.Mutex
@@ -80,45 +148,186 @@ func NewStore(capacity int) *Store {
 	return &Store{
 		messages:    make([]ProcessedMessage, capacity),
 		capacity:    capacity,
+		index:       newTextIndex(),
 		listeners:   make(map[string]*ListenerStatus),
 		subscribers: make(map[chan string]struct{}),
 		stats: Stats{
 			BySource: make(map[message.Source]int),
 		},
+		usage: make(map[string]map[message.Source]classifier.Usage),
 	}
 }
 
-// AddProcessedMessage adds a message to the ring buffer, updates stats, and
-// notifies SSE subscribers.
+// AddProcessedMessage adds a message to the ring buffer, updates stats and
+// LLM usage, and notifies SSE subscribers. Edited messages (Metadata["edited"] == "true")
+// replace the stored entry with the same source and ID, if it is still buffered.
 func (s *Store) AddProcessedMessage(pm ProcessedMessage) {
 	s.mu.Lock()
+	s.recordUsage(pm)
+
+	if idx, ok := s.findEdited(pm.Message); ok {
+		s.updateStats(s.messages[idx], -1)
+		pm.Seq = s.messages[idx].Seq
+		s.messages[idx] = pm
+		s.updateStats(pm, 1)
+		s.index.remove(pm.Seq)
+		s.indexMessage(pm)
+		s.mu.Unlock()
+		s.notifySubscribers("refresh")
+		return
+	}
 
-	// Write into the ring buffer.
+	// Write into the ring buffer, evicting the oldest message once full.
+	if s.count == s.capacity {
+		s.index.remove(s.messages[s.writeIdx].Seq)
+	}
+	s.nextSeq++
+	pm.Seq = s.nextSeq
 	s.messages[s.writeIdx] = pm
+	s.indexMessage(pm)
 	s.writeIdx = (s.writeIdx + 1) % s.capacity
 	if s.count < s.capacity {
 		s.count++
 	}
 
-	// Update stats.
 	s.stats.TotalMessages++
+	s.updateStats(pm, 1)
+
+	s.mu.Unlock()
+
+	s.notifySubscribers("refresh")
+}
+
+// updateStats adds (delta=1) or removes (delta=-1) a message's contribution to
+// the per-classification stats. TotalMessages is maintained by the caller.
+func (s *Store) updateStats(pm ProcessedMessage, delta int) {
 	if pm.Message != nil {
-		s.stats.BySource[pm.Message.Source]++
+		s.stats.BySource[pm.Message.Source] += delta
 	}
 	if pm.Classification != nil {
 		if pm.Classification.IsUrgent {
-			s.stats.UrgentMessages++
+			s.stats.UrgentMessages += delta
+		}
+		s.stats.TotalActionItems += delta * len(pm.Classification.ActionItems)
+		if t := pm.Classification.Trace; t != nil {
+			switch t.Method {
+			case "llm", "fallback":
+				s.stats.LLMCalls += delta
+			case classifier.MethodCache:
+				s.stats.CacheHits += delta
+			case classifier.MethodSimilar:
+				s.stats.SimilarHits += delta
+			}
 		}
-		s.stats.TotalActionItems += len(pm.Classification.ActionItems)
 	}
 	if pm.NotifiedAt != nil {
-		s.stats.NotificationsSent++
+		s.stats.NotificationsSent += delta
 	}
-	s.stats.EventsCreated += pm.EventsCreated
+	s.stats.EventsCreated += delta * pm.EventsCreated
+}
+
+// indexMessage adds a message's sender and text to the search index.
+// Must be called with s.mu held.
+func (s *Store) indexMessage(pm ProcessedMessage) {
+	if pm.Message != nil {
+		s.index.add(pm.Seq, pm.Message.Sender, pm.Message.Text)
+	}
+}
+
+// findEdited returns the ring buffer index of the message an edit replaces.
+// Must be called with s.mu held.
+func (s *Store) findEdited(msg *message.Message) (int, bool) {
+	if msg == nil || msg.ID == "" || msg.Metadata["edited"] != "true" {
+		return 0, false
+	}
+	return s.find(msg.Source, msg.ID)
+}
+
+// find returns the ring buffer index of the message with the given source and
+// ID. Must be called with s.mu held.
+func (s *Store) find(source message.Source, id string) (int, bool) {
+	for i := 0; i < s.count; i++ {
+		idx := (s.writeIdx - 1 - i + s.capacity) % s.capacity
+		m := s.messages[idx].Message
+		if m != nil && m.Source == source && m.ID == id {
+			return idx, true
+		}
+	}
+	return 0, false
+}
 
+// FindMessage returns the buffered message with the given source and ID.
+func (s *Store) FindMessage(source message.Source, id string) (ProcessedMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, ok := s.find(source, id)
+	if !ok {
+		return ProcessedMessage{}, false
+	}
+	return s.messages[idx], true
+}
+
+// GetMessage returns the buffered message with the given Seq.
+func (s *Store) GetMessage(seq uint64) (ProcessedMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := 0; i < s.count; i++ {
+		idx := (s.writeIdx - 1 - i + s.capacity) % s.capacity
+		if s.messages[idx].Seq == seq && s.messages[idx].Message != nil {
+			return s.messages[idx], true
+		}
+	}
+	return ProcessedMessage{}, false
+}
+
+// Conversation returns up to limit messages from msg's conversation that
+// arrived before it, oldest first. Earlier versions of msg itself are left
+// out, and so are messages that were skipped or never classified: a blocked
+// sender's or a dropped message's text must not reach the LLM as history.
+func (s *Store) Conversation(msg *message.Message, limit int) []*message.Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*message.Message
+	for i := 0; i < s.count && len(result) < limit; i++ {
+		idx := (s.writeIdx - 1 - i + s.capacity) % s.capacity
+		pm := s.messages[idx]
+		m := pm.Message
+		if m == nil || pm.Skipped || pm.Classification == nil || (m.ID == msg.ID && m.Source == msg.Source) {
+			continue
+		}
+		if m.Timestamp.After(msg.Timestamp) || !msg.SameConversation(m) {
+			continue
+		}
+		result = append(result, m)
+	}
+	slices.Reverse(result)
+	return result
+}
+
+// AddFeedback records a correction label on a buffered message and
+// refreshes the dashboard. It reports whether the message was found.
+func (s *Store) AddFeedback(seq uint64, label string) bool {
+	s.mu.Lock()
+	found := false
+	for i := 0; i < s.count; i++ {
+		idx := (s.writeIdx - 1 - i + s.capacity) % s.capacity
+		if pm := &s.messages[idx]; pm.Seq == seq && pm.Message != nil {
+			if !slices.Contains(pm.Feedback, label) {
+				pm.Feedback = append(slices.Clone(pm.Feedback), label)
+			}
+			found = true
+			break
+		}
+	}
 	s.mu.Unlock()
 
-	s.notifySubscribers("refresh")
+	if found {
+		s.notifySubscribers("refresh")
+	}
+	return found
 }
 
 // GetRecentMessages returns the most recent N messages in reverse chronological order.
@@ -139,21 +348,75 @@ func (s *Store) GetRecentMessages(limit int) []ProcessedMessage {
 	return result
 }
 
-// GetRecentMessagesBySource returns the most recent N messages from a specific source,
-// in reverse chronological order.
-func (s *Store) GetRecentMessagesBySource(source message.Source, limit int) []ProcessedMessage {
+// MessageQuery selects buffered messages. Zero-valued fields don't filter.
+type MessageQuery struct {
+	Text           string // full-text search over sender and text; every word must match
+	Source         message.Source
+	Sender         string    // case-insensitive substring of the sender
+	Urgent         *bool     // match classified messages with this urgency
+	HasActionItems *bool     // match classified messages with or without action items
+	Since          time.Time // message timestamp at or after
+	Until          time.Time // message timestamp before
+	Before         uint64    // only messages with Seq below this, for paging
+	Limit          int       // <= 0 means no limit
+}
+
+// matches reports whether pm satisfies the query's filters.
+func (q MessageQuery) matches(pm ProcessedMessage) bool {
+	m := pm.Message
+	if m == nil {
+		return false
+	}
+	if q.Before != 0 && pm.Seq >= q.Before {
+		return false
+	}
+	if q.Source != "" && m.Source != q.Source {
+		return false
+	}
+	if q.Sender != "" && !strings.Contains(strings.ToLower(m.Sender), strings.ToLower(q.Sender)) {
+		return false
+	}
+	if q.Urgent != nil && (pm.Classification == nil || pm.Classification.IsUrgent != *q.Urgent) {
+		return false
+	}
+	if q.HasActionItems != nil && (pm.Classification == nil || (len(pm.Classification.ActionItems) > 0) != *q.HasActionItems) {
+		return false
+	}
+	if !q.Since.IsZero() && m.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !m.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// QueryMessages returns the buffered messages matching q, newest first.
+func (s *Store) QueryMessages(q MessageQuery) []ProcessedMessage {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if limit <= 0 {
-		limit = s.count
+	var hits map[uint64]struct{}
+	if terms := tokenize(q.Text); len(terms) > 0 {
+		hits = s.index.search(terms)
+		if len(hits) == 0 {
+			return nil
+		}
 	}
 
-	result := make([]ProcessedMessage, 0, limit)
-	for i := 0; i < s.count && len(result) < limit; i++ {
+	var result []ProcessedMessage
+	for i := 0; i < s.count; i++ {
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
 		idx := (s.writeIdx - 1 - i + s.capacity) % s.capacity
 		pm := s.messages[idx]
-		if pm.Message != nil && pm.Message.Source == source {
+		if hits != nil {
+			if _, ok := hits[pm.Seq]; !ok {
+				continue
+			}
+		}
+		if q.matches(pm) {
 			result = append(result, pm)
 		}
 	}
@@ -161,30 +424,47 @@ func (s *Store) GetRecentMessagesBySource(source message.Source, limit int) []Pr
 }
 
 // UpdateListenerStatus updates the connection status of a listener.
-func (s *Store) UpdateListenerStatus(name string, source message.Source, connected bool) {
+func (s *Store) UpdateListenerStatus(name string, source message.Source, account string, connected bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	ls, ok := s.listeners[name]
 	if !ok {
 		ls = &ListenerStatus{
-			Name:   name,
-			Source: source,
+			Name:    name,
+			Source:  source,
+			Account: account,
 		}
 		s.listeners[name] = ls
 	}
 	ls.Connected = connected
 }
 
+// SetListenerAwaitingLogin records whether a listener is blocked on an
+// interactive login, and refreshes the dashboard.
+func (s *Store) SetListenerAwaitingLogin(name string, awaiting bool) {
+	s.mu.Lock()
+	ls, ok := s.listeners[name]
+	if ok {
+		ls.AwaitingLogin = awaiting
+		ls.Connected = !awaiting
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.notifySubscribers("refresh")
+	}
+}
+
 // IncrementListenerMessageCount increments the message count and updates the last
-// message time for the listener matching the given source.
-func (s *Store) IncrementListenerMessageCount(source message.Source) {
+// message time for the listener matching the given source and account.
+func (s *Store) IncrementListenerMessageCount(source message.Source, account string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
 	for _, ls := range s.listeners {
-		if ls.Source == source {
+		if ls.Source == source && ls.Account == account {
 			ls.MessageCount++
 			ls.LastMessage = &now
 			return
@@ -263,14 +543,15 @@ func (s *Store) GetActionItems(limit int) []ActionItemWithContext {
 		if pm.Classification == nil {
 			continue
 		}
-		for j, item := range pm.Classification.ActionItems {
+		for _, item := range pm.Classification.ActionItems {
 			if len(result) >= limit {
 				break
 			}
 			result = append(result, ActionItemWithContext{
 				Item:         item,
 				SourceMsg:    pm.Message,
-				EventCreated: j < pm.EventsCreated,
+				EventCreated: pm.created("calendar", item.Title),
+				TaskCreated:  pm.created("task", item.Title),
 				ProcessedAt:  pm.ProcessedAt,
 			})
 		}
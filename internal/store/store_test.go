@@ -0,0 +1,141 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+func processed(id, sender, text string, urgent bool) ProcessedMessage {
+	msg := message.NewMessage(message.SourceSlack, sender, text)
+	msg.ID = id
+	msg.Timestamp = time.Date(2025, 3, 12, 10, 0, 0, 0, time.UTC)
+	return ProcessedMessage{
+		Message:        msg,
+		Classification: &classifier.ClassificationResult{IsUrgent: urgent},
+	}
+}
+
+func edited(pm ProcessedMessage) ProcessedMessage {
+	pm.Message.Metadata["edited"] = "true"
+	return pm
+}
+
+// ids returns the message IDs of pms, in order.
+func ids(pms []ProcessedMessage) []string {
+	var result []string
+	for _, pm := range pms {
+		result = append(result, pm.Message.ID)
+	}
+	return result
+}
+
+func TestQueryMessages(t *testing.T) {
+	s := NewStore(10)
+	s.AddProcessedMessage(processed("1", "Ann Lee", "Lunch meeting moved to noon", false))
+	s.AddProcessedMessage(processed("2", "alerts", "Database is down", true))
+	s.AddProcessedMessage(processed("3", "Bob", "Meeting notes attached", false))
+	urgent, calm := true, false
+
+	tests := []struct {
+		name string
+		q    MessageQuery
+		want []string
+	}{
+		{"everything, newest first", MessageQuery{}, []string{"3", "2", "1"}},
+		{"word prefix", MessageQuery{Text: "meet"}, []string{"3", "1"}},
+		{"every word", MessageQuery{Text: "meeting noon"}, []string{"1"}},
+		{"sender is searched", MessageQuery{Text: "lee"}, []string{"1"}},
+		{"no hit", MessageQuery{Text: "invoice"}, nil},
+		{"urgent", MessageQuery{Urgent: &urgent}, []string{"2"}},
+		{"not urgent", MessageQuery{Urgent: &calm, Text: "meeting"}, []string{"3", "1"}},
+		{"sender substring", MessageQuery{Sender: "BOB"}, []string{"3"}},
+		{"limit", MessageQuery{Limit: 2}, []string{"3", "2"}},
+		{"paging", MessageQuery{Before: 3}, []string{"2", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(s.QueryMessages(tt.q)); !slices.Equal(got, tt.want) {
+				t.Errorf("QueryMessages(%+v) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMessagesAfterEdit(t *testing.T) {
+	s := NewStore(10)
+	s.AddProcessedMessage(processed("1", "Ann", "Standup at 9", false))
+	s.AddProcessedMessage(processed("2", "Bob", "Standup notes", false))
+	s.AddProcessedMessage(edited(processed("1", "Ann", "Retro at 10, standup cancelled", true)))
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"standup", []string{"2", "1"}}, // the edit keeps its place
+		{"retro", []string{"1"}},
+		{"9", nil}, // the old text is no longer indexed
+	}
+	for _, tt := range tests {
+		if got := ids(s.QueryMessages(MessageQuery{Text: tt.text})); !slices.Equal(got, tt.want) {
+			t.Errorf("QueryMessages(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	all := s.QueryMessages(MessageQuery{})
+	if len(all) != 2 || all[1].Seq != 1 || all[1].Message.Text != "Retro at 10, standup cancelled" {
+		t.Errorf("after the edit the buffer holds %+v", all)
+	}
+	if stats := s.GetStats(); stats.TotalMessages != 2 || stats.UrgentMessages != 1 {
+		t.Errorf("stats after the edit: %d messages, %d urgent; want 2, 1", stats.TotalMessages, stats.UrgentMessages)
+	}
+
+	// An edit of a message no longer buffered is stored as a new one
+	s.AddProcessedMessage(edited(processed("9", "Cy", "Standup moved", false)))
+	if got := ids(s.QueryMessages(MessageQuery{Text: "standup"})); !slices.Equal(got, []string{"9", "2", "1"}) {
+		t.Errorf("QueryMessages after editing an unknown message = %q", got)
+	}
+}
+
+func TestQueryMessagesAfterEviction(t *testing.T) {
+	s := NewStore(3)
+	texts := []string{"alpha report", "beta report", "gamma report", "delta report", "epsilon report"}
+	for i, text := range texts {
+		s.AddProcessedMessage(processed(string(rune('1'+i)), "bot", text, false))
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"report", []string{"5", "4", "3"}},
+		{"alpha", nil},
+		{"beta", nil},
+		{"gamma", []string{"3"}},
+		{"epsilon", []string{"5"}},
+	}
+	for _, tt := range tests {
+		if got := ids(s.QueryMessages(MessageQuery{Text: tt.text})); !slices.Equal(got, tt.want) {
+			t.Errorf("QueryMessages(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	// Evicted messages leave nothing behind in the index
+	if len(s.index.tokens) != 3 {
+		t.Errorf("index holds %d messages, want 3", len(s.index.tokens))
+	}
+	for _, tok := range []string{"alpha", "beta"} {
+		if _, ok := s.index.postings[tok]; ok {
+			t.Errorf("index still has %q", tok)
+		}
+	}
+
+	// An edit can't reach an evicted message
+	s.AddProcessedMessage(edited(processed("1", "bot", "alpha report, revised", false)))
+	if got := ids(s.QueryMessages(MessageQuery{Text: "report"})); !slices.Equal(got, []string{"1", "5", "4"}) {
+		t.Errorf("QueryMessages after editing an evicted message = %q", got)
+	}
+}
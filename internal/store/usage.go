@@ -0,0 +1,168 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/message"
+)
+
+// UsageReport is LLM usage for the current day and month, in local time.
+type UsageReport struct {
+	Today         classifier.Usage
+	Month         classifier.Usage
+	TodayBySource map[message.Source]classifier.Usage
+	MonthBySource map[message.Source]classifier.Usage
+}
+
+// dayKey groups usage by local calendar day.
+func dayKey(t time.Time) string {
+	return t.Local().Format("2006-01-02")
+}
+
+// UseUsageFile keeps the daily usage totals in a JSON file at path, so
+// spending and budgets carry over restarts. Totals already in the file are
+// loaded; a missing file starts from zero.
+func (s *Store) UseUsageFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read usage file: %w", err)
+	}
+	usage := make(map[string]map[message.Source]classifier.Usage)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return fmt.Errorf("failed to parse usage file: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for day, bySource := range usage {
+		if s.usage[day] == nil {
+			s.usage[day] = make(map[message.Source]classifier.Usage)
+		}
+		for src, u := range bySource {
+			s.usage[day][src] = s.usage[day][src].Add(u)
+		}
+	}
+	s.usagePath = path
+	return nil
+}
+
+// AddUsage records LLM usage that isn't part of a classification, such as
+// transcribing a voice note or describing an image, against source.
+func (s *Store) AddUsage(source message.Source, at time.Time, u classifier.Usage) {
+	if u.Calls == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addUsage(source, at, u)
+}
+
+// recordUsage adds the LLM usage behind a message's classification to its
+// day's totals. Must be called with s.mu held.
+func (s *Store) recordUsage(pm ProcessedMessage) {
+	if pm.Message == nil || pm.Classification == nil {
+		return
+	}
+	u := pm.Classification.Trace.Usage()
+	if u.Calls == 0 {
+		return
+	}
+	s.addUsage(pm.Message.Source, pm.ProcessedAt, u)
+}
+
+// addUsage adds u to the day's totals, dropping days before last month, and
+// saves them if a usage file is set. Must be called with s.mu held.
+func (s *Store) addUsage(source message.Source, at time.Time, u classifier.Usage) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	day := dayKey(at)
+	bySource := s.usage[day]
+	if bySource == nil {
+		bySource = make(map[message.Source]classifier.Usage)
+		s.usage[day] = bySource
+	}
+	bySource[source] = bySource[source].Add(u)
+
+	y, m, _ := at.Local().Date()
+	keep := time.Date(y, m-1, 1, 0, 0, 0, 0, time.Local).Format("2006-01-02")
+	for d := range s.usage {
+		if d < keep {
+			delete(s.usage, d)
+		}
+	}
+
+	if s.usagePath != "" {
+		if err := s.saveUsage(); err != nil {
+			slog.Warn("Failed to save LLM usage", "path", s.usagePath, "error", err)
+		}
+	}
+}
+
+// saveUsage replaces the usage file atomically, so a crash can't lose the
+// totals. Must be called with s.mu held.
+func (s *Store) saveUsage() error {
+	data, err := json.MarshalIndent(s.usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.usagePath), ".usage-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.usagePath)
+}
+
+// UsageTotals returns the LLM usage in the day and month containing at.
+func (s *Store) UsageTotals(at time.Time) (day, month classifier.Usage) {
+	r := s.GetUsage(at)
+	return r.Today, r.Month
+}
+
+// GetUsage reports LLM usage in the day and month containing at.
+func (s *Store) GetUsage(at time.Time) UsageReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := UsageReport{
+		TodayBySource: make(map[message.Source]classifier.Usage),
+		MonthBySource: make(map[message.Source]classifier.Usage),
+	}
+	today := dayKey(at)
+	month := today[:len("2006-01")]
+	for d, bySource := range s.usage {
+		if d[:len(month)] != month {
+			continue
+		}
+		for src, u := range bySource {
+			r.Month = r.Month.Add(u)
+			r.MonthBySource[src] = r.MonthBySource[src].Add(u)
+			if d == today {
+				r.Today = r.Today.Add(u)
+				r.TodayBySource[src] = r.TodayBySource[src].Add(u)
+			}
+		}
+	}
+	return r
+}
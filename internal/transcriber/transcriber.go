@@ -0,0 +1,106 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+// Transcriber converts recorded speech to text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, fileName string) (*Transcript, error)
+}
+
+// Transcript is recognized speech.
+type Transcript struct {
+	Text  string
+	Usage classifier.Usage // zero for local models
+}
+
+// whisperPerMinute is what whisper-1 costs in US dollars per minute of
+// audio. Newer models bill by the token instead.
+const whisperPerMinute = 0.006
+
+// New creates the transcriber selected by cfg.Provider. prices override the
+// built-in ones used to estimate what each call costs.
+func New(cfg config.TranscriptionConfig, prices map[string]config.ModelPrice) (Transcriber, error) {
+	switch cfg.Provider {
+	case "", "openai":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("transcription provider openai requires an api_key")
+		}
+		return NewOpenAITranscriber(cfg, prices), nil
+	case "whispercpp":
+		return NewWhisperCppTranscriber(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown transcription provider %q", cfg.Provider)
+	}
+}
+
+// OpenAITranscriber uses the OpenAI audio transcription API (Whisper).
+type OpenAITranscriber struct {
+	client   openai.Client
+	model    string
+	language string
+	prices   map[string]config.ModelPrice
+}
+
+// NewOpenAITranscriber creates a transcriber backed by the OpenAI API.
+func NewOpenAITranscriber(cfg config.TranscriptionConfig, prices map[string]config.ModelPrice) *OpenAITranscriber {
+	opts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = openai.AudioModelWhisper1
+	}
+
+	return &OpenAITranscriber{
+		client:   openai.NewClient(opts...),
+		model:    model,
+		language: cfg.Language,
+		prices:   prices,
+	}
+}
+
+func (o *OpenAITranscriber) Transcribe(ctx context.Context, audio []byte, fileName string) (*Transcript, error) {
+	params := openai.AudioTranscriptionNewParams{
+		File:  openai.File(bytes.NewReader(audio), fileName, ""),
+		Model: o.model,
+	}
+	if o.language != "" {
+		params.Language = openai.String(o.language)
+	}
+
+	resp, err := o.client.Audio.Transcriptions.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI transcription error: %w", err)
+	}
+	return &Transcript{Text: resp.Text, Usage: o.usage(resp.Usage)}, nil
+}
+
+// usage converts what the API reports, tokens or seconds of audio depending
+// on the model, into classifier.Usage.
+func (o *OpenAITranscriber) usage(u openai.TranscriptionUsageUnion) classifier.Usage {
+	result := classifier.Usage{Calls: 1}
+	switch u.Type {
+	case "tokens":
+		result.PromptTokens = int(u.InputTokens)
+		result.CompletionTokens = int(u.OutputTokens)
+		result.Cost = classifier.EstimateCost(o.prices, o.model, result.PromptTokens, result.CompletionTokens)
+	case "duration":
+		if strings.HasPrefix(o.model, openai.AudioModelWhisper1) {
+			result.Cost = u.Seconds / 60 * whisperPerMinute
+		}
+	}
+	return result
+}
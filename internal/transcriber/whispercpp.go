@@ -0,0 +1,147 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+// WhisperCppTranscriber runs a local whisper.cpp model, either through a
+// running whisper.cpp server or by invoking the whisper-cli binary. Audio is
+// converted to 16 kHz mono WAV with ffmpeg first, as whisper.cpp requires.
+type WhisperCppTranscriber struct {
+	serverURL  string
+	binaryPath string
+	modelPath  string
+	ffmpegPath string
+	language   string
+	httpClient *http.Client
+}
+
+// NewWhisperCppTranscriber creates a transcriber backed by whisper.cpp.
+func NewWhisperCppTranscriber(cfg config.TranscriptionConfig) *WhisperCppTranscriber {
+	binary := cfg.BinaryPath
+	if binary == "" {
+		binary = "whisper-cli"
+	}
+	ffmpeg := cfg.FFmpegPath
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	language := cfg.Language
+	if language == "" {
+		language = "auto"
+	}
+
+	return &WhisperCppTranscriber{
+		serverURL:  strings.TrimSuffix(cfg.ServerURL, "/"),
+		binaryPath: binary,
+		modelPath:  cfg.Model,
+		ffmpegPath: ffmpeg,
+		language:   language,
+		httpClient: &http.Client{},
+	}
+}
+
+func (w *WhisperCppTranscriber) Transcribe(ctx context.Context, audio []byte, fileName string) (*Transcript, error) {
+	dir, err := os.MkdirTemp("", "notifylm-whisper-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, filepath.Base(fileName))
+	if err := os.WriteFile(input, audio, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write audio: %w", err)
+	}
+
+	wav := filepath.Join(dir, "audio.wav")
+	convert := exec.CommandContext(ctx, w.ffmpegPath, "-nostdin", "-loglevel", "error",
+		"-i", input, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav)
+	if out, err := convert.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg conversion failed: %w: %s", err, out)
+	}
+
+	var text string
+	if w.serverURL != "" {
+		text, err = w.transcribeServer(ctx, wav)
+	} else {
+		text, err = w.transcribeBinary(ctx, wav)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Runs locally, so it costs nothing
+	return &Transcript{Text: text}, nil
+}
+
+// transcribeServer posts the WAV file to a whisper.cpp server's /inference endpoint.
+func (w *WhisperCppTranscriber) transcribeServer(ctx context.Context, wav string) (string, error) {
+	data, err := os.ReadFile(wav)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", err
+	}
+	part.Write(data)
+	form.WriteField("response_format", "json")
+	form.WriteField("language", w.language)
+	form.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.serverURL+"/inference", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("whisper.cpp server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("whisper.cpp server returned %s: %s", resp.Status, msg)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode whisper.cpp response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// transcribeBinary runs whisper-cli and reads the transcript from stdout.
+func (w *WhisperCppTranscriber) transcribeBinary(ctx context.Context, wav string) (string, error) {
+	if w.modelPath == "" {
+		return "", fmt.Errorf("whisper.cpp model path is not configured")
+	}
+
+	cmd := exec.CommandContext(ctx, w.binaryPath,
+		"-m", w.modelPath, "-f", wav, "-l", w.language, "-nt", "-np")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("whisper.cpp failed: %w: %s", err, stderr.String())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
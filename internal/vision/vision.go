@@ -0,0 +1,163 @@
+package vision
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/emirlan/notifylm/internal/classifier"
+	"github.com/emirlan/notifylm/internal/config"
+)
+
+// Description is what a vision model extracted from an image.
+type Description struct {
+	Text        string                  // what the image shows, including any legible text
+	ActionItems []classifier.ActionItem // events and tasks found in the image (bills, tickets, invitations)
+	Usage       classifier.Usage        // tokens and estimated cost of the call
+}
+
+// Describer turns images into text.
+type Describer interface {
+	Describe(ctx context.Context, image []byte, mimeType, caption string) (*Description, error)
+}
+
+// OpenAIDescriber uses an OpenAI-compatible multimodal chat model. Setting
+// base_url points it at a local server (e.g. Ollama or llama.cpp).
+type OpenAIDescriber struct {
+	client openai.Client
+	model  string
+	prices map[string]config.ModelPrice
+}
+
+// NewOpenAIDescriber creates a describer for the configured model. prices
+// override the built-in ones used to estimate what each call costs.
+func NewOpenAIDescriber(cfg config.VisionConfig, prices map[string]config.ModelPrice) *OpenAIDescriber {
+	opts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+
+	return &OpenAIDescriber{
+		client: openai.NewClient(opts...),
+		model:  model,
+		prices: prices,
+	}
+}
+
+const systemPrompt = `You describe images that were sent to the user in chats and emails. Return a JSON object with two fields:
+
+1. "description" (string): what the image shows in one to three sentences, followed by any important text it contains verbatim (amounts, due dates, reference numbers, addresses, times).
+
+2. "events" (array): dated items from the image such as bill due dates, flights, tickets or invitations. Each item has:
+   - "title": short summary
+   - "description": fuller context
+   - "datetime": in the user's timezone without an offset: "2025-03-15T14:00" for a set time, or just the date "2025-03-15" for a due date or whole day
+   - "duration_minutes": estimated duration in minutes (default 30 if unclear)
+
+   If there are no dated items, return an empty array.
+
+Respond with ONLY valid JSON.`
+
+type visionResponse struct {
+	Description string `json:"description"`
+	Events      []struct {
+		Title           string `json:"title"`
+		Description     string `json:"description"`
+		Datetime        string `json:"datetime"`
+		DurationMinutes int    `json:"duration_minutes"`
+	} `json:"events"`
+}
+
+func (o *OpenAIDescriber) Describe(ctx context.Context, image []byte, mimeType, caption string) (*Description, error) {
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(image))
+
+	prompt := "Describe this image."
+	if caption != "" {
+		prompt = fmt.Sprintf("Describe this image. It was sent with the caption: %q", caption)
+	}
+	// Dates on bills and tickets often leave out the year
+	now := time.Now()
+	prompt += fmt.Sprintf(" Today is %s.", now.Format("Monday, 2006-01-02"))
+
+	resp, err := o.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: o.model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemPrompt),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart(prompt),
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: dataURL}),
+			}),
+		},
+		MaxCompletionTokens: openai.Int(2048),
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{
+				Type: "json_object",
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("vision API error: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from vision model")
+	}
+	promptTokens, completionTokens := int(resp.Usage.PromptTokens), int(resp.Usage.CompletionTokens)
+	usage := classifier.Usage{
+		Calls:            1,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Cost:             classifier.EstimateCost(o.prices, o.model, promptTokens, completionTokens),
+	}
+
+	content := strings.TrimSpace(resp.Choices[0].Message.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+
+	var parsed visionResponse
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &parsed); err != nil {
+		// Models without JSON mode may answer in prose; keep it as the description.
+		slog.Debug("Vision response is not JSON, using it verbatim", "error", err)
+		return &Description{Text: content, Usage: usage}, nil
+	}
+
+	desc := &Description{Text: parsed.Description, Usage: usage}
+	for _, ev := range parsed.Events {
+		if strings.TrimSpace(ev.Title) == "" {
+			continue
+		}
+		// Without a usable date the item is still worth keeping as a task
+		t, allDay, err := classifier.ParseDateTime(ev.Datetime, now)
+		if err != nil && ev.Datetime != "" {
+			slog.Warn("Failed to parse image event datetime, keeping it as a task", "datetime", ev.Datetime, "error", err)
+		}
+		duration := ev.DurationMinutes
+		if duration <= 0 {
+			duration = 30
+		}
+		desc.ActionItems = append(desc.ActionItems, classifier.ActionItem{
+			Title:           ev.Title,
+			Description:     ev.Description,
+			DateTime:        t,
+			DurationMinutes: duration,
+			AllDay:          allDay,
+		})
+	}
+	return desc, nil
+}